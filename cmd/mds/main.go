@@ -0,0 +1,29 @@
+// Command mds runs the modern data stack pipeline: it converts source files to Parquet,
+// creates and loads the Iceberg tables, queries them and maintains them.
+package main
+
+import (
+	"os"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/convert"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/warehouse"
+)
+
+func main() {
+	logging.Install()
+	commands := []cli.Command{
+		{Name: "convert", Summary: "Convert the CSV, TSV, JSON, Excel and fixed-width files of the data directory to Parquet", Run: convert.Run},
+		{Name: "tables", Summary: "Create the Iceberg tables and change their layout", Subcommands: warehouse.TableCommands},
+	}
+	commands = append(commands, warehouse.PlanCommands...)
+	commands = append(commands, warehouse.LoadCommands...)
+	commands = append(commands,
+		warehouse.QueryCommand,
+		cli.Command{Name: "inspect", Summary: "Show the schema and history of a table", Subcommands: warehouse.InspectCommands},
+		cli.Command{Name: "maintain", Summary: "Delete rows, manage branches and tags, roll back and clean up tables", Subcommands: warehouse.MaintainCommands},
+	)
+
+	cli.Main("mds", commands, os.Args[1:])
+}
@@ -2,7 +2,11 @@ module the-modern-data-stack
 
 go 1.24.5
 
-require github.com/marcboeker/go-duckdb v1.8.5
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/marcboeker/go-duckdb v1.8.5
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/apache/arrow-go/v18 v18.3.1 // indirect
@@ -12,7 +16,6 @@ require (
 	github.com/google/flatbuffers v25.2.10+incompatible // indirect
 	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.10 // indirect
 	github.com/pierrec/lz4/v4 v4.1.22 // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
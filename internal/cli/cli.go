@@ -0,0 +1,196 @@
+// Package cli runs the commands of a command-line program: it dispatches nested
+// subcommands, prints consistent help and usage messages, maps errors to exit codes and
+// completes command names and flags in the shell.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"the-modern-data-stack/internal/logging"
+)
+
+// Exit codes of the program
+const (
+	ExitOK      = 0   // the command succeeded
+	ExitFailure = 1   // the command failed
+	ExitUsage   = 2   // the command line is invalid: unknown command, flag or missing arguments
+	ExitSignal  = 130 // the command was interrupted by SIGINT or SIGTERM
+)
+
+// Command is a command of the program, either a group of subcommands or a command that runs
+type Command struct {
+	Name        string
+	Summary     string
+	Args        string                                         // positional arguments shown in the usage, such as "<table> <file.parquet>..."
+	Run         func(ctx context.Context, args []string) error // ctx is canceled on SIGINT or SIGTERM
+	Subcommands []Command
+}
+
+// UsageError reports an invalid command line, which exits with ExitUsage
+type UsageError struct {
+	message string
+}
+
+func (e *UsageError) Error() string {
+	return e.message
+}
+
+// Usagef returns a UsageError with a formatted message
+func Usagef(format string, args ...interface{}) error {
+	return &UsageError{message: fmt.Sprintf(format, args...)}
+}
+
+// running is the command being run, which the usage message of its flag set describes
+var running struct {
+	path    string
+	command *Command
+}
+
+// completing is set while completing a command line, so that flag sets list their flags
+// instead of running the command
+var completing struct {
+	active bool
+	prefix string
+}
+
+// NewFlagSet returns the flag set of a command, with the logging flags. Its usage message
+// shows the command path, summary, arguments and flags, and it exits with ExitUsage on an
+// invalid flag.
+func NewFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	logging.AddFlags(fs)
+	fs.Usage = func() {
+		if completing.active {
+			fs.VisitAll(func(f *flag.Flag) {
+				if strings.HasPrefix("--"+f.Name, completing.prefix) {
+					fmt.Println("--" + f.Name)
+				}
+			})
+			return
+		}
+
+		out := fs.Output()
+		if running.command == nil {
+			fmt.Fprintf(out, "Usage of %s:\n", name)
+			fs.PrintDefaults()
+			return
+		}
+		fmt.Fprintf(out, "Usage: %s [flags] %s\n\n%s\n\nFlags:\n", running.path, running.command.Args, running.command.Summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// printGroupUsage prints the subcommands of a group
+func printGroupUsage(path string, commands []Command) {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n", path)
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-22s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for more about a command.\n", path)
+}
+
+// find returns the command of a list with the given name
+func find(commands []Command, name string) *Command {
+	for i := range commands {
+		if commands[i].Name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// run runs a command with a context canceled on the first SIGINT or SIGTERM, so that it
+// stops cleanly; a second signal kills the program. It reports whether the command was
+// interrupted, whatever error that made it return.
+func run(cmd *Command, args []string) (interrupted bool, err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+			fmt.Fprintln(os.Stderr, "\n⏹️  Stopping... (interrupt again to kill)")
+		case <-done:
+		}
+	}()
+	err = cmd.Run(ctx, args)
+	return ctx.Err() != nil, err
+}
+
+// Main runs the command named by the arguments and exits with ExitFailure if it fails,
+// or ExitUsage if the command line is invalid. The completion command and the hidden
+// __complete command it relies on are added to the commands of the program.
+func Main(program string, commands []Command, args []string) {
+	if code := execute(program, commands, args); code != ExitOK {
+		os.Exit(code)
+	}
+}
+
+// execute runs the command named by the arguments and returns the exit code of the program
+func execute(program string, commands []Command, args []string) int {
+	commands = append(commands, Command{
+		Name:    "completion",
+		Summary: "Print the shell completion script (bash, zsh or fish)",
+		Args:    "<shell>",
+		Run:     func(ctx context.Context, args []string) error { return printCompletionScript(program, args) },
+	})
+	if len(args) > 0 && args[0] == "__complete" {
+		complete(commands, args[1:])
+		return ExitOK
+	}
+
+	path := program
+	for {
+		if len(args) == 0 {
+			printGroupUsage(path, commands)
+			return ExitUsage
+		}
+		name := args[0]
+		if name == "-h" || name == "--help" || name == "help" {
+			printGroupUsage(path, commands)
+			return ExitOK
+		}
+
+		cmd := find(commands, name)
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+			printGroupUsage(path, commands)
+			return ExitUsage
+		}
+		path += " " + name
+		args = args[1:]
+		if cmd.Run == nil {
+			commands = cmd.Subcommands
+			continue
+		}
+
+		running.path = path
+		running.command = cmd
+		interrupted, err := run(cmd, args)
+		var usageErr *UsageError
+		switch {
+		case err == nil:
+			return ExitOK
+		case interrupted || errors.Is(err, context.Canceled):
+			fmt.Fprintf(os.Stderr, "Interrupted: %v\n", err)
+			return ExitSignal
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(os.Stderr, "Error: %v\nUsage: %s [flags] %s\nRun '%s -h' for its flags.\n", err, path, cmd.Args, path)
+			return ExitUsage
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitFailure
+		}
+	}
+}
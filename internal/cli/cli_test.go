@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestExitCodes(t *testing.T) {
+	commands := []Command{
+		{Name: "ok", Run: func(ctx context.Context, args []string) error { return nil }},
+		{Name: "fail", Run: func(ctx context.Context, args []string) error { return errors.New("boom") }},
+		{Name: "usage", Run: func(ctx context.Context, args []string) error {
+			return fmt.Errorf("checking flags: %w", Usagef("expected a table"))
+		}},
+		{Name: "canceled", Run: func(ctx context.Context, args []string) error {
+			return fmt.Errorf("query failed: %w", context.Canceled)
+		}},
+		{Name: "interrupted", Run: func(ctx context.Context, args []string) error {
+			// A command stopped by a signal may return an error that does not wrap
+			// context.Canceled, such as the one of an interrupted query
+			if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return errors.New("INTERRUPT Error: Interrupted!")
+		}},
+		{Name: "tables", Subcommands: []Command{
+			{Name: "create", Run: func(ctx context.Context, args []string) error {
+				if len(args) != 1 {
+					return Usagef("expected a single table")
+				}
+				return nil
+			}},
+		}},
+	}
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"ok"}, ExitOK},
+		{[]string{"help"}, ExitOK},
+		{[]string{"tables", "-h"}, ExitOK},
+		{[]string{"tables", "create", "sales"}, ExitOK},
+		{[]string{"fail"}, ExitFailure},
+		{[]string{}, ExitUsage},
+		{[]string{"tables"}, ExitUsage},
+		{[]string{"unknown"}, ExitUsage},
+		{[]string{"tables", "unknown"}, ExitUsage},
+		{[]string{"usage"}, ExitUsage},
+		{[]string{"tables", "create"}, ExitUsage},
+		{[]string{"canceled"}, ExitSignal},
+		{[]string{"interrupted"}, ExitSignal},
+	}
+	for _, test := range tests {
+		if got := execute("mds", commands, test.args); got != test.want {
+			t.Errorf("exit code of %v = %d, want %d", test.args, got, test.want)
+		}
+	}
+}
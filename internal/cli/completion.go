@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// completionScripts are the shell scripts that complete the program's command lines by
+// calling its hidden __complete command. Words without candidates fall back to file names.
+var completionScripts = map[string]string{
+	"bash": `_%[1]s_complete() {
+    local IFS=$'\n'
+    COMPREPLY=($(%[1]s __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _%[1]s_complete %[1]s
+`,
+	"zsh": `autoload -U +X bashcompinit && bashcompinit
+_%[1]s_complete() {
+    local IFS=$'\n'
+    COMPREPLY=($(%[1]s __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _%[1]s_complete %[1]s
+`,
+	"fish": `complete -c %[1]s -a '(%[1]s __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+`,
+}
+
+// printCompletionScript prints the completion script of a shell
+func printCompletionScript(program string, args []string) error {
+	if len(args) != 1 {
+		return Usagef("expected a shell: bash, zsh or fish")
+	}
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		return Usagef("unsupported shell %q, expected bash, zsh or fish", args[0])
+	}
+	fmt.Printf(script, program)
+	return nil
+}
+
+// complete prints the candidates for the last, possibly empty, word of a command line:
+// the subcommands of a group, or the flags of a command when the word starts with "-"
+func complete(commands []Command, words []string) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	prefix := words[len(words)-1]
+
+	for _, word := range words[:len(words)-1] {
+		cmd := find(commands, word)
+		if cmd == nil {
+			return
+		}
+		if cmd.Run != nil {
+			if strings.HasPrefix(prefix, "-") && cmd.Name != "completion" {
+				// The flag set of the command prints its flags instead of a usage message
+				completing.active = true
+				completing.prefix = prefix
+				cmd.Run(context.Background(), []string{"-h"})
+			}
+			return
+		}
+		commands = cmd.Subcommands
+	}
+
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd.Name, prefix) {
+			fmt.Println(cmd.Name)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package convert
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"the-modern-data-stack/internal/files"
+	"the-modern-data-stack/internal/project"
+)
+
+// source is a file to convert: a source file of the data directory, or one decompressed
+// or extracted from an archive into a temporary directory
+type source struct {
+	origin string // the file as found, or the path of the archive joined with the entry path
+	path   string // the plain file read by DuckDB
+	table  string
+	layout string // layout file of a fixed-width file, when the project config sets none
+	entry  bool   // extracted from an archive, so that origin is no file of its own
+	err    error  // why the file could not be decompressed or extracted
+}
+
+// splitCompression returns a file name without its compression extension, .gz or .zst,
+// and the extension; .tgz is short for .tar.gz
+func splitCompression(name string) (string, string) {
+	ext := filepath.Ext(name)
+	switch strings.ToLower(ext) {
+	case ".gz", ".zst":
+		return strings.TrimSuffix(name, ext), strings.ToLower(ext)
+	case ".tgz":
+		return strings.TrimSuffix(name, ext) + ".tar", ".gz"
+	}
+	return name, ""
+}
+
+// isArchive reports whether a file, once decompressed, is a zip or tar archive. Zip
+// archives are read in place, so they cannot be compressed themselves.
+func isArchive(name, compression string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip":
+		return compression == ""
+	case ".tar":
+		return true
+	}
+	return false
+}
+
+// isLayout reports whether a file is the layout file of a fixed-width file
+func isLayout(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".layout.yaml")
+}
+
+// isSource reports whether a file is converted: its extension is one of a known format,
+// or the project config of its table sets its format
+func isSource(name, table string, tables map[string]project.TableOptions) bool {
+	if isLayout(name) {
+		return false
+	}
+	if _, ok := formatExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+		return true
+	}
+	return tables[table].Format != ""
+}
+
+// defaultLayout returns the layout file of a fixed-width file when the project config sets
+// none: <file>.layout.yaml next to it
+func defaultLayout(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".layout.yaml"
+}
+
+// decompress returns a reader of the decompressed content of a compressed file
+func decompress(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case ".gz":
+		return gzip.NewReader(r)
+	case ".zst":
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return io.NopCloser(r), nil
+}
+
+// findSources finds the source files of a directory: files of a known format, compressed
+// or not, those of tables whose project config sets a format, and zip and tar archives.
+// Fixed-width layout files are skipped, and so are the excluded directories, such as the
+// Parquet and warehouse directories when they are inside it, and the metadata directories
+// of Iceberg tables, whose JSON files are no source files.
+func findSources(dir string, excluded []string, tables map[string]project.TableOptions) ([]string, error) {
+	skip := make(map[string]bool)
+	for _, path := range excluded {
+		if path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			skip[abs] = true
+		}
+	}
+	return files.FindFuncSkipping(dir, func(path string) bool {
+		if abs, err := filepath.Abs(path); err == nil && skip[abs] {
+			return true
+		}
+		return isIcebergMetadataDir(path)
+	}, func(path string) bool {
+		name, compression := splitCompression(path)
+		return isArchive(name, compression) || isSource(name, files.TableName(name), tables)
+	})
+}
+
+// isIcebergMetadataDir reports whether a directory is the metadata directory of an Iceberg
+// table, holding its metadata JSON files
+func isIcebergMetadataDir(path string) bool {
+	if filepath.Base(path) != "metadata" {
+		return false
+	}
+	matches, _ := filepath.Glob(filepath.Join(path, "*.metadata.json"))
+	return len(matches) > 0
+}
+
+// expandSources decompresses the compressed source files and extracts the source files of
+// the archives into a temporary directory, each of them in its own subdirectory. A file
+// that cannot be read yields a source holding the error, so that the others are still
+// converted.
+func expandSources(ctx context.Context, paths []string, tempDir string, tables map[string]project.TableOptions) []source {
+	var sources []source
+	for i, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		name, compression := splitCompression(path)
+		dir := filepath.Join(tempDir, strconv.Itoa(i))
+
+		switch {
+		case isArchive(name, compression):
+			sources = append(sources, extractArchive(ctx, path, name, compression, dir, tables)...)
+
+		case compression != "":
+			target := filepath.Join(dir, filepath.Base(name))
+			err := decompressFile(path, compression, target)
+			if err != nil {
+				err = fmt.Errorf("failed to decompress the file: %w", err)
+			}
+			sources = append(sources, source{origin: path, path: target, table: files.TableName(name), layout: defaultLayout(name), err: err})
+
+		default:
+			sources = append(sources, source{origin: path, path: path, table: files.TableName(path), layout: defaultLayout(path)})
+		}
+	}
+	return sources
+}
+
+// decompressFile streams the decompressed content of a file to a new file
+func decompressFile(path, compression, target string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := decompress(file, compression)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return writeFile(target, reader)
+}
+
+// writeFile streams a reader to a new file, creating its directory
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// archive extracts the source files of an archive, and the layout files next to them,
+// into a directory
+type archive struct {
+	path    string
+	table   string // table name prefix of the entries: the archive name without extensions
+	dir     string
+	tables  map[string]project.TableOptions
+	sources []source
+}
+
+// extractArchive extracts the source files of a zip or tar archive, compressed or not
+func extractArchive(ctx context.Context, path, name, compression, dir string, tables map[string]project.TableOptions) []source {
+	a := &archive{path: path, table: files.TableName(name), dir: dir, tables: tables}
+	var err error
+	if strings.EqualFold(filepath.Ext(name), ".zip") {
+		err = a.extractZip(ctx)
+	} else {
+		err = a.extractTar(ctx, compression)
+	}
+	if err != nil {
+		a.sources = append(a.sources, source{origin: path, table: a.table, err: fmt.Errorf("failed to extract the archive: %w", err)})
+	}
+	return a.sources
+}
+
+func (a *archive) extractZip(ctx context.Context) error {
+	reader, err := zip.OpenReader(a.path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		r, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		a.extract(entry.Name, r)
+		r.Close()
+	}
+	return nil
+}
+
+func (a *archive) extractTar(ctx context.Context, compression string) error {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decompressed, err := decompress(file, compression)
+	if err != nil {
+		return err
+	}
+	defer decompressed.Close()
+
+	reader := tar.NewReader(decompressed)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Links and devices are skipped: only the content of regular files is extracted
+		if header.Typeflag == tar.TypeReg {
+			a.extract(header.Name, reader)
+		}
+	}
+}
+
+// extract extracts an entry of the archive if it is a source or layout file, decompressing
+// it if needed. Entries whose path would escape the extraction directory, such as
+// ../../etc/cron.d/x or /etc/passwd (zip slip), are rejected.
+func (a *archive) extract(entryName string, r io.Reader) {
+	inner, compression := splitCompression(filepath.FromSlash(entryName))
+	table := files.TableName(a.table + "_" + strings.ReplaceAll(inner, string(filepath.Separator), "_"))
+	layout := isLayout(inner)
+	if !layout && !isSource(inner, table, a.tables) {
+		return
+	}
+
+	origin := filepath.Join(a.path, inner)
+	if !filepath.IsLocal(inner) {
+		if !layout {
+			a.sources = append(a.sources, source{origin: a.path + ":" + entryName, table: a.table, err: fmt.Errorf("unsafe path %q in the archive, escaping the extraction directory", entryName)})
+		}
+		return
+	}
+
+	target := filepath.Join(a.dir, inner)
+	reader, err := decompress(r, compression)
+	if err == nil {
+		err = writeFile(target, reader)
+		reader.Close()
+	}
+	if layout {
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to extract %s: %w", entryName, err)
+	}
+	a.sources = append(a.sources, source{origin: origin, path: target, table: table, layout: defaultLayout(target), entry: true, err: err})
+}
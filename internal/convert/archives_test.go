@@ -0,0 +1,237 @@
+package convert
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveEntry is a file of a test archive; a link is written as a symbolic link to
+// orders.csv in tar archives
+type archiveEntry struct {
+	name    string
+	content string
+	link    bool
+}
+
+// gzipped returns content compressed with gzip
+func gzipped(t *testing.T, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func writeZip(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(file)
+	for _, entry := range entries {
+		if entry.link {
+			continue
+		}
+		f, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTar(t *testing.T, path, compression string, entries []archiveEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		if entry.link {
+			header = &tar.Header{Name: entry.name, Linkname: "orders.csv", Typeflag: tar.TypeSymlink}
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if !entry.link {
+			if _, err := w.Write([]byte(entry.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	switch compression {
+	case ".gz":
+		data = []byte(gzipped(t, buf.String()))
+	case ".zst":
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = encoder.EncodeAll(buf.Bytes(), nil)
+		encoder.Close()
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandArchives(t *testing.T) {
+	entries := []archiveEntry{
+		{name: "orders.csv", content: "id\n1\n"},
+		{name: "2024/q1/orders.csv", content: "id\n2\n"},
+		{name: "nested/sales.csv.gz", content: gzipped(t, "id\n3\n")},
+		{name: "fixed/people.fwf", content: "000001Paris\n"},
+		{name: "fixed/people.layout.yaml", content: "columns: []\n"},
+		{name: "README.txt", content: "not a source"},
+		{name: "../x.csv", content: "id\n4\n"},
+		{name: "/etc/x.csv", content: "id\n5\n"},
+		{name: "../x.layout.yaml", content: "columns: []\n"},
+		{name: "link.csv", link: true},
+	}
+
+	tests := []struct {
+		name  string
+		write func(t *testing.T, path string)
+	}{
+		{"bundle.zip", func(t *testing.T, path string) { writeZip(t, path, entries) }},
+		{"bundle.tar", func(t *testing.T, path string) { writeTar(t, path, "", entries) }},
+		{"bundle.tar.gz", func(t *testing.T, path string) { writeTar(t, path, ".gz", entries) }},
+		{"bundle.tgz", func(t *testing.T, path string) { writeTar(t, path, ".gz", entries) }},
+		{"bundle.tar.zst", func(t *testing.T, path string) { writeTar(t, path, ".zst", entries) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, test.name)
+			test.write(t, path)
+			tempDir := filepath.Join(dir, "tmp")
+
+			sources := expandSources(context.Background(), []string{path}, tempDir, nil)
+
+			extracted := make(map[string]source)
+			var failed []string
+			for _, src := range sources {
+				if src.err != nil {
+					if src.table != "bundle" || !strings.Contains(src.err.Error(), "unsafe path") {
+						t.Errorf("unexpected failure of %s (table %s): %v", src.origin, src.table, src.err)
+					}
+					failed = append(failed, strings.TrimPrefix(src.origin, path+":"))
+					continue
+				}
+				extracted[src.table] = src
+			}
+
+			sort.Strings(failed)
+			if want := []string{"../x.csv", "/etc/x.csv"}; strings.Join(failed, " ") != strings.Join(want, " ") {
+				t.Errorf("rejected entries %v, want %v", failed, want)
+			}
+
+			want := map[string]struct{ entry, content string }{
+				"bundle_orders":         {"orders.csv", "id\n1\n"},
+				"bundle_2024_q1_orders": {"2024/q1/orders.csv", "id\n2\n"},
+				"bundle_nested_sales":   {"nested/sales.csv", "id\n3\n"},
+				"bundle_fixed_people":   {"fixed/people.fwf", "000001Paris\n"},
+			}
+			if len(extracted) != len(want) {
+				t.Errorf("extracted tables %v, want %d", extracted, len(want))
+			}
+			for table, w := range want {
+				src, ok := extracted[table]
+				if !ok {
+					t.Errorf("table %s was not extracted", table)
+					continue
+				}
+				if !src.entry {
+					t.Errorf("%s is not marked as an archive entry", table)
+				}
+				if wantOrigin := filepath.Join(path, filepath.FromSlash(w.entry)); src.origin != wantOrigin {
+					t.Errorf("origin of %s = %s, want %s", table, src.origin, wantOrigin)
+				}
+				if !strings.HasPrefix(src.path, tempDir+string(filepath.Separator)) {
+					t.Errorf("%s was extracted to %s, outside %s", table, src.path, tempDir)
+				}
+				content, err := os.ReadFile(src.path)
+				if err != nil {
+					t.Errorf("failed to read %s: %v", table, err)
+				} else if string(content) != w.content {
+					t.Errorf("content of %s = %q, want %q", table, content, w.content)
+				}
+			}
+
+			// The layout file is extracted next to its fixed-width file
+			if _, err := os.Stat(extracted["bundle_fixed_people"].layout); err != nil {
+				t.Errorf("layout file of the fixed-width entry: %v", err)
+			}
+
+			// Nothing was written outside the extraction directory
+			for _, name := range []string{filepath.Join(dir, "x.csv"), filepath.Join(dir, "x.layout.yaml"), filepath.Join(tempDir, "x.csv")} {
+				if _, err := os.Stat(name); !os.IsNotExist(err) {
+					t.Errorf("%s was written by the extraction", name)
+				}
+			}
+		})
+	}
+}
+
+func TestArchiveTableNames(t *testing.T) {
+	tests := []struct {
+		archive, entry, table string
+	}{
+		{"bundle.zip", "orders.csv", "bundle_orders"},
+		{"bundle.zip", "2024/orders.csv", "bundle_2024_orders"},
+		{"monthly-export.tar.gz", "sales data/2024-01.csv", "monthly_export_sales_data_2024_01"},
+		{"bundle.tgz", "a/b/c.json.gz", "bundle_a_b_c"},
+		{"bundle.tar.zst", "items.ndjson", "bundle_items"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, test.archive)
+		content := "id\n1\n"
+		if strings.HasSuffix(test.entry, ".gz") {
+			content = gzipped(t, content)
+		}
+		entries := []archiveEntry{{name: test.entry, content: content}}
+		if name, compression := splitCompression(test.archive); strings.HasSuffix(name, ".zip") {
+			writeZip(t, path, entries)
+		} else {
+			writeTar(t, path, compression, entries)
+		}
+
+		sources := expandSources(context.Background(), []string{path}, filepath.Join(dir, "tmp"), nil)
+		if len(sources) != 1 || sources[0].err != nil {
+			t.Errorf("%s/%s: sources %+v, want one", test.archive, test.entry, sources)
+			continue
+		}
+		if sources[0].table != test.table {
+			t.Errorf("%s/%s: table %s, want %s", test.archive, test.entry, sources[0].table, test.table)
+		}
+	}
+}
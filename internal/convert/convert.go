@@ -0,0 +1,542 @@
+// Package convert converts the source files of the data directory, CSV, TSV, JSON, Excel
+// or fixed-width, to Parquet files with DuckDB, one file per table.
+package convert
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/dictionary"
+	"the-modern-data-stack/internal/files"
+	"the-modern-data-stack/internal/lineage"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/project"
+	"the-modern-data-stack/internal/report"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// kvMetadataOption builds the KV_METADATA option of a Parquet COPY holding the lineage
+// entries and the data dictionary entries of a table, or returns an empty string when
+// there is none
+func kvMetadataOption(lineage map[string]string, entries []dictionary.Entry) string {
+	metadata := make(map[string]string)
+	for key, value := range lineage {
+		metadata[key] = value
+	}
+	for _, entry := range entries {
+		for key, value := range entry.Metadata() {
+			metadata[key] = value
+		}
+	}
+	if len(metadata) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s: %s", sqlutil.QuoteString(key), sqlutil.QuoteString(metadata[key]))
+	}
+	return fmt.Sprintf(", KV_METADATA {%s}", strings.Join(pairs, ", "))
+}
+
+// readCSVOptions builds the options of read_csv_auto set by the project config of a table,
+// each starting with a comma, leaving the others to detection
+func readCSVOptions(options project.CSVOptions) string {
+	var clauses []string
+	if options.Delimiter != "" {
+		clauses = append(clauses, "delim = "+sqlutil.QuoteString(options.Delimiter))
+	}
+	if options.Quote != "" {
+		clauses = append(clauses, "quote = "+sqlutil.QuoteString(options.Quote))
+	}
+	if options.Header != nil {
+		clauses = append(clauses, fmt.Sprintf("header = %t", *options.Header))
+	}
+	if options.NullString != "" {
+		clauses = append(clauses, "nullstr = "+sqlutil.QuoteString(options.NullString))
+	}
+	if options.DateFormat != "" {
+		clauses = append(clauses, "dateformat = "+sqlutil.QuoteString(options.DateFormat))
+	}
+	if options.TimestampFormat != "" {
+		clauses = append(clauses, "timestampformat = "+sqlutil.QuoteString(options.TimestampFormat))
+	}
+	if options.Skip > 0 {
+		clauses = append(clauses, fmt.Sprintf("skip = %d", options.Skip))
+	}
+	if len(clauses) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(clauses, ", ")
+}
+
+// readSchema returns the columns a reader of a source file yields, with the types DuckDB
+// infers from a sample of the file, which become the Parquet column types
+func readSchema(ctx context.Context, db *sql.DB, reader string) ([]report.Column, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("DESCRIBE SELECT * FROM %s", reader))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columnNames, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var columns []report.Column
+	values := make([]interface{}, len(columnNames))
+	for rows.Next() {
+		var column report.Column
+		// The first two columns of DESCRIBE are the name and type of each column
+		values[0], values[1] = &column.Name, &column.Type
+		for i := 2; i < len(values); i++ {
+			values[i] = new(interface{})
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// printSchema prints the inferred columns of a table
+func printSchema(out io.Writer, columns []report.Column) {
+	fmt.Fprintln(out, "📋 Inferred schema:")
+	for _, column := range columns {
+		fmt.Fprintf(out, "   - %s: %s\n", column.Name, column.Type)
+	}
+}
+
+// Run converts the source files, as the convert command
+func Run(ctx context.Context, args []string) (err error) {
+	fs := cli.NewFlagSet("convert")
+	dataDir := fs.String("source-dir", "data", "Directory searched recursively for CSV, TSV, JSON, Excel and fixed-width files")
+	parquetDir := fs.String("output-dir", "data/parquet", "Directory the Parquet files are written to")
+	warehouseDir := fs.String("warehouse-dir", "data/iceberg_warehouse", "Local directory holding the warehouse, not searched for source files")
+	dictionaryPath := fs.String("dictionary", "data_dictionary.csv", "Data dictionary (.csv or .yaml) written into the Parquet key-value metadata (skipped when missing)")
+	dryRun := fs.Bool("dry-run", false, "Infer the schemas and print the files that would be written, without creating or overwriting anything")
+	timeout := fs.Duration("timeout", 0, "Stop the conversion after this duration (default: no timeout)")
+	var parquetOptions project.ParquetOptions
+	fs.StringVar(&parquetOptions.Compression, "compression", "", "Compression codec of the Parquet files: "+strings.Join(parquetCompressions, ", ")+" (default: snappy)")
+	fs.IntVar(&parquetOptions.CompressionLevel, "compression-level", 0, "Compression level of zstd, from 1 to 22")
+	fs.IntVar(&parquetOptions.RowGroupSize, "row-group-size", 0, "Rows per row group of the Parquet files (default: DuckDB's, 122880)")
+	fs.StringVar(&parquetOptions.FileSize, "file-size", "", "Split the Parquet output of each table into files of about this size, such as 256MB, in a directory named after the table")
+	fs.StringVar(&parquetOptions.Version, "parquet-version", "", "Parquet format version: v1 or v2 (needs DuckDB 1.2 or later)")
+	var duckDBSettings project.DuckDBSettings
+	fs.StringVar(&duckDBSettings.MemoryLimit, "memory-limit", "", "Memory DuckDB may use, such as 4GB, beyond which it spills to the temporary directory (default: 80% of the RAM)")
+	fs.StringVar(&duckDBSettings.TempDirectory, "temp-directory", "", "Directory DuckDB spills to beyond its memory limit (default: DuckDB's)")
+	output := report.AddFlags(fs)
+	project.AddFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return cli.Usagef("unexpected arguments %s", strings.Join(fs.Args(), " "))
+	}
+	projectConfig, err := project.Apply(fs)
+	if err != nil {
+		return err
+	}
+	if _, err := parquetCopyOptions("", parquetOptions, nil); err != nil {
+		return cli.Usagef("invalid Parquet options: %v", err)
+	}
+	rep, err := output.Start("convert", *dryRun)
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.Close(err) }()
+	out := rep.Out
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	dict, err := dictionary.Load(*dictionaryPath)
+	if err != nil {
+		return err
+	}
+
+	// Connect to DuckDB (in-memory database)
+	db, err := logging.OpenDB("duckdb", ":memory:")
+	if err != nil {
+		return fmt.Errorf("failed to connect to DuckDB: %w", err)
+	}
+	defer db.Close()
+
+	// Test the connection
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping DuckDB: %w", err)
+	}
+
+	// Bound the memory of DuckDB, which spills the rest of large files to disk
+	if duckDBSettings.MemoryLimit != "" {
+		if _, err := db.ExecContext(ctx, "SET memory_limit = "+sqlutil.QuoteString(duckDBSettings.MemoryLimit)); err != nil {
+			return cli.Usagef("invalid --memory-limit %q: %v", duckDBSettings.MemoryLimit, err)
+		}
+	}
+	if duckDBSettings.TempDirectory != "" {
+		if _, err := db.ExecContext(ctx, "SET temp_directory = "+sqlutil.QuoteString(duckDBSettings.TempDirectory)); err != nil {
+			return fmt.Errorf("failed to set the temporary directory of DuckDB: %w", err)
+		}
+	}
+
+	fmt.Fprintln(out, "✅ Connected to DuckDB successfully")
+
+	// The files of the run share its ID in their lineage metadata
+	runID, toolVersion := lineage.NewRunID(), lineage.Version()
+	fmt.Fprintf(out, "🏷️  Run %s (mds %s)\n", runID, toolVersion)
+	if *dryRun {
+		fmt.Fprintln(out, "🔍 Dry run: no directory or file is created")
+	}
+
+	// No extensions needed for Parquet conversion
+	fmt.Fprintln(out, "🔧 Ready for Parquet conversion...")
+
+	// Check if data directory exists and has source files
+	if _, err := os.Stat(*dataDir); os.IsNotExist(err) && *dryRun {
+		fmt.Fprintf(out, "⚠️  Data directory '%s' does not exist and would be created\n", *dataDir)
+		return nil
+	} else if os.IsNotExist(err) {
+		fmt.Fprintf(out, "⚠️  Data directory '%s' does not exist. Creating it...\n", *dataDir)
+		if err := os.MkdirAll(*dataDir, 0755); err != nil {
+			return fmt.Errorf("failed to create data directory: %w", err)
+		}
+		fmt.Fprintf(out, "✅ Created data directory '%s'\n", *dataDir)
+		fmt.Fprintln(out, "📁 Please place your source files in the 'data' directory")
+		return nil
+	}
+
+	// Find all source files in the data directory
+	// The output and the warehouse are often inside the data directory
+	sourceFiles, err := findSources(*dataDir, []string{*parquetDir, *warehouseDir, duckDBSettings.TempDirectory}, projectConfig.Tables)
+	if err != nil {
+		return fmt.Errorf("failed to search for source files: %w", err)
+	}
+
+	if len(sourceFiles) == 0 {
+		fmt.Fprintf(out, "⚠️  No source files found in '%s' directory\n", *dataDir)
+		fmt.Fprintln(out, "📁 Please place your source files in the 'data' directory")
+		return nil
+	}
+
+	fmt.Fprintf(out, "📊 Found %d source file(s):\n", len(sourceFiles))
+	for _, file := range sourceFiles {
+		relPath, _ := filepath.Rel(*dataDir, file)
+		fmt.Fprintf(out, "   - %s\n", relPath)
+	}
+
+	// Decompress the compressed files and extract the archives in a temporary directory
+	tempDir, err := os.MkdirTemp("", "mds-sources-*")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+	sources := expandSources(ctx, sourceFiles, tempDir, projectConfig.Tables)
+
+	// Create Parquet output directory
+	if *dryRun {
+		if _, err := os.Stat(*parquetDir); os.IsNotExist(err) {
+			fmt.Fprintf(out, "📁 Would create directory %s\n", *parquetDir)
+		}
+	} else if err := os.MkdirAll(*parquetDir, 0755); err != nil {
+		return fmt.Errorf("failed to create Parquet directory: %w", err)
+	}
+	var planned []string
+
+	// Process each source file
+	for _, src := range sources {
+		// Once interrupted or timed out, the files left are not attempted
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(*dataDir, src.origin)
+		tableName := src.table
+		options := projectConfig.Tables[tableName]
+		if options.Layout == "" {
+			options.Layout = src.layout
+		}
+		tableParquetOptions := mergeParquetOptions(parquetOptions, options.Parquet)
+
+		// A table split into files of a maximum size or partitioned is a directory of Parquet files
+		splitOutput := splitsOutput(tableParquetOptions, options.PartitionBy)
+		parquetPath := filepath.Join(*parquetDir, tableName+".parquet")
+		otherPath := filepath.Join(*parquetDir, tableName)
+		if splitOutput {
+			parquetPath, otherPath = otherPath, parquetPath
+		}
+		file := rep.Add(src.origin, parquetPath)
+		logger := slog.With("file", relPath, "table", tableName)
+
+		fmt.Fprintf(out, "\n🔄 Processing %s -> table '%s'...\n", relPath, tableName)
+		if src.err != nil {
+			logger.Error("failed to read the source file", "error", src.err)
+			file.Fail(src.err)
+			continue
+		}
+		copyOptions, err := parquetCopyOptions(tableName, tableParquetOptions, options.PartitionBy)
+		if err != nil {
+			logger.Error("invalid Parquet options", "error", err)
+			file.Fail(fmt.Errorf("invalid Parquet options: %w", err))
+			continue
+		}
+
+		// Get absolute path for the source file
+		absSourcePath, err := filepath.Abs(src.path)
+		if err != nil {
+			logger.Error("failed to get the absolute path of the source file", "error", err)
+			file.Fail(err)
+			continue
+		}
+
+		// Read the source file with the reader of its format, streamed into the Parquet COPY
+		// rather than loaded into a table first
+		reader, cleanup, err := sourceReader(ctx, db, absSourcePath, options)
+		if err != nil {
+			logger.Error("failed to read the source file", "error", err)
+			file.Fail(err)
+			continue
+		}
+
+		if file.Schema, err = readSchema(ctx, db, reader); err != nil {
+			cleanup()
+			logger.Error("failed to read the schema", "error", err)
+			file.Fail(fmt.Errorf("failed to read the source file: %w", err))
+			continue
+		}
+
+		// Create Parquet table path
+		absParquetPath, err := filepath.Abs(parquetPath)
+		if err != nil {
+			cleanup()
+			logger.Error("failed to get the absolute path of the Parquet file", "error", err)
+			file.Fail(err)
+			continue
+		}
+
+		// Create Parquet table
+		if !*dryRun {
+			fmt.Fprintf(out, "📦 Creating Parquet table at %s...\n", parquetPath)
+		}
+
+		// Document the columns of the data dictionary that the source file has
+		columnNames := make(map[string]bool)
+		for _, column := range file.Schema {
+			columnNames[column.Name] = true
+		}
+		var documented []dictionary.Entry
+		for _, entry := range dict.Table(tableName) {
+			if !columnNames[entry.Column] {
+				logger.Warn("the data dictionary documents a column the file does not have", "column", entry.Column)
+				continue
+			}
+			documented = append(documented, entry)
+		}
+		if len(documented) > 0 {
+			fmt.Fprintf(out, "📖 Documenting %d column(s) from %s\n", len(documented), *dictionaryPath)
+		}
+
+		if *dryRun {
+			// Counting streams the file too, without writing anything
+			var rowCount int64
+			err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s", reader)).Scan(&rowCount)
+			cleanup()
+			if err != nil {
+				logger.Error("failed to count the rows", "error", err)
+				file.Fail(fmt.Errorf("failed to count the rows: %w", err))
+				continue
+			}
+			file.Rows = rowCount
+			printSchema(out, file.Schema)
+			action := "create"
+			if _, err := os.Stat(parquetPath); err == nil {
+				action = "overwrite"
+			}
+			fmt.Fprintf(out, "📝 Would %s %s (%d rows)\n", action, parquetPath, rowCount)
+			planned = append(planned, parquetPath)
+			file.Finish(report.StatusPlanned)
+			continue
+		}
+
+		// Record where the data comes from; an archive entry is hashed as extracted
+		hashedPath := src.origin
+		if src.entry {
+			hashedPath = src.path
+		}
+		sourceHash, err := lineage.HashFile(hashedPath)
+		if err != nil {
+			cleanup()
+			logger.Error("failed to hash the source file", "error", err)
+			file.Fail(fmt.Errorf("failed to hash the source file: %w", err))
+			continue
+		}
+		format, _ := sourceFormat(absSourcePath, options)
+		entries := map[string]string{
+			lineage.KeySourcePath:   src.origin,
+			lineage.KeySourceSHA256: sourceHash,
+			lineage.KeySourceFormat: format,
+			lineage.KeyIngestedAt:   time.Now().UTC().Format(time.RFC3339),
+			lineage.KeyRunID:        runID,
+			lineage.KeyToolVersion:  toolVersion,
+		}
+		dialect, err := csvDialect(ctx, db, absSourcePath, format, options.CSV)
+		if err != nil {
+			logger.Warn("failed to record the CSV dialect", "error", err)
+		} else if dialect != "" {
+			entries[lineage.KeyCSVDialect] = dialect
+		}
+
+		// Copy data to Parquet format, through a temporary file so that an interrupted copy
+		// leaves the previous Parquet file, if any, untouched
+		err = files.WriteAtomic(absParquetPath, func(tempPath string) error {
+			copyToParquetSQL := fmt.Sprintf(`
+				COPY (SELECT * FROM %s) TO %s (FORMAT 'parquet'%s%s)
+			`, reader, sqlutil.QuoteString(tempPath), copyOptions, kvMetadataOption(entries, documented))
+			_, err := db.ExecContext(ctx, copyToParquetSQL)
+			return err
+		})
+		cleanup()
+		if err != nil {
+			logger.Error("failed to write the Parquet file", "target", parquetPath, "error", err)
+			file.Fail(fmt.Errorf("failed to write the Parquet file: %w", err))
+			continue
+		}
+		file.Bytes = pathSize(parquetPath)
+
+		// The previous output of the table in the other layout would be a second table
+		if info, err := os.Stat(otherPath); err == nil && info.IsDir() != splitOutput {
+			if err := os.RemoveAll(otherPath); err != nil {
+				logger.Warn("failed to remove the previous output of the table", "path", otherPath, "error", err)
+			}
+		}
+
+		// Count the rows from the Parquet metadata rather than by reading the data again
+		written := sqlutil.QuoteString(absParquetPath)
+		if splitOutput {
+			written = sqlutil.QuoteString(filepath.Join(absParquetPath, "**", "*.parquet"))
+		}
+		if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT coalesce(sum(num_rows), 0) FROM parquet_file_metadata(%s)", written)).Scan(&file.Rows); err != nil {
+			logger.Warn("failed to read the row count from the Parquet metadata", "error", err)
+		}
+		logger = logger.With("rows", file.Rows)
+
+		fmt.Fprintf(out, "📈 Wrote %d rows from %s\n", file.Rows, relPath)
+		fmt.Fprintf(out, "✅ Created Parquet table: %s\n", parquetPath)
+
+		// Show sample data, read back from the Parquet output
+		fmt.Fprintf(out, "📋 Sample data from %s:\n", tableName)
+		fmt.Fprintln(out, "="+strings.Repeat("=", 50))
+
+		sampleSQL := fmt.Sprintf("SELECT * FROM read_parquet(%s, hive_partitioning = false) LIMIT 3", written)
+		rows, err := db.QueryContext(ctx, sampleSQL)
+		if err != nil {
+			logger.Warn("failed to query sample data", "error", err)
+		} else {
+			// Get column names
+			columns, err := rows.Columns()
+			if err != nil {
+				logger.Warn("failed to get the columns of the sample data", "error", err)
+			} else {
+				// Print header
+				for i, col := range columns {
+					if i > 0 {
+						fmt.Fprint(out, " | ")
+					}
+					fmt.Fprintf(out, "%-15s", col)
+				}
+				fmt.Fprintln(out)
+				fmt.Fprintln(out, strings.Repeat("-", len(columns)*18))
+
+				// Print sample data
+				values := make([]interface{}, len(columns))
+				valuePtrs := make([]interface{}, len(columns))
+				for i := range values {
+					valuePtrs[i] = &values[i]
+				}
+
+				sampleCount := 0
+				for rows.Next() && sampleCount < 3 {
+					err := rows.Scan(valuePtrs...)
+					if err != nil {
+						logger.Warn("failed to scan a sample row", "error", err)
+						continue
+					}
+
+					for i, val := range values {
+						if i > 0 {
+							fmt.Fprint(out, " | ")
+						}
+						if val == nil {
+							fmt.Fprintf(out, "%-15s", "NULL")
+						} else {
+							fmt.Fprintf(out, "%-15v", val)
+						}
+					}
+					fmt.Fprintln(out)
+					sampleCount++
+				}
+			}
+			rows.Close()
+		}
+
+		file.Finish(report.StatusOK)
+		logger.Debug("converted", "target", parquetPath, "bytes", file.Bytes, "duration_ms", file.DurationMs)
+
+		fmt.Fprintln(out)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Fprintf(out, "\n🔍 Dry run complete: %d Parquet file(s) would be written:\n", len(planned))
+		for _, path := range planned {
+			fmt.Fprintf(out, "   • %s\n", path)
+		}
+		return nil
+	}
+
+	failed := 0
+	for _, file := range rep.Files {
+		if file.Status == report.StatusFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "⚠️  %d of %d source file(s) failed, see the errors above\n", failed, len(sources))
+	} else {
+		fmt.Fprintln(out, "🎉 All source files processed successfully!")
+	}
+	fmt.Fprintf(out, "📁 Parquet tables created in: %s\n", *parquetDir)
+
+	// Show summary
+	fmt.Fprintln(out, "\n📊 Summary:")
+	fmt.Fprintf(out, "   - Input directory: %s\n", *dataDir)
+	fmt.Fprintf(out, "   - Output directory: %s\n", *parquetDir)
+	fmt.Fprintf(out, "   - Source files processed: %d\n", len(sources)-failed)
+
+	// List created files
+	if entries, err := os.ReadDir(*parquetDir); err == nil {
+		fmt.Fprintln(out, "   - Created files:")
+		for _, file := range entries {
+			fmt.Fprintf(out, "     • %s\n", file.Name())
+		}
+	}
+	return nil
+}
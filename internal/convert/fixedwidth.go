@@ -0,0 +1,121 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fixedWidthLayout describes the columns of a fixed-width file, such as a mainframe
+// extract:
+//
+//	skip: 1
+//	columns:
+//	  - {name: id, start: 1, width: 6, type: INTEGER}
+//	  - {name: label, start: 7, width: 20}
+type fixedWidthLayout struct {
+	Skip    int                `yaml:"skip"` // lines skipped at the top of the file, such as a header
+	Columns []fixedWidthColumn `yaml:"columns"`
+}
+
+// fixedWidthColumn is a column of a fixed-width file. Positions count characters, not
+// bytes, from 1.
+type fixedWidthColumn struct {
+	Name  string `yaml:"name"`
+	Start int    `yaml:"start"`
+	Width int    `yaml:"width"`
+	Type  string `yaml:"type"` // DuckDB type of the column, detected when empty
+}
+
+// loadLayout reads and checks the layout file of a fixed-width file
+func loadLayout(path string) (*fixedWidthLayout, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the layout file: %v", err)
+	}
+	defer file.Close()
+
+	layout := &fixedWidthLayout{}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(layout); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid layout file %s: %v", path, err)
+	}
+
+	if len(layout.Columns) == 0 {
+		return nil, fmt.Errorf("invalid layout file %s: no columns", path)
+	}
+	names := make(map[string]bool)
+	for i, column := range layout.Columns {
+		switch {
+		case column.Name == "":
+			return nil, fmt.Errorf("invalid layout file %s: column %d has no name", path, i+1)
+		case names[column.Name]:
+			return nil, fmt.Errorf("invalid layout file %s: duplicate column %s", path, column.Name)
+		case column.Start < 1 || column.Width < 1:
+			return nil, fmt.Errorf("invalid layout file %s: column %s needs a start and a width of at least 1", path, column.Name)
+		}
+		names[column.Name] = true
+	}
+	return layout, nil
+}
+
+// field returns the trimmed value of a column in a line, empty when the line is shorter
+func (c fixedWidthColumn) field(line []rune) string {
+	start := c.Start - 1
+	if start >= len(line) {
+		return ""
+	}
+	end := min(start+c.Width, len(line))
+	return strings.TrimSpace(string(line[start:end]))
+}
+
+// writeFixedWidthCSV parses a fixed-width file with its layout and writes its rows to a
+// CSV file with a header, which DuckDB then reads. Blank lines are skipped and empty
+// fields become NULL.
+func writeFixedWidthCSV(layout *fixedWidthLayout, path string, out io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(out)
+	record := make([]string, len(layout.Columns))
+	for i, column := range layout.Columns {
+		record[i] = column.Name
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		if number <= layout.Skip {
+			continue
+		}
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		line := []rune(text)
+		for i, column := range layout.Columns {
+			record[i] = column.field(line)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	writer.Flush()
+	return writer.Error()
+}
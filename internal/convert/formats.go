@@ -0,0 +1,169 @@
+package convert
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"the-modern-data-stack/internal/project"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// Input formats of the source files
+const (
+	formatCSV        = "csv"
+	formatTSV        = "tsv"
+	formatJSON       = "json"
+	formatExcel      = "excel"
+	formatFixedWidth = "fixed-width"
+)
+
+// formatExtensions are the formats of the source files by extension; the project config
+// of a table can give the format of files with another extension
+var formatExtensions = map[string]string{
+	".csv":    formatCSV,
+	".tsv":    formatTSV,
+	".json":   formatJSON,
+	".ndjson": formatJSON,
+	".jsonl":  formatJSON,
+	".xlsx":   formatExcel,
+	".fwf":    formatFixedWidth,
+}
+
+// sourceFormat returns the format of a source file, from the project config of its table
+// or its extension
+func sourceFormat(path string, options project.TableOptions) (string, error) {
+	if options.Format != "" {
+		switch options.Format {
+		case formatCSV, formatTSV, formatJSON, formatExcel, formatFixedWidth:
+			return options.Format, nil
+		}
+		return "", fmt.Errorf("unknown format %q, expected %s, %s, %s, %s or %s", options.Format, formatCSV, formatTSV, formatJSON, formatExcel, formatFixedWidth)
+	}
+	if format, ok := formatExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format of %s", path)
+}
+
+// sourceReader returns the DuckDB table function reading a source file, such as
+// read_csv_auto('/data/x.csv'), and a function removing the temporary files it needs once
+// the data is read. The layout file of a fixed-width file is options.Layout.
+func sourceReader(ctx context.Context, db *sql.DB, path string, options project.TableOptions) (string, func(), error) {
+	noCleanup := func() {}
+
+	format, err := sourceFormat(path, options)
+	if err != nil {
+		return "", noCleanup, err
+	}
+
+	switch format {
+	case formatCSV:
+		return fmt.Sprintf("read_csv_auto(%s%s)", sqlutil.QuoteString(path), readCSVOptions(options.CSV)), noCleanup, nil
+
+	case formatTSV:
+		if options.CSV.Delimiter == "" {
+			options.CSV.Delimiter = "\t"
+		}
+		return fmt.Sprintf("read_csv_auto(%s%s)", sqlutil.QuoteString(path), readCSVOptions(options.CSV)), noCleanup, nil
+
+	case formatJSON:
+		// Detects both JSON arrays and newline-delimited JSON
+		return fmt.Sprintf("read_json_auto(%s)", sqlutil.QuoteString(path)), noCleanup, nil
+
+	case formatExcel:
+		// Spreadsheets are read by the GDAL driver of the spatial extension
+		if _, err := db.ExecContext(ctx, "INSTALL spatial; LOAD spatial"); err != nil {
+			return "", noCleanup, fmt.Errorf("failed to load the spatial extension reading Excel files: %w", err)
+		}
+		var sheet string
+		if options.Sheet != "" {
+			sheet = ", layer = " + sqlutil.QuoteString(options.Sheet)
+		}
+		return fmt.Sprintf("st_read(%s%s, open_options = ['HEADERS=FORCE', 'FIELD_TYPES=AUTO'])", sqlutil.QuoteString(path), sheet), noCleanup, nil
+
+	case formatFixedWidth:
+		layout, err := loadLayout(options.Layout)
+		if err != nil {
+			return "", noCleanup, err
+		}
+		parsed, err := os.CreateTemp("", "mds-fixed-width-*.csv")
+		if err != nil {
+			return "", noCleanup, err
+		}
+		cleanup := func() { os.Remove(parsed.Name()) }
+		err = writeFixedWidthCSV(layout, path, parsed)
+		if closeErr := parsed.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cleanup()
+			return "", noCleanup, fmt.Errorf("failed to parse the fixed-width file: %w", err)
+		}
+		return fmt.Sprintf("read_csv_auto(%s, header = true%s)", sqlutil.QuoteString(parsed.Name()), columnTypesOption(layout)), cleanup, nil
+	}
+	return "", noCleanup, fmt.Errorf("unknown format %q", format)
+}
+
+// csvDialect returns the dialect DuckDB reads a CSV or TSV file with, the options of the
+// project config and those it detects, as JSON; it returns an empty string for the other
+// formats
+func csvDialect(ctx context.Context, db *sql.DB, path, format string, options project.CSVOptions) (string, error) {
+	switch format {
+	case formatCSV:
+	case formatTSV:
+		if options.Delimiter == "" {
+			options.Delimiter = "\t"
+		}
+	default:
+		return "", nil
+	}
+
+	var dialect struct {
+		Delimiter       string `json:"delimiter"`
+		Quote           string `json:"quote"`
+		Escape          string `json:"escape"`
+		NewLine         string `json:"newline"`
+		Skip            int64  `json:"skip"`
+		Header          bool   `json:"header"`
+		NullString      string `json:"null_string,omitempty"`
+		DateFormat      string `json:"date_format,omitempty"`
+		TimestampFormat string `json:"timestamp_format,omitempty"`
+	}
+	var dateFormat, timestampFormat sql.NullString
+	err := db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT Delimiter, Quote, Escape, NewLineDelimiter, SkipRows, HasHeader, DateFormat, TimestampFormat FROM sniff_csv(%s%s)",
+		sqlutil.QuoteString(path), readCSVOptions(options))).Scan(
+		&dialect.Delimiter, &dialect.Quote, &dialect.Escape, &dialect.NewLine, &dialect.Skip, &dialect.Header, &dateFormat, &timestampFormat)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the CSV dialect: %w", err)
+	}
+	dialect.NullString = options.NullString
+	dialect.DateFormat = dateFormat.String
+	dialect.TimestampFormat = timestampFormat.String
+
+	encoded, err := json.Marshal(dialect)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// columnTypesOption builds the types option of read_csv holding the column types of a
+// fixed-width layout, or returns an empty string when all of them are detected
+func columnTypesOption(layout *fixedWidthLayout) string {
+	var pairs []string
+	for _, column := range layout.Columns {
+		if column.Type != "" {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", sqlutil.QuoteString(column.Name), sqlutil.QuoteString(column.Type)))
+		}
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", types = {%s}", strings.Join(pairs, ", "))
+}
@@ -0,0 +1,115 @@
+package convert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"the-modern-data-stack/internal/project"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// parquetCompressions are the codecs DuckDB writes Parquet files with
+var parquetCompressions = []string{"snappy", "zstd", "gzip", "lz4", "lz4_raw", "brotli", "uncompressed"}
+
+// mergeParquetOptions returns the Parquet options of a table: those of its project config,
+// and the global ones for the options it leaves empty
+func mergeParquetOptions(global, table project.ParquetOptions) project.ParquetOptions {
+	if table.Compression == "" {
+		table.Compression = global.Compression
+	}
+	if table.CompressionLevel == 0 {
+		table.CompressionLevel = global.CompressionLevel
+	}
+	if table.RowGroupSize == 0 {
+		table.RowGroupSize = global.RowGroupSize
+	}
+	if table.FileSize == "" {
+		table.FileSize = global.FileSize
+	}
+	if table.Version == "" {
+		table.Version = global.Version
+	}
+	return table
+}
+
+// splitsOutput reports whether the Parquet output of a table is a directory of files rather
+// than a single file: when it is split by size or partitioned
+func splitsOutput(options project.ParquetOptions, partitionBy []string) bool {
+	return options.FileSize != "" || len(partitionBy) > 0
+}
+
+// parquetCopyOptions builds the options of the Parquet COPY of a table, each starting with
+// a comma. With a file size or partition columns, DuckDB writes a directory of files named
+// after the table; partitions are Hive-style directories, such as annee=2023/, whose files
+// still hold the partition columns so that they keep their types.
+func parquetCopyOptions(tableName string, options project.ParquetOptions, partitionBy []string) (string, error) {
+	var clauses []string
+
+	if options.Compression != "" {
+		compression := strings.ToLower(options.Compression)
+		known := false
+		for _, name := range parquetCompressions {
+			known = known || name == compression
+		}
+		if !known {
+			return "", fmt.Errorf("unknown compression %q, expected %s", options.Compression, strings.Join(parquetCompressions, ", "))
+		}
+		clauses = append(clauses, "COMPRESSION "+compression)
+	}
+	if options.CompressionLevel != 0 {
+		if !strings.EqualFold(options.Compression, "zstd") {
+			return "", fmt.Errorf("a compression level needs the zstd compression")
+		}
+		if options.CompressionLevel < 1 || options.CompressionLevel > 22 {
+			return "", fmt.Errorf("invalid zstd compression level %d, expected 1 to 22", options.CompressionLevel)
+		}
+		clauses = append(clauses, fmt.Sprintf("COMPRESSION_LEVEL %d", options.CompressionLevel))
+	}
+	if options.RowGroupSize < 0 {
+		return "", fmt.Errorf("invalid row group size %d", options.RowGroupSize)
+	} else if options.RowGroupSize > 0 {
+		clauses = append(clauses, fmt.Sprintf("ROW_GROUP_SIZE %d", options.RowGroupSize))
+	}
+	if options.FileSize != "" {
+		clauses = append(clauses, "FILE_SIZE_BYTES "+sqlutil.QuoteString(options.FileSize))
+	}
+	if len(partitionBy) > 0 {
+		columns := make([]string, len(partitionBy))
+		for i, column := range partitionBy {
+			if column == "" {
+				return "", fmt.Errorf("empty partition column")
+			}
+			columns[i] = sqlutil.QuoteIdentifier(column)
+		}
+		clauses = append(clauses, "PARTITION_BY ("+strings.Join(columns, ", ")+")", "WRITE_PARTITION_COLUMNS true")
+	}
+	if splitsOutput(options, partitionBy) {
+		clauses = append(clauses, "FILENAME_PATTERN "+sqlutil.QuoteString(tableName+"_{i}"))
+	}
+	switch strings.ToLower(options.Version) {
+	case "":
+	case "v1", "v2":
+		clauses = append(clauses, "PARQUET_VERSION "+strings.ToUpper(options.Version))
+	default:
+		return "", fmt.Errorf("unknown Parquet version %q, expected v1 or v2", options.Version)
+	}
+
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return ", " + strings.Join(clauses, ", "), nil
+}
+
+// pathSize returns the size of a file, or of the files of a directory
+func pathSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
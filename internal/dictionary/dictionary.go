@@ -0,0 +1,170 @@
+// Package dictionary reads the data dictionary documenting the columns of the tables,
+// either as a CSV file or as a YAML list with the same fields.
+package dictionary
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Entry documents one column of a table
+type Entry struct {
+	Table       string `yaml:"table"`
+	Column      string `yaml:"column"`
+	Description string `yaml:"description"`
+	Unit        string `yaml:"unit"`
+	Source      string `yaml:"source"`
+}
+
+// Doc returns the column documentation as a single string, such as
+// "Sale price (unit: EUR, source: DVF)"
+func (e Entry) Doc() string {
+	var details []string
+	if e.Unit != "" {
+		details = append(details, "unit: "+e.Unit)
+	}
+	if e.Source != "" {
+		details = append(details, "source: "+e.Source)
+	}
+	if len(details) == 0 {
+		return e.Description
+	}
+	if e.Description == "" {
+		return strings.Join(details, ", ")
+	}
+	return fmt.Sprintf("%s (%s)", e.Description, strings.Join(details, ", "))
+}
+
+// Metadata returns the entry as Parquet key-value metadata, one
+// dictionary.<column>.<field> key per non-empty field
+func (e Entry) Metadata() map[string]string {
+	metadata := make(map[string]string)
+	for field, value := range map[string]string{"description": e.Description, "unit": e.Unit, "source": e.Source} {
+		if value != "" {
+			metadata[fmt.Sprintf("dictionary.%s.%s", e.Column, field)] = value
+		}
+	}
+	return metadata
+}
+
+// Dictionary holds the documented columns, by table and column name
+type Dictionary struct {
+	tables map[string]map[string]Entry
+}
+
+// Load reads a data dictionary from a .csv, .yaml or .yml file. A missing file
+// yields an empty dictionary.
+func Load(path string) (*Dictionary, error) {
+	d := &Dictionary{tables: make(map[string]map[string]Entry)}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = readCSV(file)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		if err = decoder.Decode(&entries); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		err = fmt.Errorf("unsupported format, expected .csv, .yaml or .yml")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid data dictionary %s: %v", path, err)
+	}
+
+	for i, entry := range entries {
+		if entry.Table == "" || entry.Column == "" {
+			return nil, fmt.Errorf("invalid data dictionary %s: entry %d has no table or column", path, i+1)
+		}
+		if d.tables[entry.Table] == nil {
+			d.tables[entry.Table] = make(map[string]Entry)
+		}
+		if _, ok := d.tables[entry.Table][entry.Column]; ok {
+			return nil, fmt.Errorf("invalid data dictionary %s: %s.%s is documented twice", path, entry.Table, entry.Column)
+		}
+		d.tables[entry.Table][entry.Column] = entry
+	}
+	return d, nil
+}
+
+// readCSV reads entries from a CSV file whose header names the entry fields
+func readCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"table", "column"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the header has no %s column", required)
+		}
+	}
+	get := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var entries []Entry
+	for _, record := range records[1:] {
+		entries = append(entries, Entry{
+			Table:       get(record, "table"),
+			Column:      get(record, "column"),
+			Description: get(record, "description"),
+			Unit:        get(record, "unit"),
+			Source:      get(record, "source"),
+		})
+	}
+	return entries, nil
+}
+
+// Column returns the entry documenting a column of a table
+func (d *Dictionary) Column(table, column string) (Entry, bool) {
+	entry, ok := d.tables[table][column]
+	return entry, ok
+}
+
+// Table returns the entries of a table sorted by column name
+func (d *Dictionary) Table(table string) []Entry {
+	var entries []Entry
+	for _, entry := range d.tables[table] {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Column < entries[j].Column })
+	return entries
+}
+
+// Tables returns the names of the documented tables in alphabetical order
+func (d *Dictionary) Tables() []string {
+	var tables []string
+	for table := range d.tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
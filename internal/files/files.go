@@ -0,0 +1,155 @@
+// Package files discovers the data files of the pipeline and derives table names from them.
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Find recursively finds the files of a directory with the given extension, such as
+// ".csv", whatever its case
+func Find(rootDir, extension string) ([]string, error) {
+	return FindFunc(rootDir, func(path string) bool {
+		return strings.EqualFold(filepath.Ext(path), extension)
+	})
+}
+
+// FindFunc recursively finds the files of a directory for which match returns true
+func FindFunc(rootDir string, match func(path string) bool) ([]string, error) {
+	return FindFuncSkipping(rootDir, nil, match)
+}
+
+// FindFuncSkipping recursively finds the files of a directory for which match returns
+// true, without searching the subdirectories for which skip returns true
+func FindFuncSkipping(rootDir string, skip, match func(path string) bool) ([]string, error) {
+	var found []string
+
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != rootDir && skip != nil && skip(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if match(path) {
+			found = append(found, path)
+		}
+		return nil
+	})
+
+	return found, err
+}
+
+// TableName creates a valid table name from a file path
+func TableName(filePath string) string {
+	// Get filename without extension
+	filename := filepath.Base(filePath)
+	return sanitize(strings.TrimSuffix(filename, filepath.Ext(filename)))
+}
+
+// sanitize replaces the characters of a name that are not valid in a table name
+func sanitize(tableName string) string {
+	// Replace special characters with underscores
+	tableName = strings.ReplaceAll(tableName, "-", "_")
+	tableName = strings.ReplaceAll(tableName, " ", "_")
+	tableName = strings.ReplaceAll(tableName, ".", "_")
+
+	return tableName
+}
+
+// WriteAtomic creates or replaces a file through write, which writes the temporary path
+// it is given next to the file. The temporary file is renamed into place once complete,
+// so that a failed or interrupted write leaves no partial file behind. write may also
+// create a directory, which replaces the previous one once complete: the previous one is
+// moved aside, and only removed once the new one is in place.
+func WriteAtomic(path string, write func(tempPath string) error) error {
+	tempPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	os.RemoveAll(tempPath)
+	if err := write(tempPath); err != nil {
+		os.RemoveAll(tempPath)
+		return err
+	}
+
+	// A directory cannot be renamed over another one, nor over a file
+	if !isDir(tempPath) && !isDir(path) {
+		if err := os.Rename(tempPath, path); err != nil {
+			os.RemoveAll(tempPath)
+			return err
+		}
+		return nil
+	}
+	oldPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".old")
+	os.RemoveAll(oldPath)
+	if err := os.Rename(path, oldPath); err != nil && !os.IsNotExist(err) {
+		os.RemoveAll(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Rename(oldPath, path)
+		os.RemoveAll(tempPath)
+		return err
+	}
+	os.RemoveAll(oldPath)
+	return nil
+}
+
+// isDir reports whether a path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// ParquetTable is a table of a Parquet directory
+type ParquetTable struct {
+	Name   string
+	Path   string   // the Parquet file of the table, or the directory holding its files
+	Source string   // what DuckDB's read_parquet reads: the file, or a glob of the directory
+	Files  []string // the Parquet files of the table
+}
+
+// FindParquetTables finds the tables of a Parquet directory, sorted by name. A file at the
+// top of the directory is a table named after the file; the files under a subdirectory,
+// such as the parts of a table split by size, make up one table named after the
+// subdirectory. Hidden files and directories, such as unfinished writes, are skipped.
+func FindParquetTables(rootDir string) ([]ParquetTable, error) {
+	paths, err := FindFunc(rootDir, func(path string) bool {
+		rel, err := filepath.Rel(rootDir, path)
+		if err != nil || !strings.EqualFold(filepath.Ext(path), ".parquet") {
+			return false
+		}
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			if strings.HasPrefix(part, ".") {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []ParquetTable
+	byPath := make(map[string]int)
+	for _, path := range paths {
+		rel, _ := filepath.Rel(rootDir, path)
+		top, _, nested := strings.Cut(rel, string(filepath.Separator))
+		if !nested {
+			tables = append(tables, ParquetTable{Name: TableName(path), Path: path, Source: path, Files: []string{path}})
+			continue
+		}
+		dir := filepath.Join(rootDir, top)
+		if i, ok := byPath[dir]; ok {
+			tables[i].Files = append(tables[i].Files, path)
+			continue
+		}
+		byPath[dir] = len(tables)
+		tables = append(tables, ParquetTable{Name: sanitize(top), Path: dir, Source: filepath.Join(dir, "**", "*.parquet"), Files: []string{path}})
+	}
+	sort.SliceStable(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables, nil
+}
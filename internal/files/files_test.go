@@ -0,0 +1,89 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile returns a write function of WriteAtomic creating a file
+func writeFile(content string) func(string) error {
+	return func(tempPath string) error {
+		return os.WriteFile(tempPath, []byte(content), 0644)
+	}
+}
+
+// writeDir returns a write function of WriteAtomic creating a directory holding a file
+func writeDir(name, content string) func(string) error {
+	return func(tempPath string) error {
+		if err := os.Mkdir(tempPath, 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(tempPath, name), []byte(content), 0644)
+	}
+}
+
+// readFile returns the content of a file, or "" when it does not exist
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sales.parquet")
+	failure := errors.New("interrupted")
+
+	steps := []struct {
+		name    string
+		write   func(string) error
+		wantErr error
+		file    string // the file checked after the step, relative to path when it is a directory
+		content string
+	}{
+		{"create a file", writeFile("v1"), nil, "", "v1"},
+		{"replace the file", writeFile("v2"), nil, "", "v2"},
+		{"fail to replace the file", func(tempPath string) error {
+			writeFile("partial")(tempPath)
+			return failure
+		}, failure, "", "v2"},
+		{"replace the file by a directory", writeDir("part-0.parquet", "v3"), nil, "part-0.parquet", "v3"},
+		{"replace the directory", writeDir("part-1.parquet", "v4"), nil, "part-1.parquet", "v4"},
+		{"fail to replace the directory", func(tempPath string) error {
+			writeDir("part-2.parquet", "partial")(tempPath)
+			return failure
+		}, failure, "part-1.parquet", "v4"},
+		{"replace the directory by a file", writeFile("v5"), nil, "", "v5"},
+	}
+	for _, step := range steps {
+		if err := WriteAtomic(path, step.write); !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: error %v, want %v", step.name, err, step.wantErr)
+		}
+		if got := readFile(t, filepath.Join(path, step.file)); got != step.content {
+			t.Errorf("%s: content %q, want %q", step.name, got, step.content)
+		}
+		if step.file == "part-1.parquet" {
+			if _, err := os.Stat(filepath.Join(path, "part-0.parquet")); !os.IsNotExist(err) {
+				t.Errorf("%s: the files of the previous directory were kept", step.name)
+			}
+		}
+
+		// Only the result is left: no temporary file, nor previous directory
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Name() != "sales.parquet" {
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			t.Errorf("%s: the directory holds %v", step.name, names)
+		}
+	}
+}
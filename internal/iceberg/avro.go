@@ -0,0 +1,589 @@
+package iceberg
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"the-modern-data-stack/internal/files"
+)
+
+// avroMagic is the header that starts every Avro object container file
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+// avroSchema is a parsed Avro schema node
+type avroSchema struct {
+	Type     string
+	Name     string
+	Fields   []avroField
+	Items    *avroSchema
+	Values   *avroSchema
+	Branches []*avroSchema
+	Symbols  []string
+	Size     int
+}
+
+// avroField is a single field of an Avro record schema
+type avroField struct {
+	Name   string
+	Schema *avroSchema
+}
+
+// parseAvroSchema parses an Avro schema from its JSON representation
+func parseAvroSchema(data []byte) (*avroSchema, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	return buildAvroSchema(raw, map[string]*avroSchema{})
+}
+
+// buildAvroSchema converts a decoded JSON schema into an avroSchema, resolving named types
+func buildAvroSchema(raw interface{}, names map[string]*avroSchema) (*avroSchema, error) {
+	switch s := raw.(type) {
+	case string:
+		switch s {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{Type: s}, nil
+		}
+		if named, ok := names[s]; ok {
+			return named, nil
+		}
+		return nil, fmt.Errorf("unknown avro type %q", s)
+
+	case []interface{}:
+		union := &avroSchema{Type: "union"}
+		for _, branch := range s {
+			b, err := buildAvroSchema(branch, names)
+			if err != nil {
+				return nil, err
+			}
+			union.Branches = append(union.Branches, b)
+		}
+		return union, nil
+
+	case map[string]interface{}:
+		typ, _ := s["type"].(string)
+		name, _ := s["name"].(string)
+		switch typ {
+		case "record", "error":
+			record := &avroSchema{Type: "record", Name: name}
+			if name != "" {
+				names[name] = record
+			}
+			fields, _ := s["fields"].([]interface{})
+			for _, f := range fields {
+				fm, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("invalid field in record %q", name)
+				}
+				fieldName, _ := fm["name"].(string)
+				fieldSchema, err := buildAvroSchema(fm["type"], names)
+				if err != nil {
+					return nil, fmt.Errorf("field %q: %v", fieldName, err)
+				}
+				record.Fields = append(record.Fields, avroField{Name: fieldName, Schema: fieldSchema})
+			}
+			return record, nil
+		case "enum":
+			enum := &avroSchema{Type: "enum", Name: name}
+			symbols, _ := s["symbols"].([]interface{})
+			for _, sym := range symbols {
+				str, _ := sym.(string)
+				enum.Symbols = append(enum.Symbols, str)
+			}
+			if name != "" {
+				names[name] = enum
+			}
+			return enum, nil
+		case "fixed":
+			size, _ := s["size"].(float64)
+			fixed := &avroSchema{Type: "fixed", Name: name, Size: int(size)}
+			if name != "" {
+				names[name] = fixed
+			}
+			return fixed, nil
+		case "array":
+			items, err := buildAvroSchema(s["items"], names)
+			if err != nil {
+				return nil, err
+			}
+			return &avroSchema{Type: "array", Items: items}, nil
+		case "map":
+			values, err := buildAvroSchema(s["values"], names)
+			if err != nil {
+				return nil, err
+			}
+			return &avroSchema{Type: "map", Values: values}, nil
+		default:
+			// Primitive type wrapped in an object, usually to carry a logicalType
+			return buildAvroSchema(s["type"], names)
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported avro schema node: %v", raw)
+}
+
+// avroReader decodes Avro binary encoded values
+type avroReader struct {
+	r *bufio.Reader
+}
+
+func (a *avroReader) readLong() (int64, error) {
+	v, err := binary.ReadUvarint(a.r)
+	if err != nil {
+		return 0, err
+	}
+	// Zig-zag decoding
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+func (a *avroReader) readBytes() ([]byte, error) {
+	n, err := a.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("negative avro length %d", n)
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(a.r, buf)
+	return buf, err
+}
+
+// readValue decodes a single value of the given schema
+func (a *avroReader) readValue(schema *avroSchema) (interface{}, error) {
+	switch schema.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := a.r.ReadByte()
+		return b != 0, err
+	case "int":
+		v, err := a.readLong()
+		return int32(v), err
+	case "long":
+		return a.readLong()
+	case "float":
+		var buf [4]byte
+		if _, err := io.ReadFull(a.r, buf[:]); err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(buf[:])), nil
+	case "double":
+		var buf [8]byte
+		if _, err := io.ReadFull(a.r, buf[:]); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf[:])), nil
+	case "bytes":
+		return a.readBytes()
+	case "string":
+		b, err := a.readBytes()
+		return string(b), err
+	case "fixed":
+		buf := make([]byte, schema.Size)
+		_, err := io.ReadFull(a.r, buf)
+		return buf, err
+	case "enum":
+		idx, err := a.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if idx < 0 || int(idx) >= len(schema.Symbols) {
+			return nil, fmt.Errorf("enum index %d out of range", idx)
+		}
+		return schema.Symbols[idx], nil
+	case "union":
+		idx, err := a.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if idx < 0 || int(idx) >= len(schema.Branches) {
+			return nil, fmt.Errorf("union branch %d out of range", idx)
+		}
+		return a.readValue(schema.Branches[idx])
+	case "record":
+		record := make(map[string]interface{}, len(schema.Fields))
+		for _, field := range schema.Fields {
+			v, err := a.readValue(field.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %v", field.Name, err)
+			}
+			record[field.Name] = v
+		}
+		return record, nil
+	case "array":
+		var items []interface{}
+		err := a.readBlocks(func() error {
+			v, err := a.readValue(schema.Items)
+			items = append(items, v)
+			return err
+		})
+		return items, err
+	case "map":
+		values := make(map[string]interface{})
+		err := a.readBlocks(func() error {
+			key, err := a.readBytes()
+			if err != nil {
+				return err
+			}
+			v, err := a.readValue(schema.Values)
+			values[string(key)] = v
+			return err
+		})
+		return values, err
+	}
+
+	return nil, fmt.Errorf("unsupported avro type %q", schema.Type)
+}
+
+// readBlocks reads the block-encoded items of an array or map
+func (a *avroReader) readBlocks(readItem func() error) error {
+	for {
+		count, err := a.readLong()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// Negative counts are followed by the block size in bytes
+			count = -count
+			if _, err := a.readLong(); err != nil {
+				return err
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			if err := readItem(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// avroFile holds the decoded contents of an Avro object container file
+type avroFile struct {
+	Metadata map[string]string
+	Records  []map[string]interface{}
+}
+
+// readAvroFile reads all records from an Avro object container file
+func readAvroFile(path string) (*avroFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := &avroReader{r: bufio.NewReader(f)}
+
+	magic := make([]byte, len(avroMagic))
+	if _, err := io.ReadFull(header.r, magic); err != nil || !bytes.Equal(magic, avroMagic) {
+		return nil, fmt.Errorf("%s is not an avro file", path)
+	}
+
+	meta, err := header.readValue(&avroSchema{Type: "map", Values: &avroSchema{Type: "bytes"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avro header: %v", err)
+	}
+
+	file := &avroFile{Metadata: make(map[string]string)}
+	for k, v := range meta.(map[string]interface{}) {
+		file.Metadata[k] = string(v.([]byte))
+	}
+
+	schema, err := parseAvroSchema([]byte(file.Metadata["avro.schema"]))
+	if err != nil {
+		return nil, err
+	}
+
+	sync := make([]byte, 16)
+	if _, err := io.ReadFull(header.r, sync); err != nil {
+		return nil, fmt.Errorf("failed to read avro sync marker: %v", err)
+	}
+
+	codec := file.Metadata["avro.codec"]
+	if codec != "" && codec != "null" && codec != "deflate" {
+		return nil, fmt.Errorf("unsupported avro codec %q", codec)
+	}
+
+	for {
+		count, err := header.readLong()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read avro block: %v", err)
+		}
+
+		block, err := header.readBytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read avro block: %v", err)
+		}
+
+		var blockReader io.Reader = bytes.NewReader(block)
+		if codec == "deflate" {
+			blockReader = flate.NewReader(blockReader)
+		}
+		records := &avroReader{r: bufio.NewReader(blockReader)}
+
+		for i := int64(0); i < count; i++ {
+			v, err := records.readValue(schema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode avro record: %v", err)
+			}
+			record, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("avro file %s does not contain records", path)
+			}
+			file.Records = append(file.Records, record)
+		}
+
+		marker := make([]byte, 16)
+		if _, err := io.ReadFull(header.r, marker); err != nil || !bytes.Equal(marker, sync) {
+			return nil, fmt.Errorf("invalid avro sync marker in %s", path)
+		}
+	}
+
+	return file, nil
+}
+
+// avroWriter encodes values with the Avro binary encoding
+type avroWriter struct {
+	buf bytes.Buffer
+}
+
+func (a *avroWriter) writeLong(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	// Zig-zag encoding
+	n := binary.PutUvarint(tmp[:], uint64((v<<1)^(v>>63)))
+	a.buf.Write(tmp[:n])
+}
+
+func (a *avroWriter) writeBytes(b []byte) {
+	a.writeLong(int64(len(b)))
+	a.buf.Write(b)
+}
+
+// toInt64 converts the Go integer types used for Avro int and long values
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+// writeValue encodes a single value of the given schema
+func (a *avroWriter) writeValue(schema *avroSchema, v interface{}) error {
+	switch schema.Type {
+	case "null":
+		if v != nil {
+			return fmt.Errorf("expected null, got %T", v)
+		}
+		return nil
+	case "boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("expected boolean, got %T", v)
+		}
+		if b {
+			a.buf.WriteByte(1)
+		} else {
+			a.buf.WriteByte(0)
+		}
+		return nil
+	case "int", "long":
+		n, ok := toInt64(v)
+		if !ok {
+			return fmt.Errorf("expected %s, got %T", schema.Type, v)
+		}
+		a.writeLong(n)
+		return nil
+	case "float":
+		var f float32
+		switch x := v.(type) {
+		case float32:
+			f = x
+		case float64:
+			f = float32(x)
+		default:
+			return fmt.Errorf("expected float, got %T", v)
+		}
+		return binary.Write(&a.buf, binary.LittleEndian, math.Float32bits(f))
+	case "double":
+		var f float64
+		switch x := v.(type) {
+		case float32:
+			f = float64(x)
+		case float64:
+			f = x
+		default:
+			return fmt.Errorf("expected double, got %T", v)
+		}
+		return binary.Write(&a.buf, binary.LittleEndian, math.Float64bits(f))
+	case "bytes", "fixed":
+		b, ok := v.([]byte)
+		if !ok {
+			return fmt.Errorf("expected bytes, got %T", v)
+		}
+		if schema.Type == "fixed" {
+			if len(b) != schema.Size {
+				return fmt.Errorf("expected %d fixed bytes, got %d", schema.Size, len(b))
+			}
+			a.buf.Write(b)
+			return nil
+		}
+		a.writeBytes(b)
+		return nil
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", v)
+		}
+		a.writeBytes([]byte(s))
+		return nil
+	case "enum":
+		s, _ := v.(string)
+		for i, sym := range schema.Symbols {
+			if sym == s {
+				a.writeLong(int64(i))
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown enum symbol %q", s)
+	case "union":
+		// Null values use the null branch, anything else the first non-null branch
+		for i, branch := range schema.Branches {
+			if (v == nil) == (branch.Type == "null") {
+				a.writeLong(int64(i))
+				return a.writeValue(branch, v)
+			}
+		}
+		return fmt.Errorf("no union branch for %T", v)
+	case "record":
+		record, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected record %s, got %T", schema.Name, v)
+		}
+		for _, field := range schema.Fields {
+			if err := a.writeValue(field.Schema, record[field.Name]); err != nil {
+				return fmt.Errorf("field %q: %v", field.Name, err)
+			}
+		}
+		return nil
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok && v != nil {
+			return fmt.Errorf("expected array, got %T", v)
+		}
+		if len(items) > 0 {
+			a.writeLong(int64(len(items)))
+			for _, item := range items {
+				if err := a.writeValue(schema.Items, item); err != nil {
+					return err
+				}
+			}
+		}
+		a.writeLong(0)
+		return nil
+	case "map":
+		values, ok := v.(map[string]interface{})
+		if !ok && v != nil {
+			return fmt.Errorf("expected map, got %T", v)
+		}
+		if len(values) > 0 {
+			a.writeLong(int64(len(values)))
+			for key, value := range values {
+				a.writeBytes([]byte(key))
+				if err := a.writeValue(schema.Values, value); err != nil {
+					return err
+				}
+			}
+		}
+		a.writeLong(0)
+		return nil
+	}
+
+	return fmt.Errorf("unsupported avro type %q", schema.Type)
+}
+
+// writeAvroFile writes records to a deflate-compressed Avro object container file
+// and returns the size of the written file
+func writeAvroFile(path string, schemaJSON string, metadata map[string]string, records []map[string]interface{}) (int64, error) {
+	schema, err := parseAvroSchema([]byte(schemaJSON))
+	if err != nil {
+		return 0, err
+	}
+
+	header := &avroWriter{}
+	header.buf.Write(avroMagic)
+
+	meta := map[string]interface{}{
+		"avro.schema": []byte(schemaJSON),
+		"avro.codec":  []byte("deflate"),
+	}
+	for k, v := range metadata {
+		meta[k] = []byte(v)
+	}
+	if err := header.writeValue(&avroSchema{Type: "map", Values: &avroSchema{Type: "bytes"}}, meta); err != nil {
+		return 0, fmt.Errorf("failed to encode avro header: %v", err)
+	}
+
+	sync := make([]byte, 16)
+	if _, err := rand.Read(sync); err != nil {
+		return 0, err
+	}
+	header.buf.Write(sync)
+
+	block := &avroWriter{}
+	for _, record := range records {
+		if err := block.writeValue(schema, record); err != nil {
+			return 0, fmt.Errorf("failed to encode avro record: %v", err)
+		}
+	}
+
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := fw.Write(block.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	if err := fw.Close(); err != nil {
+		return 0, err
+	}
+
+	if len(records) > 0 {
+		header.writeLong(int64(len(records)))
+		header.writeBytes(compressed.Bytes())
+		header.buf.Write(sync)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	err = files.WriteAtomic(path, func(tempPath string) error {
+		return os.WriteFile(tempPath, header.buf.Bytes(), 0644)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(header.buf.Len()), nil
+}
@@ -0,0 +1,395 @@
+package iceberg
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// decodeAvro decodes a single value of a schema from its binary encoding
+func decodeAvro(t *testing.T, schema *avroSchema, data []byte) interface{} {
+	t.Helper()
+	r := &avroReader{r: bufio.NewReader(bytes.NewReader(data))}
+	v, err := r.readValue(schema)
+	if err != nil {
+		t.Fatalf("failed to decode %x: %v", data, err)
+	}
+	if _, err := r.r.ReadByte(); err == nil {
+		t.Fatalf("bytes left after decoding %x", data)
+	}
+	return v
+}
+
+func mustParseAvroSchema(t *testing.T, schemaJSON string) *avroSchema {
+	t.Helper()
+	schema, err := parseAvroSchema([]byte(schemaJSON))
+	if err != nil {
+		t.Fatalf("invalid schema %s: %v", schemaJSON, err)
+	}
+	return schema
+}
+
+func TestAvroLongZigZag(t *testing.T) {
+	tests := []struct {
+		value   int64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{-1, []byte{0x01}},
+		{1, []byte{0x02}},
+		{-2, []byte{0x03}},
+		{63, []byte{0x7e}},
+		{-64, []byte{0x7f}},
+		{64, []byte{0x80, 0x01}},
+		{-65, []byte{0x81, 0x01}},
+		{8675309, []byte{0xda, 0xff, 0xa2, 0x08}},
+		{math.MaxInt64, []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{math.MinInt64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+	}
+	for _, test := range tests {
+		w := &avroWriter{}
+		w.writeLong(test.value)
+		if !bytes.Equal(w.buf.Bytes(), test.encoded) {
+			t.Errorf("writeLong(%d) = %x, want %x", test.value, w.buf.Bytes(), test.encoded)
+		}
+		if got := decodeAvro(t, &avroSchema{Type: "long"}, test.encoded); got != test.value {
+			t.Errorf("readLong(%x) = %v, want %d", test.encoded, got, test.value)
+		}
+	}
+}
+
+func TestAvroDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		encoded []byte
+		want    interface{}
+	}{
+		{"null", `"null"`, nil, nil},
+		{"boolean", `"boolean"`, []byte{0x01}, true},
+		{"int", `"int"`, []byte{0x53}, int32(-42)},
+		{"long", `"long"`, []byte{0x80, 0x01}, int64(64)},
+		{"float", `"float"`, []byte{0x00, 0x00, 0xc0, 0x3f}, float32(1.5)},
+		{"double", `"double"`, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xc0}, -2.25},
+		{"string", `"string"`, []byte{0x0c, 'h', 0xc3, 0xa9, 'l', 'l', 'o'}, "héllo"},
+		{"empty string", `"string"`, []byte{0x00}, ""},
+		{"bytes", `"bytes"`, []byte{0x06, 0x00, 0x01, 0xff}, []byte{0, 1, 0xff}},
+		{"fixed", `{"type": "fixed", "name": "f4", "size": 4}`, []byte{1, 2, 3, 4}, []byte{1, 2, 3, 4}},
+		{"enum", `{"type": "enum", "name": "e", "symbols": ["a", "b", "c"]}`, []byte{0x04}, "c"},
+		{"logical type", `{"type": "int", "logicalType": "date"}`, []byte{0xf0, 0xa8, 0x02}, int32(19000)},
+
+		// A union is the index of its branch followed by the value; null may come first or last
+		{"union null first, null", `["null", "long"]`, []byte{0x00}, nil},
+		{"union null first, value", `["null", "long"]`, []byte{0x02, 0x0e}, int64(7)},
+		{"union null last, null", `["long", "null"]`, []byte{0x02}, nil},
+		{"union null last, value", `["long", "null"]`, []byte{0x00, 0x0e}, int64(7)},
+		{"union of three branches", `["null", "string", "long"]`, []byte{0x04, 0x0e}, int64(7)},
+		{"union of a record", `["null", {"type": "record", "name": "r", "fields": [{"name": "ok", "type": "boolean"}]}]`,
+			[]byte{0x02, 0x01}, map[string]interface{}{"ok": true}},
+
+		{"array", `{"type": "array", "items": "int"}`, []byte{0x04, 0x02, 0x04, 0x00}, []interface{}{int32(1), int32(2)}},
+		{"map", `{"type": "map", "values": "int"}`, []byte{0x02, 0x02, 'k', 0x06, 0x00}, map[string]interface{}{"k": int32(3)}},
+		{"empty map", `{"type": "map", "values": "int"}`, []byte{0x00}, map[string]interface{}{}},
+		{"record", `{"type": "record", "name": "r", "fields": [
+			{"name": "id", "type": "long"},
+			{"name": "tags", "type": ["null", {"type": "array", "items": "string"}]},
+			{"name": "child", "type": {"type": "record", "name": "c", "fields": [{"name": "ok", "type": "boolean"}]}}
+		]}`, []byte{0x02, 0x02, 0x04, 0x02, 'x', 0x02, 'y', 0x00, 0x00}, map[string]interface{}{
+			"id":    int64(1),
+			"tags":  []interface{}{"x", "y"},
+			"child": map[string]interface{}{"ok": false},
+		}},
+		{"record with a null field", `{"type": "record", "name": "r", "fields": [
+			{"name": "id", "type": "long"},
+			{"name": "name", "type": ["null", "string"], "default": null}
+		]}`, []byte{0x02, 0x00}, map[string]interface{}{"id": int64(1), "name": nil}},
+		{"iceberg map", `{"type": "array", "logicalType": "map", "items": {"type": "record", "name": "k117_v118", "fields": [
+			{"name": "key", "type": "int", "field-id": 117},
+			{"name": "value", "type": "long", "field-id": 118}
+		]}}`, []byte{0x04, 0x02, 0xc8, 0x01, 0x04, 0x90, 0x03, 0x00}, []interface{}{
+			map[string]interface{}{"key": int32(1), "value": int64(100)},
+			map[string]interface{}{"key": int32(2), "value": int64(200)},
+		}},
+		{"named type reference", `{"type": "record", "name": "pair", "fields": [
+			{"name": "first", "type": {"type": "fixed", "name": "uuid", "size": 2}},
+			{"name": "second", "type": "uuid"}
+		]}`, []byte{1, 2, 3, 4}, map[string]interface{}{"first": []byte{1, 2}, "second": []byte{3, 4}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := decodeAvro(t, mustParseAvroSchema(t, test.schema), test.encoded); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decoded %x as %#v, want %#v", test.encoded, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAvroReadBlocks(t *testing.T) {
+	schema := mustParseAvroSchema(t, `{"type": "array", "items": "long"}`)
+	tests := []struct {
+		name    string
+		encoded []byte
+		want    []interface{}
+	}{
+		{"one block", []byte{0x04, 0x02, 0x04, 0x00}, []interface{}{int64(1), int64(2)}},
+		{"several blocks", []byte{0x02, 0x02, 0x02, 0x04, 0x00}, []interface{}{int64(1), int64(2)}},
+		// A negative count is followed by the size of the block in bytes
+		{"negative count", []byte{0x03, 0x04, 0x02, 0x04, 0x00}, []interface{}{int64(1), int64(2)}},
+		{"empty", []byte{0x00}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := decodeAvro(t, schema, test.encoded); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decoded %x as %#v, want %#v", test.encoded, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAvroDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		encoded []byte
+	}{
+		{"union branch out of range", `["null", "long"]`, []byte{0x04}},
+		{"negative union branch", `["null", "long"]`, []byte{0x01}},
+		{"enum index out of range", `{"type": "enum", "name": "e", "symbols": ["a"]}`, []byte{0x02}},
+		{"negative length", `"string"`, []byte{0x01}},
+		{"truncated string", `"string"`, []byte{0x06, 'a'}},
+		{"truncated long", `"long"`, []byte{0x80}},
+		{"truncated record", `{"type": "record", "name": "r", "fields": [{"name": "a", "type": "int"}, {"name": "b", "type": "int"}]}`, []byte{0x02}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &avroReader{r: bufio.NewReader(bytes.NewReader(test.encoded))}
+			if v, err := r.readValue(mustParseAvroSchema(t, test.schema)); err == nil {
+				t.Errorf("decoded %x as %#v, expected an error", test.encoded, v)
+			}
+		})
+	}
+
+	for _, schema := range []string{`"decimal"`, `{"type": "record", "name": "r", "fields": [{"name": "a", "type": "unknown"}]}`, `{`} {
+		if _, err := parseAvroSchema([]byte(schema)); err == nil {
+			t.Errorf("expected an error parsing the schema %s", schema)
+		}
+	}
+}
+
+func TestAvroValueRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  interface{}
+	}{
+		{"null", `"null"`, nil},
+		{"boolean", `"boolean"`, true},
+		{"int", `"int"`, int32(-42)},
+		{"long", `"long"`, int64(1) << 40},
+		{"float", `"float"`, float32(1.5)},
+		{"double", `"double"`, -2.25},
+		{"string", `"string"`, "héllo"},
+		{"empty string", `"string"`, ""},
+		{"bytes", `"bytes"`, []byte{0, 1, 0xff}},
+		{"fixed", `{"type": "fixed", "name": "f4", "size": 4}`, []byte{1, 2, 3, 4}},
+		{"enum", `{"type": "enum", "name": "e", "symbols": ["a", "b", "c"]}`, "c"},
+		{"logical type", `{"type": "int", "logicalType": "date"}`, int32(19000)},
+		{"union null", `["null", "long"]`, nil},
+		{"union value", `["null", "long"]`, int64(7)},
+		{"union null last", `["long", "null"]`, nil},
+		{"union value, null last", `["long", "null"]`, int64(7)},
+		{"array", `{"type": "array", "items": "long"}`, []interface{}{int64(1), int64(-1), int64(300)}},
+		{"map", `{"type": "map", "values": "string"}`, map[string]interface{}{"a": "x", "b": ""}},
+		{"record", `{"type": "record", "name": "r", "fields": [
+			{"name": "id", "type": "long"},
+			{"name": "tags", "type": ["null", {"type": "array", "items": "string"}]},
+			{"name": "child", "type": {"type": "record", "name": "c", "fields": [{"name": "ok", "type": "boolean"}]}}
+		]}`, map[string]interface{}{
+			"id":    int64(1),
+			"tags":  []interface{}{"x", "y"},
+			"child": map[string]interface{}{"ok": false},
+		}},
+		{"iceberg map", `{"type": "array", "logicalType": "map", "items": {"type": "record", "name": "k117_v118", "fields": [
+			{"name": "key", "type": "int", "field-id": 117},
+			{"name": "value", "type": "long", "field-id": 118}
+		]}}`, []interface{}{
+			map[string]interface{}{"key": int32(1), "value": int64(100)},
+			map[string]interface{}{"key": int32(2), "value": int64(200)},
+		}},
+		{"named type reference", `{"type": "record", "name": "pair", "fields": [
+			{"name": "first", "type": {"type": "fixed", "name": "uuid", "size": 2}},
+			{"name": "second", "type": "uuid"}
+		]}`, map[string]interface{}{"first": []byte{1, 2}, "second": []byte{3, 4}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema := mustParseAvroSchema(t, test.schema)
+			w := &avroWriter{}
+			if err := w.writeValue(schema, test.value); err != nil {
+				t.Fatalf("failed to encode %v: %v", test.value, err)
+			}
+			if got := decodeAvro(t, schema, w.buf.Bytes()); !reflect.DeepEqual(got, test.value) {
+				t.Errorf("round trip of %#v gave %#v", test.value, got)
+			}
+		})
+	}
+}
+
+func TestAvroEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		value   interface{}
+		encoded []byte
+	}{
+		// Unions are the branch index followed by the value
+		{"union null", `["null", "string"]`, nil, []byte{0x00}},
+		{"union value", `["null", "string"]`, "a", []byte{0x02, 0x02, 'a'}},
+		{"union null last", `["string", "null"]`, nil, []byte{0x02}},
+		// Arrays and maps are blocks of items ending with an empty block
+		{"array", `{"type": "array", "items": "int"}`, []interface{}{int32(1), int32(2)}, []byte{0x04, 0x02, 0x04, 0x00}},
+		{"empty array", `{"type": "array", "items": "int"}`, []interface{}{}, []byte{0x00}},
+		{"map", `{"type": "map", "values": "int"}`, map[string]interface{}{"k": int32(3)}, []byte{0x02, 0x02, 'k', 0x06, 0x00}},
+		{"float", `"float"`, float32(1), []byte{0x00, 0x00, 0x80, 0x3f}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &avroWriter{}
+			if err := w.writeValue(mustParseAvroSchema(t, test.schema), test.value); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(w.buf.Bytes(), test.encoded) {
+				t.Errorf("encoded %#v as %x, want %x", test.value, w.buf.Bytes(), test.encoded)
+			}
+		})
+	}
+}
+
+func TestAvroEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  interface{}
+	}{
+		{"no union branch", `["null"]`, int64(1)},
+		{"wrong type", `"long"`, "1"},
+		{"fixed size", `{"type": "fixed", "name": "f", "size": 2}`, []byte{1}},
+		{"unknown enum symbol", `{"type": "enum", "name": "e", "symbols": ["a"]}`, "b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &avroWriter{}
+			if err := w.writeValue(mustParseAvroSchema(t, test.schema), test.value); err == nil {
+				t.Errorf("encoded %#v as %x, expected an error", test.value, w.buf.Bytes())
+			}
+		})
+	}
+}
+
+// TestReadAvroFileReference reads a file written by another Avro implementation (hamba/avro,
+// from the test data of apache/arrow-go), with the null codec, negative block counts and
+// maps of records
+func TestReadAvroFileReference(t *testing.T) {
+	file, err := readAvroFile(filepath.Join("testdata", "arrayrecordmap.avro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Metadata["avro.codec"] != "null" {
+		t.Errorf("codec %q, want null", file.Metadata["avro.codec"])
+	}
+
+	want := []map[string]interface{}{{
+		"array": []interface{}{
+			map[string]interface{}{
+				"a": int32(42),
+				"b": []interface{}{"bacon", "tofu"},
+				"map": map[string]interface{}{
+					"arrayrecordmap": map[string]interface{}{
+						"number": int32(8675309),
+						"name":   []interface{}{"jenny", "jenny"},
+					},
+				},
+			},
+		},
+	}}
+	if !reflect.DeepEqual(file.Records, want) {
+		t.Errorf("read %#v, want %#v", file.Records, want)
+	}
+}
+
+func TestReadAvroFileInvalid(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "arrayrecordmap.avro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sync marker ends the file, after the only block
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not avro", []byte("PAR1")},
+		{"empty", nil},
+		{"truncated", data[:len(data)-8]},
+		{"sync marker", corrupted},
+	}
+	dir := t.TempDir()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.name+".avro")
+			if err := os.WriteFile(path, test.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readAvroFile(path); err == nil {
+				t.Errorf("expected an error reading %s", test.name)
+			}
+		})
+	}
+}
+
+func TestAvroFileRoundTrip(t *testing.T) {
+	schemaJSON := `{"type": "record", "name": "r", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": ["null", "string"]}
+	]}`
+	tests := []struct {
+		name    string
+		records []map[string]interface{}
+	}{
+		{"records", []map[string]interface{}{
+			{"id": int64(1), "name": "a"},
+			{"id": int64(2), "name": nil},
+		}},
+		{"no records", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.avro")
+			size, err := writeAvroFile(path, schemaJSON, map[string]string{"format-version": "2"}, test.records)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size == 0 {
+				t.Error("writeAvroFile returned a size of 0")
+			}
+
+			file, err := readAvroFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Metadata["avro.codec"] != "deflate" || file.Metadata["format-version"] != "2" {
+				t.Errorf("unexpected metadata %v", file.Metadata)
+			}
+			if !reflect.DeepEqual(file.Records, test.records) {
+				t.Errorf("read %#v, want %#v", file.Records, test.records)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package iceberg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to an Iceberg REST Catalog
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+	Token      string        // bearer token sent with every request, if set
+	Timeout    time.Duration // timeout of each attempt of a request, if set
+	Retry      RetryPolicy
+}
+
+// NewClient creates a REST Catalog client for the given base URL
+func NewClient(catalogURL string) *Client {
+	return &Client{
+		URL:        strings.TrimRight(catalogURL, "/"),
+		HTTPClient: http.DefaultClient,
+		Retry:      DefaultRetryPolicy,
+	}
+}
+
+// CatalogError is returned when the catalog answers with a non-success status
+type CatalogError struct {
+	StatusCode int
+	Type       string
+	Message    string
+	RetryAfter time.Duration // wait asked for by the Retry-After header, if any
+}
+
+func (e *CatalogError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("status: %d, %s: %s", e.StatusCode, e.Type, e.Message)
+	}
+	return fmt.Sprintf("status: %d, body: %s", e.StatusCode, e.Message)
+}
+
+// IsCommitConflict reports whether err is a 409 response, which the catalog returns
+// when a commit requirement no longer holds because another writer committed first
+func IsCommitConflict(err error) bool {
+	var catalogErr *CatalogError
+	return errors.As(err, &catalogErr) && catalogErr.StatusCode == http.StatusConflict
+}
+
+// IsCommitRejected reports whether a commit certainly was not applied: the catalog
+// answered with a 4xx response, such as a 409 conflict or a 400 invalid update, or a 503,
+// or could not be connected to. After any other failure, such as a 500, a 504 or a
+// connection lost before the response, the commit may have been applied.
+func IsCommitRejected(err error) bool {
+	var catalogErr *CatalogError
+	if errors.As(err, &catalogErr) {
+		code := catalogErr.StatusCode
+		return code >= 400 && code < 500 || code == http.StatusServiceUnavailable
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// LoadTableResult is the catalog response for a table load
+type LoadTableResult struct {
+	MetadataLocation string            `json:"metadata-location"`
+	Metadata         TableMetadata     `json:"metadata"`
+	Config           map[string]string `json:"config,omitempty"`
+}
+
+// do sends a request to the catalog, retrying it as its retry policy allows, and decodes
+// the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to marshal request: %v", err)
+		}
+	}
+	return c.retry(ctx, method, path, func() error {
+		return c.send(ctx, method, path, payload, out)
+	})
+}
+
+// send makes one attempt of a request
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, out interface{}) error {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		catalogErr := &CatalogError{StatusCode: resp.StatusCode, Message: string(respBody), RetryAfter: retryAfter(resp.Header)}
+		var errResp struct {
+			Error struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
+			catalogErr.Type = errResp.Error.Type
+			catalogErr.Message = errResp.Error.Message
+		}
+		return catalogErr
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// tablePath builds the REST path of a table
+func tablePath(namespace, table string) string {
+	return fmt.Sprintf("/v1/namespaces/%s/tables/%s", url.PathEscape(namespace), url.PathEscape(table))
+}
+
+// Ping checks that the catalog answers its config endpoint
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/v1/config", nil, nil)
+}
+
+// ListNamespaces returns the top-level namespaces of the catalog
+func (c *Client) ListNamespaces(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Namespaces [][]string `json:"namespaces"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/namespaces", nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	var namespaces []string
+	for _, ns := range resp.Namespaces {
+		namespaces = append(namespaces, strings.Join(ns, "."))
+	}
+	return namespaces, nil
+}
+
+// LoadNamespaceProperties returns the properties of a namespace
+func (c *Client) LoadNamespaceProperties(ctx context.Context, namespace string) (map[string]string, error) {
+	var resp struct {
+		Properties map[string]string `json:"properties"`
+	}
+	path := fmt.Sprintf("/v1/namespaces/%s", url.PathEscape(namespace))
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to load namespace %s: %w", namespace, err)
+	}
+	if resp.Properties == nil {
+		resp.Properties = make(map[string]string)
+	}
+	return resp.Properties, nil
+}
+
+// CreateNamespace creates a namespace with the given properties
+func (c *Client) CreateNamespace(ctx context.Context, namespace string, properties map[string]string) error {
+	if properties == nil {
+		properties = make(map[string]string)
+	}
+	body := map[string]interface{}{
+		"namespace":  strings.Split(namespace, "."),
+		"properties": properties,
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/namespaces", body, nil); err != nil {
+		return fmt.Errorf("failed to create namespace %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// UpdateNamespaceProperties sets and removes properties of a namespace
+func (c *Client) UpdateNamespaceProperties(ctx context.Context, namespace string, removals []string, updates map[string]string) error {
+	if removals == nil {
+		removals = []string{}
+	}
+	if updates == nil {
+		updates = make(map[string]string)
+	}
+	body := map[string]interface{}{"removals": removals, "updates": updates}
+	path := fmt.Sprintf("/v1/namespaces/%s/properties", url.PathEscape(namespace))
+	if err := c.do(ctx, http.MethodPost, path, body, nil); err != nil {
+		return fmt.Errorf("failed to update properties of namespace %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// ListTables returns the names of the tables in a namespace
+func (c *Client) ListTables(ctx context.Context, namespace string) ([]string, error) {
+	var resp struct {
+		Identifiers []struct {
+			Name string `json:"name"`
+		} `json:"identifiers"`
+	}
+	path := fmt.Sprintf("/v1/namespaces/%s/tables", url.PathEscape(namespace))
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list tables in %s: %w", namespace, err)
+	}
+
+	var tables []string
+	for _, id := range resp.Identifiers {
+		tables = append(tables, id.Name)
+	}
+	return tables, nil
+}
+
+// LoadTable loads the current metadata of a table
+func (c *Client) LoadTable(ctx context.Context, namespace, table string) (*LoadTableResult, error) {
+	var result LoadTableResult
+	if err := c.do(ctx, http.MethodGet, tablePath(namespace, table), nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to load table %s.%s: %w", namespace, table, err)
+	}
+	return &result, nil
+}
+
+// CreateTableRequest is the request body for creating a table
+type CreateTableRequest struct {
+	Name          string            `json:"name"`
+	Location      string            `json:"location,omitempty"`
+	Schema        Schema            `json:"schema"`
+	PartitionSpec *PartitionSpec    `json:"partition-spec,omitempty"`
+	Properties    map[string]string `json:"properties,omitempty"`
+}
+
+// CreateTable creates a table in a namespace
+func (c *Client) CreateTable(ctx context.Context, namespace string, request *CreateTableRequest) (*LoadTableResult, error) {
+	var result LoadTableResult
+	path := fmt.Sprintf("/v1/namespaces/%s/tables", url.PathEscape(namespace))
+	if err := c.do(ctx, http.MethodPost, path, request, &result); err != nil {
+		return nil, fmt.Errorf("failed to create table %s.%s: %w", namespace, request.Name, err)
+	}
+	return &result, nil
+}
+
+// DropTable removes a table from the catalog. Its files are only deleted when purge is set.
+func (c *Client) DropTable(ctx context.Context, namespace, table string, purge bool) error {
+	path := fmt.Sprintf("%s?purgeRequested=%t", tablePath(namespace, table), purge)
+	if err := c.do(ctx, http.MethodDelete, path, nil, nil); err != nil {
+		return fmt.Errorf("failed to drop table %s.%s: %w", namespace, table, err)
+	}
+	return nil
+}
+
+// TableRequirement is an assertion the catalog validates before applying a commit
+type TableRequirement map[string]interface{}
+
+// TableUpdate is a change to the table metadata applied by a commit
+type TableUpdate map[string]interface{}
+
+// TableCommit holds the requirements and updates of a single table commit
+type TableCommit struct {
+	Requirements []TableRequirement `json:"requirements"`
+	Updates      []TableUpdate      `json:"updates"`
+}
+
+// AssertTableUUID requires the table to still be the one that was loaded
+func AssertTableUUID(uuid string) TableRequirement {
+	return TableRequirement{"type": "assert-table-uuid", "uuid": uuid}
+}
+
+// AssertRefSnapshotID requires a branch or tag to point at snapshotID, or not to exist when snapshotID is nil
+func AssertRefSnapshotID(ref string, snapshotID *int64) TableRequirement {
+	requirement := TableRequirement{"type": "assert-ref-snapshot-id", "ref": ref, "snapshot-id": nil}
+	if snapshotID != nil {
+		requirement["snapshot-id"] = *snapshotID
+	}
+	return requirement
+}
+
+// AssertCurrentSchemaID requires the current schema to still be the one that was loaded
+func AssertCurrentSchemaID(schemaID int) TableRequirement {
+	return TableRequirement{"type": "assert-current-schema-id", "current-schema-id": schemaID}
+}
+
+// AddSchema adds a new schema to the table
+func AddSchema(schema *Schema, lastColumnID int) TableUpdate {
+	return TableUpdate{"action": "add-schema", "schema": schema, "last-column-id": lastColumnID}
+}
+
+// SetCurrentSchema makes a schema the current one; -1 selects the schema added last
+func SetCurrentSchema(schemaID int) TableUpdate {
+	return TableUpdate{"action": "set-current-schema", "schema-id": schemaID}
+}
+
+// AssertDefaultSpecID requires the default partition spec to still be the one that was loaded
+func AssertDefaultSpecID(specID int) TableRequirement {
+	return TableRequirement{"type": "assert-default-spec-id", "default-spec-id": specID}
+}
+
+// AssertLastAssignedPartitionID requires no partition field ID to have been assigned since the table was loaded
+func AssertLastAssignedPartitionID(partitionID int) TableRequirement {
+	return TableRequirement{"type": "assert-last-assigned-partition-id", "last-assigned-partition-id": partitionID}
+}
+
+// AddPartitionSpec adds a new partition spec to the table
+func AddPartitionSpec(spec *PartitionSpec) TableUpdate {
+	return TableUpdate{"action": "add-spec", "spec": spec}
+}
+
+// SetDefaultSpec makes a partition spec the default one; -1 selects the spec added last
+func SetDefaultSpec(specID int) TableUpdate {
+	return TableUpdate{"action": "set-default-spec", "spec-id": specID}
+}
+
+// SetProperties sets table properties
+func SetProperties(updates map[string]string) TableUpdate {
+	return TableUpdate{"action": "set-properties", "updates": updates}
+}
+
+// RemoveProperties removes table properties
+func RemoveProperties(removals []string) TableUpdate {
+	return TableUpdate{"action": "remove-properties", "removals": removals}
+}
+
+// AddSnapshot adds a snapshot to the table metadata
+func AddSnapshot(snapshot *Snapshot) TableUpdate {
+	return TableUpdate{"action": "add-snapshot", "snapshot": snapshot}
+}
+
+// SetSnapshotRef creates or moves a branch or tag
+func SetSnapshotRef(name string, ref SnapshotRef) TableUpdate {
+	update := TableUpdate{
+		"action":      "set-snapshot-ref",
+		"ref-name":    name,
+		"type":        ref.Type,
+		"snapshot-id": ref.SnapshotID,
+	}
+	if ref.MinSnapshotsToKeep != nil {
+		update["min-snapshots-to-keep"] = *ref.MinSnapshotsToKeep
+	}
+	if ref.MaxSnapshotAgeMs != nil {
+		update["max-snapshot-age-ms"] = *ref.MaxSnapshotAgeMs
+	}
+	if ref.MaxRefAgeMs != nil {
+		update["max-ref-age-ms"] = *ref.MaxRefAgeMs
+	}
+	return update
+}
+
+// RemoveSnapshotRef deletes a branch or tag
+func RemoveSnapshotRef(name string) TableUpdate {
+	return TableUpdate{"action": "remove-snapshot-ref", "ref-name": name}
+}
+
+// CommitTable applies updates to a table once all requirements hold
+func (c *Client) CommitTable(ctx context.Context, namespace, table string, commit *TableCommit) (*LoadTableResult, error) {
+	var result LoadTableResult
+	if err := c.do(ctx, http.MethodPost, tablePath(namespace, table), commit, &result); err != nil {
+		return nil, fmt.Errorf("failed to commit to table %s.%s: %w", namespace, table, err)
+	}
+	return &result, nil
+}
+
+// TableIdentifier names a table in a multi-table commit
+type TableIdentifier struct {
+	Namespace []string `json:"namespace"`
+	Name      string   `json:"name"`
+}
+
+// TableChange is the commit of one table within a transaction
+type TableChange struct {
+	Identifier TableIdentifier `json:"identifier"`
+	TableCommit
+}
+
+// NewTableChange wraps a table commit for a multi-table transaction
+func NewTableChange(namespace, table string, commit *TableCommit) TableChange {
+	return TableChange{
+		Identifier:  TableIdentifier{Namespace: strings.Split(namespace, "."), Name: table},
+		TableCommit: *commit,
+	}
+}
+
+// CommitTransaction atomically commits changes to several tables: either all
+// requirements hold and every change is applied, or nothing is
+func (c *Client) CommitTransaction(ctx context.Context, changes []TableChange) error {
+	body := map[string]interface{}{"table-changes": changes}
+	if err := c.do(ctx, http.MethodPost, "/v1/transactions/commit", body, nil); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// IsUnsupportedEndpoint reports whether err means the catalog does not implement
+// the endpoint, as opposed to rejecting the request itself
+func IsUnsupportedEndpoint(err error) bool {
+	var catalogErr *CatalogError
+	if !errors.As(err, &catalogErr) {
+		return false
+	}
+	switch catalogErr.StatusCode {
+	case http.StatusNotFound:
+		return !strings.HasPrefix(catalogErr.Type, "NoSuch")
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package iceberg
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileIO maps the locations recorded in Iceberg metadata to local paths.
+// The catalog runs in Docker with the warehouse mounted at WarehouseLocation,
+// while the same files live under WarehouseDir on the host.
+type FileIO struct {
+	WarehouseDir      string
+	WarehouseLocation string
+}
+
+// NewFileIO creates a FileIO for a warehouse directory mounted at the given catalog location
+func NewFileIO(warehouseDir, warehouseLocation string) *FileIO {
+	return &FileIO{
+		WarehouseDir:      warehouseDir,
+		WarehouseLocation: stripScheme(warehouseLocation),
+	}
+}
+
+// stripScheme removes the file: scheme from a location
+func stripScheme(location string) string {
+	switch {
+	case strings.HasPrefix(location, "file://"):
+		location = strings.TrimPrefix(location, "file://")
+	case strings.HasPrefix(location, "file:"):
+		location = strings.TrimPrefix(location, "file:")
+	}
+	return path.Clean("/" + strings.TrimLeft(location, "/"))
+}
+
+// LocalPath converts a metadata location into a path on the local filesystem
+func (f *FileIO) LocalPath(location string) string {
+	p := stripScheme(location)
+	prefix := strings.TrimRight(f.WarehouseLocation, "/")
+	if p == prefix || strings.HasPrefix(p, prefix+"/") {
+		return filepath.Join(f.WarehouseDir, filepath.FromSlash(strings.TrimPrefix(p, prefix)))
+	}
+	return filepath.FromSlash(p)
+}
@@ -0,0 +1,224 @@
+package iceberg
+
+import "fmt"
+
+// Manifest content types
+const (
+	ManifestContentData    = 0
+	ManifestContentDeletes = 1
+)
+
+// Manifest entry statuses
+const (
+	EntryStatusExisting = 0
+	EntryStatusAdded    = 1
+	EntryStatusDeleted  = 2
+)
+
+// Data file content types
+const (
+	FileContentData            = 0
+	FileContentPositionDeletes = 1
+	FileContentEqualityDeletes = 2
+)
+
+// ManifestFile is an entry of a snapshot's manifest list
+type ManifestFile struct {
+	Path               string
+	Length             int64
+	PartitionSpecID    int32
+	Content            int32
+	SequenceNumber     int64
+	MinSequenceNumber  int64
+	AddedSnapshotID    int64
+	AddedFilesCount    int32
+	ExistingFilesCount int32
+	DeletedFilesCount  int32
+	AddedRowsCount     int64
+	ExistingRowsCount  int64
+	DeletedRowsCount   int64
+}
+
+// DataFile describes a data or delete file tracked by a manifest
+type DataFile struct {
+	Content         int32
+	FilePath        string
+	FileFormat      string
+	Partition       map[string]interface{}
+	RecordCount     int64
+	FileSizeInBytes int64
+	EqualityIDs     []int
+	// record is the data_file record the file was read from, whose column stats, split
+	// offsets and sort order are written back unchanged when its manifest is rewritten
+	record map[string]interface{}
+}
+
+// ManifestEntry is a single entry of a manifest file
+type ManifestEntry struct {
+	Status             int32
+	SnapshotID         int64
+	SequenceNumber     int64
+	FileSequenceNumber int64
+	DataFile           DataFile
+	// PartitionSpecID is the spec of the manifest holding the entry, which its partition follows
+	PartitionSpecID int32
+}
+
+// longField returns the first of the named fields present in an Avro record as an int64.
+// Format v1 and v2 use different names for some fields, hence the alternatives.
+func longField(record map[string]interface{}, names ...string) int64 {
+	for _, name := range names {
+		switch v := record[name].(type) {
+		case int64:
+			return v
+		case int32:
+			return int64(v)
+		}
+	}
+	return 0
+}
+
+// stringField returns a string field of an Avro record
+func stringField(record map[string]interface{}, name string) string {
+	s, _ := record[name].(string)
+	return s
+}
+
+// ReadManifestList reads the manifests listed in a snapshot's manifest list
+func (f *FileIO) ReadManifestList(location string) ([]ManifestFile, error) {
+	file, err := readAvroFile(f.LocalPath(location))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest list %s: %v", location, err)
+	}
+
+	var manifests []ManifestFile
+	for _, r := range file.Records {
+		manifests = append(manifests, ManifestFile{
+			Path:               stringField(r, "manifest_path"),
+			Length:             longField(r, "manifest_length"),
+			PartitionSpecID:    int32(longField(r, "partition_spec_id")),
+			Content:            int32(longField(r, "content")),
+			SequenceNumber:     longField(r, "sequence_number"),
+			MinSequenceNumber:  longField(r, "min_sequence_number"),
+			AddedSnapshotID:    longField(r, "added_snapshot_id"),
+			AddedFilesCount:    int32(longField(r, "added_files_count", "added_data_files_count")),
+			ExistingFilesCount: int32(longField(r, "existing_files_count", "existing_data_files_count")),
+			DeletedFilesCount:  int32(longField(r, "deleted_files_count", "deleted_data_files_count")),
+			AddedRowsCount:     longField(r, "added_rows_count"),
+			ExistingRowsCount:  longField(r, "existing_rows_count"),
+			DeletedRowsCount:   longField(r, "deleted_rows_count"),
+		})
+	}
+
+	return manifests, nil
+}
+
+// intListField returns an optional Avro array of ints, or nil when it is absent
+func intListField(record map[string]interface{}, name string) []int {
+	items, _ := record[name].([]interface{})
+	var values []int
+	for _, item := range items {
+		switch v := item.(type) {
+		case int32:
+			values = append(values, int(v))
+		case int64:
+			values = append(values, int(v))
+		}
+	}
+	return values
+}
+
+// ReadManifest reads the entries of a manifest file
+func (f *FileIO) ReadManifest(location string) ([]ManifestEntry, error) {
+	file, err := readAvroFile(f.LocalPath(location))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %v", location, err)
+	}
+
+	var entries []ManifestEntry
+	for _, r := range file.Records {
+		df, ok := r["data_file"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("manifest %s has an entry without data_file", location)
+		}
+		partition, _ := df["partition"].(map[string]interface{})
+
+		entries = append(entries, ManifestEntry{
+			Status:             int32(longField(r, "status")),
+			SnapshotID:         longField(r, "snapshot_id"),
+			SequenceNumber:     longField(r, "sequence_number"),
+			FileSequenceNumber: longField(r, "file_sequence_number"),
+			DataFile: DataFile{
+				Content:         int32(longField(df, "content")),
+				FilePath:        stringField(df, "file_path"),
+				FileFormat:      stringField(df, "file_format"),
+				Partition:       partition,
+				RecordCount:     longField(df, "record_count"),
+				FileSizeInBytes: longField(df, "file_size_in_bytes"),
+				EqualityIDs:     intListField(df, "equality_ids"),
+				record:          df,
+			},
+		})
+	}
+
+	return entries, nil
+}
+
+// ReadSnapshotEntries returns the live data and delete file entries of a snapshot.
+// Entries without an explicit sequence number inherit the one of their manifest.
+func (f *FileIO) ReadSnapshotEntries(snapshot *Snapshot) ([]ManifestEntry, error) {
+	manifests := make([]ManifestFile, 0, len(snapshot.Manifests))
+	for _, path := range snapshot.Manifests {
+		manifests = append(manifests, ManifestFile{Path: path})
+	}
+
+	if snapshot.ManifestList != "" {
+		listed, err := f.ReadManifestList(snapshot.ManifestList)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, listed...)
+	}
+
+	var live []ManifestEntry
+	for _, manifest := range manifests {
+		entries, err := f.ReadLiveEntries(manifest)
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, entries...)
+	}
+
+	return live, nil
+}
+
+// ReadLiveEntries reads the entries of a manifest that are not deleted. Added entries
+// inherit the snapshot ID and sequence numbers of the manifest when they are not set.
+func (f *FileIO) ReadLiveEntries(manifest ManifestFile) ([]ManifestEntry, error) {
+	entries, err := f.ReadManifest(manifest.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var live []ManifestEntry
+	for _, entry := range entries {
+		if entry.Status == EntryStatusDeleted {
+			continue
+		}
+		entry.PartitionSpecID = manifest.PartitionSpecID
+		if entry.Status == EntryStatusAdded {
+			if entry.SnapshotID == 0 {
+				entry.SnapshotID = manifest.AddedSnapshotID
+			}
+			if entry.SequenceNumber == 0 {
+				entry.SequenceNumber = manifest.SequenceNumber
+			}
+			if entry.FileSequenceNumber == 0 {
+				entry.FileSequenceNumber = manifest.SequenceNumber
+			}
+		}
+		live = append(live, entry)
+	}
+
+	return live, nil
+}
@@ -0,0 +1,121 @@
+package iceberg
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The manifests and manifest lists of testdata were encoded independently of this package,
+// with the schemas, field IDs and file metadata the Java implementation writes for each
+// format version. They are deflate-compressed and use ["null", type] unions for optional
+// fields, as Java does.
+
+// testdataPath returns the absolute path of a test file, which FileIO reads as is since it
+// is outside the warehouse
+func testdataPath(t *testing.T, name string) string {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testdata/manifest-v2.avro holds an added, an existing and a deleted entry with the full
+// data_file record, column stats included, and an identity partition
+func TestReadManifestReference(t *testing.T) {
+	io := NewFileIO(t.TempDir(), "file:/warehouse")
+
+	location := "file:/var/lib/iceberg/warehouse/my_data/ventes/data/"
+	want := []ManifestEntry{
+		{Status: EntryStatusAdded, SnapshotID: 3051729675574597004, DataFile: DataFile{FilePath: location + "annee=2023/00000-0-a.parquet",
+			FileFormat: "PARQUET", Partition: map[string]interface{}{"annee": int32(2023)}, RecordCount: 2, FileSizeInBytes: 1024}},
+		{Status: EntryStatusExisting, SnapshotID: 3051729675574597000, SequenceNumber: 1, FileSequenceNumber: 1, DataFile: DataFile{FilePath: location + "annee=2024/00000-0-b.parquet",
+			FileFormat: "PARQUET", Partition: map[string]interface{}{"annee": int32(2024)}, RecordCount: 1, FileSizeInBytes: 980}},
+		{Status: EntryStatusDeleted, SnapshotID: 3051729675574597004, SequenceNumber: 1, FileSequenceNumber: 1, DataFile: DataFile{FilePath: location + "annee=2022/00000-0-c.parquet",
+			FileFormat: "PARQUET", Partition: map[string]interface{}{"annee": int32(2022)}, RecordCount: 5, FileSizeInBytes: 2048}},
+	}
+
+	path := testdataPath(t, "manifest-v2.avro")
+	entries, err := io.ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(withoutRecords(entries), want) {
+		t.Errorf("entries\n got %+v\nwant %+v", entries, want)
+	}
+
+	live, err := io.ReadLiveEntries(ManifestFile{Path: path, AddedSnapshotID: 3051729675574597004, SequenceNumber: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(live) != 2 {
+		t.Fatalf("%d live entries, want 2", len(live))
+	}
+	if live[0].SequenceNumber != 2 || live[0].FileSequenceNumber != 2 {
+		t.Errorf("added entry has sequence numbers %d and %d, want them inherited from the manifest", live[0].SequenceNumber, live[0].FileSequenceNumber)
+	}
+	if live[1].SequenceNumber != 1 {
+		t.Errorf("existing entry has sequence number %d, want 1", live[1].SequenceNumber)
+	}
+}
+
+// A v1 manifest is resolved by field name: snapshot_id is required, content and the
+// sequence numbers are missing and read as their defaults, and block_size_in_bytes, which
+// v2 dropped, is ignored
+func TestReadManifestV1(t *testing.T) {
+	io := NewFileIO(t.TempDir(), "file:/warehouse")
+
+	location := "file:/var/lib/iceberg/warehouse/my_data/ventes/data/"
+	want := []ManifestEntry{
+		{Status: EntryStatusAdded, SnapshotID: 5293004537813459370, DataFile: DataFile{FilePath: location + "annee=2023/00000-0-d.parquet",
+			FileFormat: "PARQUET", Partition: map[string]interface{}{"annee": int32(2023)}, RecordCount: 3, FileSizeInBytes: 1100}},
+		{Status: EntryStatusExisting, SnapshotID: 5293004537813459001, DataFile: DataFile{FilePath: location + "annee=2024/00000-0-e.parquet",
+			FileFormat: "PARQUET", Partition: map[string]interface{}{"annee": nil}, RecordCount: 7, FileSizeInBytes: 1500}},
+		{Status: EntryStatusDeleted, SnapshotID: 5293004537813459370, DataFile: DataFile{FilePath: location + "annee=2022/00000-0-f.parquet",
+			FileFormat: "PARQUET", Partition: map[string]interface{}{"annee": int32(2022)}, RecordCount: 4, FileSizeInBytes: 1300}},
+	}
+
+	entries, err := io.ReadManifest(testdataPath(t, "manifest-v1.avro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(withoutRecords(entries), want) {
+		t.Errorf("entries\n got %+v\nwant %+v", entries, want)
+	}
+}
+
+func TestReadManifestList(t *testing.T) {
+	metadata := "file:/var/lib/iceberg/warehouse/my_data/ventes/metadata/"
+	tests := []struct {
+		file string
+		want []ManifestFile
+	}{
+		// v1 names the counts added_data_files_count and so on, and older writers leave
+		// them null
+		{"snap-v1.avro", []ManifestFile{
+			{Path: metadata + "7d3f0b9a-m0.avro", Length: 6180, AddedSnapshotID: 5293004537813459370,
+				AddedFilesCount: 1, ExistingFilesCount: 1, DeletedFilesCount: 1, AddedRowsCount: 3, ExistingRowsCount: 7, DeletedRowsCount: 4},
+			{Path: metadata + "1a2b3c4d-m0.avro", Length: 5120, AddedSnapshotID: 5293004537813459001},
+		}},
+		{"snap-v2.avro", []ManifestFile{
+			{Path: metadata + "9e8d7c6b-m0.avro", Length: 4062, SequenceNumber: 2, MinSequenceNumber: 1, AddedSnapshotID: 3051729675574597004,
+				AddedFilesCount: 1, ExistingFilesCount: 1, DeletedFilesCount: 1, AddedRowsCount: 2, ExistingRowsCount: 1, DeletedRowsCount: 5},
+			{Path: metadata + "9e8d7c6b-m1.avro", Length: 3010, Content: ManifestContentDeletes, SequenceNumber: 2, MinSequenceNumber: 2,
+				AddedSnapshotID: 3051729675574597004, AddedFilesCount: 1, AddedRowsCount: 2},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			io := NewFileIO(t.TempDir(), "file:/warehouse")
+			manifests, err := io.ReadManifestList(testdataPath(t, test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(manifests, test.want) {
+				t.Errorf("manifests\n got %+v\nwant %+v", manifests, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package iceberg
+
+// Field represents a field in an Iceberg schema
+type Field struct {
+	ID       int         `json:"id"`
+	Name     string      `json:"name"`
+	Required bool        `json:"required"`
+	Type     interface{} `json:"type"`
+	Doc      string      `json:"doc,omitempty"`
+}
+
+// Schema represents an Iceberg table schema
+type Schema struct {
+	Type               string  `json:"type"`
+	SchemaID           int     `json:"schema-id"`
+	IdentifierFieldIDs []int   `json:"identifier-field-ids,omitempty"`
+	Fields             []Field `json:"fields"`
+}
+
+// FieldByID returns the top-level field with the given ID, or nil
+func (s *Schema) FieldByID(id int) *Field {
+	for i := range s.Fields {
+		if s.Fields[i].ID == id {
+			return &s.Fields[i]
+		}
+	}
+	return nil
+}
+
+// FieldByName returns the top-level field with the given name, or nil
+func (s *Schema) FieldByName(name string) *Field {
+	for i := range s.Fields {
+		if s.Fields[i].Name == name {
+			return &s.Fields[i]
+		}
+	}
+	return nil
+}
+
+// PartitionField represents a single field of a partition spec
+type PartitionField struct {
+	SourceID  int    `json:"source-id"`
+	FieldID   int    `json:"field-id"`
+	Name      string `json:"name"`
+	Transform string `json:"transform"`
+}
+
+// PartitionSpec represents an Iceberg partition spec
+type PartitionSpec struct {
+	SpecID int              `json:"spec-id"`
+	Fields []PartitionField `json:"fields"`
+}
+
+// Snapshot represents a snapshot entry of the table metadata
+type Snapshot struct {
+	SnapshotID       int64             `json:"snapshot-id"`
+	ParentSnapshotID *int64            `json:"parent-snapshot-id,omitempty"`
+	SequenceNumber   int64             `json:"sequence-number,omitempty"`
+	TimestampMs      int64             `json:"timestamp-ms"`
+	ManifestList     string            `json:"manifest-list,omitempty"`
+	Manifests        []string          `json:"manifests,omitempty"`
+	Summary          map[string]string `json:"summary,omitempty"`
+	SchemaID         *int              `json:"schema-id,omitempty"`
+}
+
+// SnapshotRef represents a named branch or tag pointing at a snapshot
+type SnapshotRef struct {
+	SnapshotID         int64  `json:"snapshot-id"`
+	Type               string `json:"type"`
+	MinSnapshotsToKeep *int   `json:"min-snapshots-to-keep,omitempty"`
+	MaxSnapshotAgeMs   *int64 `json:"max-snapshot-age-ms,omitempty"`
+	MaxRefAgeMs        *int64 `json:"max-ref-age-ms,omitempty"`
+}
+
+// SnapshotLogEntry records when a snapshot became the current one
+type SnapshotLogEntry struct {
+	SnapshotID  int64 `json:"snapshot-id"`
+	TimestampMs int64 `json:"timestamp-ms"`
+}
+
+// MetadataLogEntry records a previous metadata file of the table
+type MetadataLogEntry struct {
+	MetadataFile string `json:"metadata-file"`
+	TimestampMs  int64  `json:"timestamp-ms"`
+}
+
+// StatisticsFile references a Puffin statistics file of a snapshot
+type StatisticsFile struct {
+	SnapshotID     int64  `json:"snapshot-id"`
+	StatisticsPath string `json:"statistics-path"`
+}
+
+// PartitionStatisticsFile references a partition statistics file of a snapshot
+type PartitionStatisticsFile struct {
+	SnapshotID     int64  `json:"snapshot-id"`
+	StatisticsPath string `json:"statistics-path"`
+}
+
+// TableMetadata represents the Iceberg table metadata returned by the catalog
+type TableMetadata struct {
+	FormatVersion       int                       `json:"format-version"`
+	TableUUID           string                    `json:"table-uuid"`
+	Location            string                    `json:"location"`
+	LastSequenceNumber  int64                     `json:"last-sequence-number"`
+	LastUpdatedMs       int64                     `json:"last-updated-ms"`
+	LastColumnID        int                       `json:"last-column-id"`
+	CurrentSchemaID     int                       `json:"current-schema-id"`
+	Schemas             []Schema                  `json:"schemas"`
+	DefaultSpecID       int                       `json:"default-spec-id"`
+	PartitionSpecs      []PartitionSpec           `json:"partition-specs"`
+	LastPartitionID     int                       `json:"last-partition-id"`
+	Properties          map[string]string         `json:"properties,omitempty"`
+	CurrentSnapshotID   *int64                    `json:"current-snapshot-id,omitempty"`
+	Snapshots           []Snapshot                `json:"snapshots,omitempty"`
+	SnapshotLog         []SnapshotLogEntry        `json:"snapshot-log,omitempty"`
+	MetadataLog         []MetadataLogEntry        `json:"metadata-log,omitempty"`
+	Refs                map[string]SnapshotRef    `json:"refs,omitempty"`
+	Statistics          []StatisticsFile          `json:"statistics,omitempty"`
+	PartitionStatistics []PartitionStatisticsFile `json:"partition-statistics,omitempty"`
+}
+
+// SnapshotByID returns the snapshot with the given ID, or nil if it does not exist
+func (m *TableMetadata) SnapshotByID(id int64) *Snapshot {
+	for i := range m.Snapshots {
+		if m.Snapshots[i].SnapshotID == id {
+			return &m.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+// CurrentSnapshot returns the snapshot the main branch points at, or nil for an empty table
+func (m *TableMetadata) CurrentSnapshot() *Snapshot {
+	if m.CurrentSnapshotID == nil || *m.CurrentSnapshotID < 0 {
+		return nil
+	}
+	return m.SnapshotByID(*m.CurrentSnapshotID)
+}
+
+// SnapshotAsOf returns the snapshot that was current on the main branch at the given time,
+// or nil if the table had no snapshot yet
+func (m *TableMetadata) SnapshotAsOf(timestampMs int64) *Snapshot {
+	var snapshotID int64
+	found := false
+
+	// The snapshot log records when each snapshot became current, which accounts for rollbacks
+	for _, entry := range m.SnapshotLog {
+		if entry.TimestampMs <= timestampMs {
+			snapshotID = entry.SnapshotID
+			found = true
+		}
+	}
+
+	if !found && len(m.SnapshotLog) == 0 {
+		// Without a snapshot log fall back to the snapshot creation times
+		var latest int64 = -1
+		for _, s := range m.Snapshots {
+			if s.TimestampMs <= timestampMs && s.TimestampMs > latest {
+				latest = s.TimestampMs
+				snapshotID = s.SnapshotID
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return m.SnapshotByID(snapshotID)
+}
+
+// CurrentSchema returns the schema identified by current-schema-id
+func (m *TableMetadata) CurrentSchema() *Schema {
+	for i := range m.Schemas {
+		if m.Schemas[i].SchemaID == m.CurrentSchemaID {
+			return &m.Schemas[i]
+		}
+	}
+	return nil
+}
+
+// DefaultSpec returns the partition spec identified by default-spec-id
+func (m *TableMetadata) DefaultSpec() *PartitionSpec {
+	for i := range m.PartitionSpecs {
+		if m.PartitionSpecs[i].SpecID == m.DefaultSpecID {
+			return &m.PartitionSpecs[i]
+		}
+	}
+	return &PartitionSpec{SpecID: m.DefaultSpecID}
+}
+
+// SpecByID returns the partition spec with the given ID, or nil
+func (m *TableMetadata) SpecByID(id int) *PartitionSpec {
+	for i := range m.PartitionSpecs {
+		if m.PartitionSpecs[i].SpecID == id {
+			return &m.PartitionSpecs[i]
+		}
+	}
+	return nil
+}
+
+// BranchSnapshot returns the snapshot a branch points at, or nil if the branch does not exist
+func (m *TableMetadata) BranchSnapshot(branch string) *Snapshot {
+	if ref, ok := m.Refs[branch]; ok {
+		return m.SnapshotByID(ref.SnapshotID)
+	}
+	if branch == "main" {
+		return m.CurrentSnapshot()
+	}
+	return nil
+}
+
+// IsAncestorOf reports whether ancestorID is snapshotID itself or one of its parents
+func (m *TableMetadata) IsAncestorOf(ancestorID, snapshotID int64) bool {
+	for snapshot := m.SnapshotByID(snapshotID); snapshot != nil; {
+		if snapshot.SnapshotID == ancestorID {
+			return true
+		}
+		if snapshot.ParentSnapshotID == nil {
+			return false
+		}
+		snapshot = m.SnapshotByID(*snapshot.ParentSnapshotID)
+	}
+	return false
+}
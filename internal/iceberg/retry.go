@@ -0,0 +1,143 @@
+package iceberg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy is how the client retries failed requests: with exponential backoff and
+// jitter, waiting as long as the catalog asks when it sends Retry-After
+type RetryPolicy struct {
+	MaxAttempts int           // attempts of a request, including the first
+	Backoff     time.Duration // wait before the first retry, doubled before each next one
+	MaxBackoff  time.Duration // longest wait between two attempts, unless Retry-After asks for more; 0 for the default
+	Jitter      float64       // fraction of each wait that is random, from 0 to 1
+}
+
+// defaultMaxBackoff is the longest wait between two attempts of a policy that sets none
+const defaultMaxBackoff = 30 * time.Second
+
+// DefaultRetryPolicy retries a request 4 times over about 7 seconds
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, Backoff: 500 * time.Millisecond, MaxBackoff: defaultMaxBackoff, Jitter: 0.5}
+
+// delay returns the wait before an attempt, after the given number of failed attempts
+func (p RetryPolicy) delay(failed int) time.Duration {
+	limit := p.MaxBackoff
+	if limit <= 0 {
+		limit = defaultMaxBackoff
+	}
+	wait := p.Backoff
+	for i := 1; i < failed && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+	if p.Jitter > 0 {
+		random := time.Duration(p.Jitter * float64(wait))
+		wait -= time.Duration(rand.Int63n(int64(random) + 1))
+	}
+	return wait
+}
+
+// retryAfter returns the wait a 429 or 503 response asks for in its Retry-After header,
+// given in seconds or as an HTTP date
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// retryable reports whether a failed request may be sent again: after a connection error,
+// a 429 or a 5xx response. Requests changing the catalog are only retried when they
+// certainly had no effect: the connection was never established, or the catalog answered
+// 429 or 503. Their other 5xx responses, such as a 500 or 504 on a commit, leave the
+// outcome unknown, so retrying them could apply a change twice.
+func retryable(method string, err error) bool {
+	var catalogErr *CatalogError
+	if errors.As(err, &catalogErr) {
+		switch {
+		case catalogErr.StatusCode == http.StatusTooManyRequests, catalogErr.StatusCode == http.StatusServiceUnavailable:
+			return true
+		case catalogErr.StatusCode >= 500:
+			return method == http.MethodGet || method == http.MethodHead
+		}
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return method == http.MethodGet || method == http.MethodHead
+	}
+	return false
+}
+
+// sleep waits for a duration, or until the context is done
+func sleep(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retry runs a request until it succeeds, fails for good or runs out of attempts
+func (c *Client) retry(ctx context.Context, method, path string, send func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil || ctx.Err() != nil || attempt >= c.Retry.MaxAttempts || !retryable(method, err) {
+			return err
+		}
+
+		wait := c.Retry.delay(attempt)
+		var catalogErr *CatalogError
+		if errors.As(err, &catalogErr) && catalogErr.RetryAfter > 0 {
+			wait = catalogErr.RetryAfter
+		}
+		slog.Warn("retrying catalog request", "method", method, "path", path, "attempt", attempt, "wait", wait, "error", err)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// RetryCommit runs a commit until it succeeds, fails with another error than a commit
+// conflict or runs out of attempts. When another writer committed first, the commit is
+// run again after a backoff with refresh set: it must reload the table metadata and
+// rebuild its changes and requirements against it.
+func (c *Client) RetryCommit(ctx context.Context, commit func(refresh bool) error) error {
+	for attempt := 1; ; attempt++ {
+		err := commit(attempt > 1)
+		if err == nil || ctx.Err() != nil || attempt >= c.Retry.MaxAttempts || !IsCommitConflict(err) {
+			return err
+		}
+
+		wait := c.Retry.delay(attempt)
+		slog.Warn("commit conflict, retrying against the refreshed table", "attempt", attempt, "wait", wait, "error", err)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
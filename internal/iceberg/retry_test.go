@@ -0,0 +1,164 @@
+package iceberg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		failed int
+		want   time.Duration
+	}{
+		{"first retry", RetryPolicy{Backoff: time.Second, MaxBackoff: time.Minute}, 1, time.Second},
+		{"doubled", RetryPolicy{Backoff: time.Second, MaxBackoff: time.Minute}, 2, 2 * time.Second},
+		{"doubled twice", RetryPolicy{Backoff: time.Second, MaxBackoff: time.Minute}, 3, 4 * time.Second},
+		{"capped", RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}, 4, 5 * time.Second},
+		{"capped without overflowing", RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}, 200, 5 * time.Second},
+		{"backoff above the cap", RetryPolicy{Backoff: 10 * time.Second, MaxBackoff: 5 * time.Second}, 1, 5 * time.Second},
+		{"below the default cap", RetryPolicy{Backoff: time.Second}, 5, 16 * time.Second},
+		{"default cap", RetryPolicy{Backoff: time.Second}, 6, defaultMaxBackoff},
+		{"default cap without overflowing", RetryPolicy{Backoff: time.Second}, 200, defaultMaxBackoff},
+		{"negative cap", RetryPolicy{Backoff: time.Second, MaxBackoff: -time.Second}, 200, defaultMaxBackoff},
+		{"no backoff", RetryPolicy{}, 3, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.policy.delay(test.failed); got != test.want {
+				t.Errorf("delay(%d) = %v, want %v", test.failed, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelayJitter(t *testing.T) {
+	tests := []struct {
+		policy   RetryPolicy
+		failed   int
+		min, max time.Duration
+	}{
+		{RetryPolicy{Backoff: time.Second, MaxBackoff: time.Minute, Jitter: 0.5}, 1, 500 * time.Millisecond, time.Second},
+		{RetryPolicy{Backoff: time.Second, MaxBackoff: time.Minute, Jitter: 0.5}, 3, 2 * time.Second, 4 * time.Second},
+		{RetryPolicy{Backoff: time.Second, MaxBackoff: 3 * time.Second, Jitter: 0.5}, 10, 1500 * time.Millisecond, 3 * time.Second},
+		{RetryPolicy{Backoff: time.Second, MaxBackoff: time.Minute, Jitter: 1}, 1, 0, time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 1000; i++ {
+			if got := test.policy.delay(test.failed); got < test.min || got > test.max {
+				t.Fatalf("%+v: delay(%d) = %v, want between %v and %v", test.policy, test.failed, got, test.min, test.max)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		min, max time.Duration
+	}{
+		{"missing", "", 0, 0},
+		{"seconds", "120", 120 * time.Second, 120 * time.Second},
+		{"zero seconds", "0", 0, 0},
+		{"negative seconds", "-5", 0, 0},
+		{"HTTP date", time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat), 85 * time.Second, 90 * time.Second},
+		{"invalid", "soon", 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			if test.value != "" {
+				header.Set("Retry-After", test.value)
+			}
+			if got := retryAfter(header); got < test.min || got > test.max {
+				t.Errorf("retryAfter(%q) = %v, want between %v and %v", test.value, got, test.min, test.max)
+			}
+		})
+	}
+
+	// A date in the past asks for no wait, which the client ignores in favor of its backoff
+	header := http.Header{"Retry-After": {time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)}}
+	if got := retryAfter(header); got > 0 {
+		t.Errorf("retryAfter of a past date = %v, want no wait", got)
+	}
+}
+
+// timeoutError is a network error such as a read timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	status := func(code int) error { return &CatalogError{StatusCode: code} }
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errors.New("connection refused"))}
+	read := &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}
+
+	tests := []struct {
+		name string
+		err  error
+		// retryable for GET and HEAD, and for POST and DELETE
+		reads, writes bool
+	}{
+		{"429", status(http.StatusTooManyRequests), true, true},
+		{"503", status(http.StatusServiceUnavailable), true, true},
+		{"500", status(http.StatusInternalServerError), true, false},
+		{"502", status(http.StatusBadGateway), true, false},
+		{"504", status(http.StatusGatewayTimeout), true, false},
+		{"400", status(http.StatusBadRequest), false, false},
+		{"404", status(http.StatusNotFound), false, false},
+		{"409", status(http.StatusConflict), false, false},
+		{"wrapped 503", fmt.Errorf("failed to commit: %w", status(http.StatusServiceUnavailable)), true, true},
+		{"connection refused", dial, true, true},
+		{"read timeout", read, true, false},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true, false},
+		{"other error", errors.New("invalid JSON"), false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodDelete} {
+				want := test.writes
+				if method == http.MethodGet || method == http.MethodHead {
+					want = test.reads
+				}
+				if got := retryable(method, test.err); got != want {
+					t.Errorf("retryable(%s, %v) = %v, want %v", method, test.err, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsCommitRejected(t *testing.T) {
+	status := func(code int) error { return &CatalogError{StatusCode: code} }
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"400", status(http.StatusBadRequest), true},
+		{"404", status(http.StatusNotFound), true},
+		{"409", fmt.Errorf("failed to commit: %w", status(http.StatusConflict)), true},
+		{"429", status(http.StatusTooManyRequests), true},
+		{"500", status(http.StatusInternalServerError), false},
+		{"503", status(http.StatusServiceUnavailable), true},
+		{"504", status(http.StatusGatewayTimeout), false},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"read timeout", &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, false},
+		{"unexpected EOF", io.ErrUnexpectedEOF, false},
+	}
+	for _, test := range tests {
+		if got := IsCommitRejected(test.err); got != test.want {
+			t.Errorf("IsCommitRejected(%s) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
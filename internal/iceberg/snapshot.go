@@ -0,0 +1,303 @@
+package iceberg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snapshot operations recorded in the snapshot summary
+const (
+	OperationAppend    = "append"
+	OperationOverwrite = "overwrite"
+	OperationDelete    = "delete"
+)
+
+// SnapshotUpdate stages a new snapshot on top of a branch of a table.
+// Files are written when the update is staged; nothing is visible until the
+// returned TableCommit is sent to the catalog.
+type SnapshotUpdate struct {
+	fileIO    *FileIO
+	metadata  *TableMetadata
+	branch    string
+	operation string
+	added     []DataFile
+	removed   map[string]bool
+	summary   map[string]string
+}
+
+// NewSnapshotUpdate starts a snapshot update of the given operation on a branch
+func (f *FileIO) NewSnapshotUpdate(metadata *TableMetadata, branch, operation string) *SnapshotUpdate {
+	return &SnapshotUpdate{
+		fileIO:    f,
+		metadata:  metadata,
+		branch:    branch,
+		operation: operation,
+		removed:   make(map[string]bool),
+		summary:   make(map[string]string),
+	}
+}
+
+// AddFile adds a data or delete file to the snapshot
+func (u *SnapshotUpdate) AddFile(file DataFile) {
+	u.added = append(u.added, file)
+}
+
+// DeleteFile removes a data or delete file of the branch from the snapshot
+func (u *SnapshotUpdate) DeleteFile(location string) {
+	u.removed[location] = true
+}
+
+// SetSummaryProperty sets an additional property of the snapshot summary
+func (u *SnapshotUpdate) SetSummaryProperty(key, value string) {
+	u.summary[key] = value
+}
+
+// NewDataFileLocation returns the location of a new Parquet file in the table's data directory
+func NewDataFileLocation(metadata *TableMetadata) string {
+	return strings.TrimRight(metadata.Location, "/") + "/data/" + newUUID() + ".parquet"
+}
+
+// Parent returns the snapshot the new snapshot builds on: the head of the branch, or the
+// current snapshot of main when the branch does not exist yet
+func (u *SnapshotUpdate) Parent() *Snapshot {
+	if _, exists := u.metadata.Refs[u.branch]; !exists {
+		return u.metadata.CurrentSnapshot()
+	}
+	return u.metadata.BranchSnapshot(u.branch)
+}
+
+// metadataLocation returns the location of a new file in the table's metadata directory
+func (u *SnapshotUpdate) metadataLocation(name string) string {
+	return strings.TrimRight(u.metadata.Location, "/") + "/metadata/" + name
+}
+
+// snapshotSummary accumulates the counters of a snapshot summary
+type snapshotSummary map[string]int64
+
+// addFile counts a file added by the snapshot
+func (s snapshotSummary) addFile(file DataFile) {
+	s["added-files-size"] += file.FileSizeInBytes
+	switch file.Content {
+	case FileContentData:
+		s["added-data-files"]++
+		s["added-records"] += file.RecordCount
+	case FileContentPositionDeletes:
+		s["added-delete-files"]++
+		s["added-position-delete-files"]++
+		s["added-position-deletes"] += file.RecordCount
+	case FileContentEqualityDeletes:
+		s["added-delete-files"]++
+		s["added-equality-delete-files"]++
+		s["added-equality-deletes"] += file.RecordCount
+	}
+}
+
+// removeFile counts a file removed by the snapshot
+func (s snapshotSummary) removeFile(file DataFile) {
+	s["removed-files-size"] += file.FileSizeInBytes
+	switch file.Content {
+	case FileContentData:
+		s["deleted-data-files"]++
+		s["deleted-records"] += file.RecordCount
+	case FileContentPositionDeletes:
+		s["removed-delete-files"]++
+		s["removed-position-delete-files"]++
+		s["removed-position-deletes"] += file.RecordCount
+	case FileContentEqualityDeletes:
+		s["removed-delete-files"]++
+		s["removed-equality-delete-files"]++
+		s["removed-equality-deletes"] += file.RecordCount
+	}
+}
+
+// totalSummaryKeys maps the running totals of a summary to the counters that change them
+var totalSummaryKeys = []struct {
+	total, added, removed string
+}{
+	{"total-data-files", "added-data-files", "deleted-data-files"},
+	{"total-delete-files", "added-delete-files", "removed-delete-files"},
+	{"total-records", "added-records", "deleted-records"},
+	{"total-files-size", "added-files-size", "removed-files-size"},
+	{"total-position-deletes", "added-position-deletes", "removed-position-deletes"},
+	{"total-equality-deletes", "added-equality-deletes", "removed-equality-deletes"},
+}
+
+// build renders the summary, carrying the totals forward from the parent snapshot
+func (s snapshotSummary) build(operation string, parent *Snapshot, extra map[string]string) map[string]string {
+	summary := map[string]string{"operation": operation}
+	for key, value := range s {
+		if value != 0 {
+			summary[key] = strconv.FormatInt(value, 10)
+		}
+	}
+
+	for _, keys := range totalSummaryKeys {
+		var previous int64
+		if parent != nil {
+			// Totals are only meaningful if the parent tracked them too
+			value, ok := parent.Summary[keys.total]
+			if !ok {
+				continue
+			}
+			previous, _ = strconv.ParseInt(value, 10, 64)
+		}
+		summary[keys.total] = strconv.FormatInt(previous+s[keys.added]-s[keys.removed], 10)
+	}
+
+	for key, value := range extra {
+		summary[key] = value
+	}
+
+	return summary
+}
+
+// Stage writes the manifests and manifest list of the new snapshot and returns
+// the catalog commit that adds it and moves the branch to it
+func (u *SnapshotUpdate) Stage() (*TableCommit, *Snapshot, error) {
+	if u.metadata.FormatVersion < 2 {
+		return nil, nil, fmt.Errorf("writing to format version %d tables is not supported", u.metadata.FormatVersion)
+	}
+
+	schema := u.metadata.CurrentSchema()
+	if schema == nil {
+		return nil, nil, fmt.Errorf("table metadata has no current schema")
+	}
+	spec := u.metadata.DefaultSpec()
+
+	if ref, ok := u.metadata.Refs[u.branch]; ok && ref.Type != "branch" {
+		return nil, nil, fmt.Errorf("%s is a %s, snapshots can only be added to branches", u.branch, ref.Type)
+	}
+
+	parent := u.Parent()
+	var branchHead *int64
+	if head := u.metadata.BranchSnapshot(u.branch); head != nil {
+		branchHead = &head.SnapshotID
+	}
+
+	snapshot := &Snapshot{
+		SnapshotID:     NewSnapshotID(),
+		SequenceNumber: u.metadata.LastSequenceNumber + 1,
+		TimestampMs:    time.Now().UnixMilli(),
+		SchemaID:       &schema.SchemaID,
+	}
+
+	var existing []ManifestFile
+	if parent != nil {
+		snapshot.ParentSnapshotID = &parent.SnapshotID
+
+		if parent.ManifestList == "" {
+			return nil, nil, fmt.Errorf("parent snapshot %d has no manifest list", parent.SnapshotID)
+		}
+		manifests, err := u.fileIO.ReadManifestList(parent.ManifestList)
+		if err != nil {
+			return nil, nil, err
+		}
+		existing = manifests
+	}
+
+	counters := snapshotSummary{}
+	var dataEntries, deleteEntries []ManifestEntry
+	for _, file := range u.added {
+		counters.addFile(file)
+		entry := ManifestEntry{Status: EntryStatusAdded, DataFile: file}
+		if file.Content == FileContentData {
+			dataEntries = append(dataEntries, entry)
+		} else {
+			deleteEntries = append(deleteEntries, entry)
+		}
+	}
+
+	// New manifests go first, followed by the unchanged manifests of the parent
+	var manifests []ManifestFile
+	manifestUUID := newUUID()
+	for i, group := range []struct {
+		content int32
+		entries []ManifestEntry
+	}{
+		{ManifestContentData, dataEntries},
+		{ManifestContentDeletes, deleteEntries},
+	} {
+		if len(group.entries) == 0 {
+			continue
+		}
+		location := u.metadataLocation(fmt.Sprintf("%s-m%d.avro", manifestUUID, i))
+		manifest, err := u.fileIO.WriteManifest(location, u.metadata, spec, group.content, snapshot.SnapshotID, snapshot.SequenceNumber, group.entries)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	// Manifests holding removed files are rewritten with those entries marked deleted
+	found := 0
+	for i, manifest := range existing {
+		if len(u.removed) == 0 {
+			manifests = append(manifests, manifest)
+			continue
+		}
+
+		entries, err := u.fileIO.ReadLiveEntries(manifest)
+		if err != nil {
+			return nil, nil, err
+		}
+		var rewritten []ManifestEntry
+		changed := false
+		for _, entry := range entries {
+			entry.Status = EntryStatusExisting
+			if u.removed[entry.DataFile.FilePath] {
+				entry.Status = EntryStatusDeleted
+				counters.removeFile(entry.DataFile)
+				changed = true
+				found++
+			}
+			rewritten = append(rewritten, entry)
+		}
+		if !changed {
+			manifests = append(manifests, manifest)
+			continue
+		}
+
+		manifestSpec := u.metadata.SpecByID(int(manifest.PartitionSpecID))
+		if manifestSpec == nil {
+			return nil, nil, fmt.Errorf("manifest %s uses unknown partition spec %d", manifest.Path, manifest.PartitionSpecID)
+		}
+		location := u.metadataLocation(fmt.Sprintf("%s-m%d.avro", manifestUUID, i+2))
+		replacement, err := u.fileIO.WriteManifest(location, u.metadata, manifestSpec, manifest.Content, snapshot.SnapshotID, snapshot.SequenceNumber, rewritten)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifests = append(manifests, replacement)
+	}
+	if found != len(u.removed) {
+		return nil, nil, fmt.Errorf("%d of the files to remove are not part of branch %s", len(u.removed)-found, u.branch)
+	}
+
+	snapshot.ManifestList = u.metadataLocation(fmt.Sprintf("snap-%d-1-%s.avro", snapshot.SnapshotID, manifestUUID))
+	if err := u.fileIO.WriteManifestList(snapshot.ManifestList, snapshot, manifests); err != nil {
+		return nil, nil, err
+	}
+
+	snapshot.Summary = counters.build(u.operation, parent, u.summary)
+
+	// Keep the retention settings of an existing branch
+	ref := SnapshotRef{Type: "branch"}
+	if existingRef, ok := u.metadata.Refs[u.branch]; ok {
+		ref = existingRef
+	}
+	ref.SnapshotID = snapshot.SnapshotID
+
+	commit := &TableCommit{
+		Requirements: []TableRequirement{
+			AssertTableUUID(u.metadata.TableUUID),
+			AssertRefSnapshotID(u.branch, branchHead),
+		},
+		Updates: []TableUpdate{
+			AddSnapshot(snapshot),
+			SetSnapshotRef(u.branch, ref),
+		},
+	}
+
+	return commit, snapshot, nil
+}
@@ -0,0 +1,154 @@
+package iceberg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ViewRepresentation is the SQL text of a view in one SQL dialect
+type ViewRepresentation struct {
+	Type    string `json:"type"`
+	SQL     string `json:"sql"`
+	Dialect string `json:"dialect"`
+}
+
+// ViewVersion is one version of a view definition
+type ViewVersion struct {
+	VersionID        int                  `json:"version-id"`
+	TimestampMs      int64                `json:"timestamp-ms"`
+	SchemaID         int                  `json:"schema-id"`
+	Summary          map[string]string    `json:"summary"`
+	Representations  []ViewRepresentation `json:"representations"`
+	DefaultCatalog   string               `json:"default-catalog,omitempty"`
+	DefaultNamespace []string             `json:"default-namespace"`
+}
+
+// ViewMetadata represents the metadata of an Iceberg view
+type ViewMetadata struct {
+	ViewUUID         string            `json:"view-uuid"`
+	FormatVersion    int               `json:"format-version"`
+	Location         string            `json:"location"`
+	CurrentVersionID int               `json:"current-version-id"`
+	Versions         []ViewVersion     `json:"versions"`
+	Schemas          []Schema          `json:"schemas"`
+	Properties       map[string]string `json:"properties,omitempty"`
+}
+
+// CurrentVersion returns the current version of the view, or nil if it is missing
+func (m *ViewMetadata) CurrentVersion() *ViewVersion {
+	for i := range m.Versions {
+		if m.Versions[i].VersionID == m.CurrentVersionID {
+			return &m.Versions[i]
+		}
+	}
+	return nil
+}
+
+// SchemaByID returns the view schema with the given ID, or nil if it is missing
+func (m *ViewMetadata) SchemaByID(id int) *Schema {
+	for i := range m.Schemas {
+		if m.Schemas[i].SchemaID == id {
+			return &m.Schemas[i]
+		}
+	}
+	return nil
+}
+
+// LoadViewResult is the catalog response for a view load
+type LoadViewResult struct {
+	MetadataLocation string            `json:"metadata-location"`
+	Metadata         ViewMetadata      `json:"metadata"`
+	Config           map[string]string `json:"config,omitempty"`
+}
+
+// CreateViewRequest is the request body for creating a view
+type CreateViewRequest struct {
+	Name        string            `json:"name"`
+	Location    string            `json:"location,omitempty"`
+	Schema      Schema            `json:"schema"`
+	ViewVersion ViewVersion       `json:"view-version"`
+	Properties  map[string]string `json:"properties"`
+}
+
+// IsNotFound reports whether err is a 404 response for a missing namespace, table or view
+func IsNotFound(err error) bool {
+	var catalogErr *CatalogError
+	return errors.As(err, &catalogErr) && catalogErr.StatusCode == http.StatusNotFound &&
+		strings.HasPrefix(catalogErr.Type, "NoSuch")
+}
+
+// viewPath builds the REST path of a view
+func viewPath(namespace, view string) string {
+	return fmt.Sprintf("/v1/namespaces/%s/views/%s", url.PathEscape(namespace), url.PathEscape(view))
+}
+
+// ListViews returns the names of the views in a namespace
+func (c *Client) ListViews(ctx context.Context, namespace string) ([]string, error) {
+	var resp struct {
+		Identifiers []struct {
+			Name string `json:"name"`
+		} `json:"identifiers"`
+	}
+	path := fmt.Sprintf("/v1/namespaces/%s/views", url.PathEscape(namespace))
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list views in %s: %w", namespace, err)
+	}
+
+	var views []string
+	for _, id := range resp.Identifiers {
+		views = append(views, id.Name)
+	}
+	return views, nil
+}
+
+// LoadView loads the current metadata of a view
+func (c *Client) LoadView(ctx context.Context, namespace, view string) (*LoadViewResult, error) {
+	var result LoadViewResult
+	if err := c.do(ctx, http.MethodGet, viewPath(namespace, view), nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to load view %s.%s: %w", namespace, view, err)
+	}
+	return &result, nil
+}
+
+// CreateView creates a view in a namespace
+func (c *Client) CreateView(ctx context.Context, namespace string, request *CreateViewRequest) (*LoadViewResult, error) {
+	var result LoadViewResult
+	path := fmt.Sprintf("/v1/namespaces/%s/views", url.PathEscape(namespace))
+	if err := c.do(ctx, http.MethodPost, path, request, &result); err != nil {
+		return nil, fmt.Errorf("failed to create view %s.%s: %w", namespace, request.Name, err)
+	}
+	return &result, nil
+}
+
+// AssertViewUUID requires the view to still be the one that was loaded
+func AssertViewUUID(uuid string) TableRequirement {
+	return TableRequirement{"type": "assert-view-uuid", "uuid": uuid}
+}
+
+// AddViewSchema adds a new schema to a view
+func AddViewSchema(schema *Schema) TableUpdate {
+	return TableUpdate{"action": "add-schema", "schema": schema}
+}
+
+// AddViewVersion adds a new version to a view; a schema ID of -1 selects the schema added last
+func AddViewVersion(version *ViewVersion) TableUpdate {
+	return TableUpdate{"action": "add-view-version", "view-version": version}
+}
+
+// SetCurrentViewVersion makes a version the current one; -1 selects the version added last
+func SetCurrentViewVersion(versionID int) TableUpdate {
+	return TableUpdate{"action": "set-current-view-version", "view-version-id": versionID}
+}
+
+// ReplaceView applies updates to a view once all requirements hold
+func (c *Client) ReplaceView(ctx context.Context, namespace, view string, commit *TableCommit) (*LoadViewResult, error) {
+	var result LoadViewResult
+	if err := c.do(ctx, http.MethodPost, viewPath(namespace, view), commit, &result); err != nil {
+		return nil, fmt.Errorf("failed to replace view %s.%s: %w", namespace, view, err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,317 @@
+package iceberg
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// manifestListSchema is the Avro schema of format v2 manifest lists
+const manifestListSchema = `{
+	"type": "record",
+	"name": "manifest_file",
+	"fields": [
+		{"name": "manifest_path", "type": "string", "field-id": 500},
+		{"name": "manifest_length", "type": "long", "field-id": 501},
+		{"name": "partition_spec_id", "type": "int", "field-id": 502},
+		{"name": "content", "type": "int", "field-id": 517},
+		{"name": "sequence_number", "type": "long", "field-id": 515},
+		{"name": "min_sequence_number", "type": "long", "field-id": 516},
+		{"name": "added_snapshot_id", "type": "long", "field-id": 503},
+		{"name": "added_files_count", "type": "int", "field-id": 504},
+		{"name": "existing_files_count", "type": "int", "field-id": 505},
+		{"name": "deleted_files_count", "type": "int", "field-id": 506},
+		{"name": "added_rows_count", "type": "long", "field-id": 512},
+		{"name": "existing_rows_count", "type": "long", "field-id": 513},
+		{"name": "deleted_rows_count", "type": "long", "field-id": 514}
+	]
+}`
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// NewSnapshotID returns a random positive snapshot ID
+func NewSnapshotID() int64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return int64(binary.BigEndian.Uint64(b[:]) & (1<<63 - 1))
+}
+
+// avroPrimitiveType returns the Avro encoding of an Iceberg primitive type
+func avroPrimitiveType(icebergType interface{}) (interface{}, error) {
+	t, _ := icebergType.(string)
+	switch {
+	case t == "boolean" || t == "int" || t == "long" || t == "float" || t == "double" || t == "string":
+		return t, nil
+	case t == "binary":
+		return "bytes", nil
+	case t == "date":
+		return map[string]interface{}{"type": "int", "logicalType": "date"}, nil
+	case t == "time":
+		return map[string]interface{}{"type": "long", "logicalType": "time-micros"}, nil
+	case t == "timestamp":
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-micros", "adjust-to-utc": false}, nil
+	case t == "timestamptz":
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-micros", "adjust-to-utc": true}, nil
+	}
+	return nil, fmt.Errorf("unsupported partition source type %v", icebergType)
+}
+
+// partitionAvroType returns the Avro type of a partition field's values
+func partitionAvroType(schema *Schema, field PartitionField) (interface{}, error) {
+	switch {
+	case field.Transform == "year" || field.Transform == "month" || field.Transform == "hour" ||
+		strings.HasPrefix(field.Transform, "bucket"):
+		return "int", nil
+	case field.Transform == "day":
+		return map[string]interface{}{"type": "int", "logicalType": "date"}, nil
+	}
+
+	// identity, truncate and void keep the source column type
+	for _, f := range schema.Fields {
+		if f.ID == field.SourceID {
+			return avroPrimitiveType(f.Type)
+		}
+	}
+	return nil, fmt.Errorf("partition field %s references unknown column %d", field.Name, field.SourceID)
+}
+
+// statsMapType returns the Avro type of an optional map of column stats, encoded as an
+// array of key-value records the way Iceberg encodes maps with non-string keys
+func statsMapType(keyID, valueID int, valueType string) []interface{} {
+	return []interface{}{"null", map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "record",
+			"name": fmt.Sprintf("k%d_v%d", keyID, valueID),
+			"fields": []interface{}{
+				map[string]interface{}{"name": "key", "type": "int", "field-id": keyID},
+				map[string]interface{}{"name": "value", "type": valueType, "field-id": valueID},
+			},
+		},
+		"logicalType": "map",
+	}}
+}
+
+// manifestEntrySchema builds the Avro schema of format v2 manifest entries for a partition spec
+func manifestEntrySchema(schema *Schema, spec *PartitionSpec) (string, error) {
+	partitionFields := []interface{}{}
+	for _, field := range spec.Fields {
+		avroType, err := partitionAvroType(schema, field)
+		if err != nil {
+			return "", err
+		}
+		partitionFields = append(partitionFields, map[string]interface{}{
+			"name":     field.Name,
+			"type":     []interface{}{"null", avroType},
+			"default":  nil,
+			"field-id": field.FieldID,
+		})
+	}
+
+	dataFile := map[string]interface{}{
+		"type": "record",
+		"name": "r2",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "content", "type": "int", "field-id": 134},
+			map[string]interface{}{"name": "file_path", "type": "string", "field-id": 100},
+			map[string]interface{}{"name": "file_format", "type": "string", "field-id": 101},
+			map[string]interface{}{"name": "partition", "type": map[string]interface{}{
+				"type": "record", "name": "r102", "fields": partitionFields,
+			}, "field-id": 102},
+			map[string]interface{}{"name": "record_count", "type": "long", "field-id": 103},
+			map[string]interface{}{"name": "file_size_in_bytes", "type": "long", "field-id": 104},
+			map[string]interface{}{"name": "column_sizes", "type": statsMapType(117, 118, "long"), "default": nil, "field-id": 108},
+			map[string]interface{}{"name": "value_counts", "type": statsMapType(119, 120, "long"), "default": nil, "field-id": 109},
+			map[string]interface{}{"name": "null_value_counts", "type": statsMapType(121, 122, "long"), "default": nil, "field-id": 110},
+			map[string]interface{}{"name": "nan_value_counts", "type": statsMapType(138, 139, "long"), "default": nil, "field-id": 137},
+			map[string]interface{}{"name": "lower_bounds", "type": statsMapType(126, 127, "bytes"), "default": nil, "field-id": 125},
+			map[string]interface{}{"name": "upper_bounds", "type": statsMapType(129, 130, "bytes"), "default": nil, "field-id": 128},
+			map[string]interface{}{"name": "key_metadata", "type": []interface{}{"null", "bytes"}, "default": nil, "field-id": 131},
+			map[string]interface{}{"name": "split_offsets", "type": []interface{}{"null", map[string]interface{}{
+				"type": "array", "items": "long", "element-id": 133,
+			}}, "default": nil, "field-id": 132},
+			map[string]interface{}{"name": "equality_ids", "type": []interface{}{"null", map[string]interface{}{
+				"type": "array", "items": "int", "element-id": 136,
+			}}, "default": nil, "field-id": 135},
+			map[string]interface{}{"name": "sort_order_id", "type": []interface{}{"null", "int"}, "default": nil, "field-id": 140},
+		},
+	}
+
+	entry := map[string]interface{}{
+		"type": "record",
+		"name": "manifest_entry",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "status", "type": "int", "field-id": 0},
+			map[string]interface{}{"name": "snapshot_id", "type": []interface{}{"null", "long"}, "default": nil, "field-id": 1},
+			map[string]interface{}{"name": "sequence_number", "type": []interface{}{"null", "long"}, "default": nil, "field-id": 3},
+			map[string]interface{}{"name": "file_sequence_number", "type": []interface{}{"null", "long"}, "default": nil, "field-id": 4},
+			map[string]interface{}{"name": "data_file", "type": dataFile, "field-id": 2},
+		},
+	}
+
+	data, err := json.Marshal(entry)
+	return string(data), err
+}
+
+// WriteManifest writes a format v2 manifest for the given entries. Added entries get the
+// snapshot ID and inherit the sequence number from the manifest list, as the spec requires.
+func (f *FileIO) WriteManifest(location string, metadata *TableMetadata, spec *PartitionSpec, content int32, snapshotID, sequenceNumber int64, entries []ManifestEntry) (ManifestFile, error) {
+	schema := metadata.CurrentSchema()
+	if schema == nil {
+		return ManifestFile{}, fmt.Errorf("table metadata has no current schema")
+	}
+
+	avroSchemaJSON, err := manifestEntrySchema(schema, spec)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	manifest := ManifestFile{
+		Path:              location,
+		PartitionSpecID:   int32(spec.SpecID),
+		Content:           content,
+		SequenceNumber:    sequenceNumber,
+		MinSequenceNumber: sequenceNumber,
+		AddedSnapshotID:   snapshotID,
+	}
+
+	var records []map[string]interface{}
+	for _, entry := range entries {
+		partition := make(map[string]interface{})
+		for _, field := range spec.Fields {
+			partition[field.Name] = entry.DataFile.Partition[field.Name]
+		}
+
+		var equalityIDs interface{}
+		if len(entry.DataFile.EqualityIDs) > 0 {
+			ids := make([]interface{}, len(entry.DataFile.EqualityIDs))
+			for i, id := range entry.DataFile.EqualityIDs {
+				ids[i] = int32(id)
+			}
+			equalityIDs = ids
+		}
+
+		// Files read from a manifest keep the stats of their record, new files have none
+		dataFile := make(map[string]interface{}, len(entry.DataFile.record))
+		for name, value := range entry.DataFile.record {
+			dataFile[name] = value
+		}
+		dataFile["content"] = entry.DataFile.Content
+		dataFile["file_path"] = entry.DataFile.FilePath
+		dataFile["file_format"] = entry.DataFile.FileFormat
+		dataFile["partition"] = partition
+		dataFile["record_count"] = entry.DataFile.RecordCount
+		dataFile["file_size_in_bytes"] = entry.DataFile.FileSizeInBytes
+		dataFile["equality_ids"] = equalityIDs
+
+		record := map[string]interface{}{
+			"status":    entry.Status,
+			"data_file": dataFile,
+		}
+
+		switch entry.Status {
+		case EntryStatusAdded:
+			record["snapshot_id"] = snapshotID
+			record["sequence_number"] = nil
+			record["file_sequence_number"] = nil
+			manifest.AddedFilesCount++
+			manifest.AddedRowsCount += entry.DataFile.RecordCount
+		default:
+			// Existing and deleted entries keep the sequence numbers they were written with
+			record["snapshot_id"] = entry.SnapshotID
+			record["sequence_number"] = entry.SequenceNumber
+			record["file_sequence_number"] = entry.FileSequenceNumber
+			if entry.Status == EntryStatusDeleted {
+				record["snapshot_id"] = snapshotID
+				manifest.DeletedFilesCount++
+				manifest.DeletedRowsCount += entry.DataFile.RecordCount
+			} else {
+				manifest.ExistingFilesCount++
+				manifest.ExistingRowsCount += entry.DataFile.RecordCount
+			}
+			if entry.SequenceNumber < manifest.MinSequenceNumber {
+				manifest.MinSequenceNumber = entry.SequenceNumber
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	specJSON, err := json.Marshal(spec.Fields)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	contentName := "data"
+	if content == ManifestContentDeletes {
+		contentName = "deletes"
+	}
+
+	length, err := writeAvroFile(f.LocalPath(location), avroSchemaJSON, map[string]string{
+		"schema":            string(schemaJSON),
+		"schema-id":         strconv.Itoa(schema.SchemaID),
+		"partition-spec":    string(specJSON),
+		"partition-spec-id": strconv.Itoa(spec.SpecID),
+		"format-version":    "2",
+		"content":           contentName,
+	}, records)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("failed to write manifest %s: %v", location, err)
+	}
+	manifest.Length = length
+
+	return manifest, nil
+}
+
+// WriteManifestList writes the format v2 manifest list of a snapshot
+func (f *FileIO) WriteManifestList(location string, snapshot *Snapshot, manifests []ManifestFile) error {
+	var records []map[string]interface{}
+	for _, m := range manifests {
+		records = append(records, map[string]interface{}{
+			"manifest_path":        m.Path,
+			"manifest_length":      m.Length,
+			"partition_spec_id":    m.PartitionSpecID,
+			"content":              m.Content,
+			"sequence_number":      m.SequenceNumber,
+			"min_sequence_number":  m.MinSequenceNumber,
+			"added_snapshot_id":    m.AddedSnapshotID,
+			"added_files_count":    m.AddedFilesCount,
+			"existing_files_count": m.ExistingFilesCount,
+			"deleted_files_count":  m.DeletedFilesCount,
+			"added_rows_count":     m.AddedRowsCount,
+			"existing_rows_count":  m.ExistingRowsCount,
+			"deleted_rows_count":   m.DeletedRowsCount,
+		})
+	}
+
+	parentID := "null"
+	if snapshot.ParentSnapshotID != nil {
+		parentID = strconv.FormatInt(*snapshot.ParentSnapshotID, 10)
+	}
+
+	_, err := writeAvroFile(f.LocalPath(location), manifestListSchema, map[string]string{
+		"snapshot-id":        strconv.FormatInt(snapshot.SnapshotID, 10),
+		"parent-snapshot-id": parentID,
+		"sequence-number":    strconv.FormatInt(snapshot.SequenceNumber, 10),
+		"format-version":     "2",
+	}, records)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest list %s: %v", location, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,326 @@
+package iceberg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withoutRecords drops the data_file records the entries were read from, to compare them
+// with entries built by hand
+func withoutRecords(entries []ManifestEntry) []ManifestEntry {
+	for i := range entries {
+		entries[i].DataFile.record = nil
+	}
+	return entries
+}
+
+// testTable returns the metadata of a table with an id and a partition column, and a spec
+// partitioning it by year
+func testTable() (*TableMetadata, *PartitionSpec) {
+	metadata := &TableMetadata{
+		FormatVersion:   2,
+		CurrentSchemaID: 0,
+		Schemas: []Schema{{
+			Type:     "struct",
+			SchemaID: 0,
+			Fields: []Field{
+				{ID: 1, Name: "id", Type: "long"},
+				{ID: 2, Name: "annee", Type: "int"},
+			},
+		}},
+		PartitionSpecs: []PartitionSpec{{SpecID: 0, Fields: []PartitionField{
+			{SourceID: 2, FieldID: 1000, Name: "annee", Transform: "identity"},
+		}}},
+	}
+	return metadata, &metadata.PartitionSpecs[0]
+}
+
+func TestWriteManifestRoundTrip(t *testing.T) {
+	metadata, spec := testTable()
+	unpartitioned := &PartitionSpec{SpecID: 1}
+
+	tests := []struct {
+		name           string
+		spec           *PartitionSpec
+		content        int32
+		entries        []ManifestEntry
+		want           []ManifestEntry
+		wantManifest   ManifestFile
+		sequenceNumber int64
+	}{
+		{
+			name:    "added data files",
+			spec:    spec,
+			content: ManifestContentData,
+			entries: []ManifestEntry{
+				{Status: EntryStatusAdded, DataFile: DataFile{FilePath: "/w/a.parquet", FileFormat: "PARQUET",
+					Partition: map[string]interface{}{"annee": int32(2023)}, RecordCount: 10, FileSizeInBytes: 100}},
+				{Status: EntryStatusAdded, DataFile: DataFile{FilePath: "/w/b.parquet", FileFormat: "PARQUET",
+					Partition: map[string]interface{}{"annee": nil}, RecordCount: 5, FileSizeInBytes: 50}},
+			},
+			sequenceNumber: 3,
+			want: []ManifestEntry{
+				{Status: EntryStatusAdded, SnapshotID: 42, DataFile: DataFile{FilePath: "/w/a.parquet", FileFormat: "PARQUET",
+					Partition: map[string]interface{}{"annee": int32(2023)}, RecordCount: 10, FileSizeInBytes: 100}},
+				{Status: EntryStatusAdded, SnapshotID: 42, DataFile: DataFile{FilePath: "/w/b.parquet", FileFormat: "PARQUET",
+					Partition: map[string]interface{}{"annee": nil}, RecordCount: 5, FileSizeInBytes: 50}},
+			},
+			wantManifest: ManifestFile{PartitionSpecID: 0, Content: ManifestContentData, SequenceNumber: 3, MinSequenceNumber: 3,
+				AddedSnapshotID: 42, AddedFilesCount: 2, AddedRowsCount: 15},
+		},
+		{
+			name:    "existing and deleted files keep their sequence numbers",
+			spec:    unpartitioned,
+			content: ManifestContentData,
+			entries: []ManifestEntry{
+				{Status: EntryStatusExisting, SnapshotID: 7, SequenceNumber: 1, FileSequenceNumber: 1,
+					DataFile: DataFile{FilePath: "/w/c.parquet", FileFormat: "PARQUET", RecordCount: 4, FileSizeInBytes: 40}},
+				{Status: EntryStatusDeleted, SnapshotID: 8, SequenceNumber: 2, FileSequenceNumber: 2,
+					DataFile: DataFile{FilePath: "/w/d.parquet", FileFormat: "PARQUET", RecordCount: 6, FileSizeInBytes: 60}},
+			},
+			sequenceNumber: 5,
+			want: []ManifestEntry{
+				{Status: EntryStatusExisting, SnapshotID: 7, SequenceNumber: 1, FileSequenceNumber: 1,
+					DataFile: DataFile{FilePath: "/w/c.parquet", FileFormat: "PARQUET", Partition: map[string]interface{}{}, RecordCount: 4, FileSizeInBytes: 40}},
+				{Status: EntryStatusDeleted, SnapshotID: 42, SequenceNumber: 2, FileSequenceNumber: 2,
+					DataFile: DataFile{FilePath: "/w/d.parquet", FileFormat: "PARQUET", Partition: map[string]interface{}{}, RecordCount: 6, FileSizeInBytes: 60}},
+			},
+			wantManifest: ManifestFile{PartitionSpecID: 1, Content: ManifestContentData, SequenceNumber: 5, MinSequenceNumber: 1,
+				AddedSnapshotID: 42, ExistingFilesCount: 1, ExistingRowsCount: 4, DeletedFilesCount: 1, DeletedRowsCount: 6},
+		},
+		{
+			name:    "equality deletes",
+			spec:    spec,
+			content: ManifestContentDeletes,
+			entries: []ManifestEntry{
+				{Status: EntryStatusAdded, DataFile: DataFile{Content: FileContentEqualityDeletes, FilePath: "/w/eq.parquet", FileFormat: "PARQUET",
+					Partition: map[string]interface{}{"annee": int32(2024)}, RecordCount: 2, FileSizeInBytes: 20, EqualityIDs: []int{1, 2}}},
+			},
+			sequenceNumber: 4,
+			want: []ManifestEntry{
+				{Status: EntryStatusAdded, SnapshotID: 42, DataFile: DataFile{Content: FileContentEqualityDeletes, FilePath: "/w/eq.parquet", FileFormat: "PARQUET",
+					Partition: map[string]interface{}{"annee": int32(2024)}, RecordCount: 2, FileSizeInBytes: 20, EqualityIDs: []int{1, 2}}},
+			},
+			wantManifest: ManifestFile{PartitionSpecID: 0, Content: ManifestContentDeletes, SequenceNumber: 4, MinSequenceNumber: 4,
+				AddedSnapshotID: 42, AddedFilesCount: 1, AddedRowsCount: 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			io := NewFileIO(dir, "file:/warehouse")
+			location := "file:/warehouse/db/t/metadata/m0.avro"
+
+			manifest, err := io.WriteManifest(location, metadata, test.spec, test.content, 42, test.sequenceNumber, test.entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(filepath.Join(dir, "db", "t", "metadata", "m0.avro"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if manifest.Length != info.Size() {
+				t.Errorf("manifest length %d, file size %d", manifest.Length, info.Size())
+			}
+			test.wantManifest.Path = location
+			test.wantManifest.Length = manifest.Length
+			if !reflect.DeepEqual(manifest, test.wantManifest) {
+				t.Errorf("manifest file\n got %+v\nwant %+v", manifest, test.wantManifest)
+			}
+
+			entries, err := io.ReadManifest(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(withoutRecords(entries), test.want) {
+				t.Errorf("entries\n got %+v\nwant %+v", entries, test.want)
+			}
+
+			file, err := readAvroFile(io.LocalPath(location))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range map[string]string{"format-version": "2", "schema-id": "0", "content": map[int32]string{0: "data", 1: "deletes"}[test.content]} {
+				if got := file.Metadata[key]; got != want {
+					t.Errorf("metadata %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteManifestListRoundTrip(t *testing.T) {
+	parent := int64(6)
+	tests := []struct {
+		name      string
+		snapshot  *Snapshot
+		manifests []ManifestFile
+		parentID  string
+	}{
+		{
+			name:     "first snapshot",
+			snapshot: &Snapshot{SnapshotID: 5, SequenceNumber: 1},
+			manifests: []ManifestFile{
+				{Path: "/w/m0.avro", Length: 1234, SequenceNumber: 1, MinSequenceNumber: 1, AddedSnapshotID: 5, AddedFilesCount: 2, AddedRowsCount: 15},
+			},
+			parentID: "null",
+		},
+		{
+			name:     "data and delete manifests",
+			snapshot: &Snapshot{SnapshotID: 7, ParentSnapshotID: &parent, SequenceNumber: 4},
+			manifests: []ManifestFile{
+				{Path: "/w/m1.avro", Length: 99, PartitionSpecID: 1, SequenceNumber: 4, MinSequenceNumber: 2, AddedSnapshotID: 7,
+					ExistingFilesCount: 3, DeletedFilesCount: 1, ExistingRowsCount: 30, DeletedRowsCount: 8},
+				{Path: "/w/m2.avro", Length: 77, Content: ManifestContentDeletes, SequenceNumber: 4, MinSequenceNumber: 4, AddedSnapshotID: 7,
+					AddedFilesCount: 1, AddedRowsCount: 2},
+			},
+			parentID: "6",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			io := NewFileIO(t.TempDir(), "file:/warehouse")
+			location := "file:/warehouse/db/t/metadata/snap.avro"
+			if err := io.WriteManifestList(location, test.snapshot, test.manifests); err != nil {
+				t.Fatal(err)
+			}
+
+			manifests, err := io.ReadManifestList(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(manifests, test.manifests) {
+				t.Errorf("manifests\n got %+v\nwant %+v", manifests, test.manifests)
+			}
+
+			file, err := readAvroFile(io.LocalPath(location))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := file.Metadata["parent-snapshot-id"]; got != test.parentID {
+				t.Errorf("parent-snapshot-id = %q, want %q", got, test.parentID)
+			}
+		})
+	}
+}
+
+// statsFields are the data_file fields a rewrite has no reason to change
+var statsFields = []string{"column_sizes", "value_counts", "null_value_counts", "nan_value_counts",
+	"lower_bounds", "upper_bounds", "key_metadata", "split_offsets", "sort_order_id"}
+
+// dataFileRecords returns the data_file records of a manifest by file path
+func dataFileRecords(t *testing.T, path string) map[string]map[string]interface{} {
+	t.Helper()
+	file, err := readAvroFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make(map[string]map[string]interface{})
+	for _, r := range file.Records {
+		df := r["data_file"].(map[string]interface{})
+		records[df["file_path"].(string)] = df
+	}
+	return records
+}
+
+// checkStatsKept compares the stats of the data files of a rewritten manifest with those of
+// the reference manifest
+func checkStatsKept(t *testing.T, rewritten string, wantFiles int) {
+	t.Helper()
+	original := dataFileRecords(t, filepath.Join("testdata", "manifest-v2.avro"))
+	records := dataFileRecords(t, rewritten)
+	if len(records) != wantFiles {
+		t.Fatalf("rewritten manifest has %d files, want %d", len(records), wantFiles)
+	}
+	for path, record := range records {
+		for _, field := range statsFields {
+			if got, want := record[field], original[path][field]; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s of %s = %v, want %v", field, path, got, want)
+			}
+		}
+	}
+}
+
+func TestWriteManifestKeepsStats(t *testing.T) {
+	metadata, spec := testTable()
+	path, err := filepath.Abs(filepath.Join("testdata", "manifest-v2.avro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	io := NewFileIO(dir, "file:/warehouse")
+
+	entries, err := io.ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		entries[i].Status = EntryStatusExisting
+	}
+	location := "file:/warehouse/m.avro"
+	if _, err := io.WriteManifest(location, metadata, spec, ManifestContentData, 42, 3, entries); err != nil {
+		t.Fatal(err)
+	}
+	checkStatsKept(t, io.LocalPath(location), 3)
+}
+
+func TestStageKeepsStats(t *testing.T) {
+	metadata, _ := testTable()
+	dir := t.TempDir()
+	io := NewFileIO(dir, "file:/warehouse")
+
+	// The parent snapshot lists the reference manifest
+	reference, err := os.ReadFile(filepath.Join("testdata", "manifest-v2.avro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "db", "t", "metadata"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manifestLocation := "file:/warehouse/db/t/metadata/reference-m0.avro"
+	if err := os.WriteFile(io.LocalPath(manifestLocation), reference, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parent := Snapshot{SnapshotID: 3051729675574597004, SequenceNumber: 1, ManifestList: "file:/warehouse/db/t/metadata/snap-parent.avro"}
+	if err := io.WriteManifestList(parent.ManifestList, &parent, []ManifestFile{{Path: manifestLocation, Length: int64(len(reference)),
+		SequenceNumber: 1, MinSequenceNumber: 1, AddedSnapshotID: parent.SnapshotID, AddedFilesCount: 1, ExistingFilesCount: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	metadata.Location = "file:/warehouse/db/t"
+	metadata.TableUUID = "9c12d441-03fe-4693-9a96-a0705ddf69c1"
+	metadata.LastSequenceNumber = 1
+	metadata.Snapshots = []Snapshot{parent}
+	metadata.CurrentSnapshotID = &parent.SnapshotID
+
+	removed := "file:/var/lib/iceberg/warehouse/my_data/ventes/data/annee=2024/00000-0-b.parquet"
+	update := io.NewSnapshotUpdate(metadata, "main", OperationDelete)
+	update.DeleteFile(removed)
+	_, snapshot, err := update.Stage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifests, err := io.ReadManifestList(snapshot.ManifestList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 1 || manifests[0].Path == manifestLocation {
+		t.Fatalf("manifests %+v, want the reference manifest rewritten", manifests)
+	}
+	entries, err := io.ReadManifest(manifests[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]int32)
+	for _, entry := range entries {
+		statuses[entry.DataFile.FilePath] = entry.Status
+	}
+	if statuses[removed] != EntryStatusDeleted {
+		t.Errorf("removed file has status %d, want deleted", statuses[removed])
+	}
+	checkStatsKept(t, io.LocalPath(manifests[0].Path), 2)
+}
@@ -0,0 +1,129 @@
+// Package lineage records where the Parquet files come from: convert writes the source file,
+// its hash, the run and the tool version into their key-value metadata, and the loads copy
+// them into the summary of the Iceberg snapshots.
+package lineage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Keys of the lineage entries, in the Parquet key-value metadata and the snapshot summaries
+const (
+	KeySourcePath   = "lineage.source_path"   // the source file as found, or the archive joined with the entry path
+	KeySourceSHA256 = "lineage.source_sha256" // SHA-256 of the source file, or of the archive entry
+	KeySourceFormat = "lineage.source_format" // csv, tsv, json, excel or fixed-width
+	KeyIngestedAt   = "lineage.ingested_at"   // when the file was converted, RFC 3339 in UTC
+	KeyRunID        = "lineage.run_id"        // the convert run, shared by the files it wrote
+	KeyToolVersion  = "lineage.tool_version"  // version of mds
+	KeyCSVDialect   = "lineage.csv_dialect"   // JSON of the delimiter, quote, header... read, for CSV and TSV files
+)
+
+// prefix is the common prefix of the keys
+const prefix = "lineage."
+
+// NewRunID returns the ID of a run: its start time and a random suffix, such as
+// 20241018T131430Z-3f9a2c1b
+func NewRunID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// Version returns the version of mds: the module version it was installed at, or the VCS
+// revision it was built from, with a -dirty suffix for uncommitted changes
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// HashFile returns the hex SHA-256 of a file
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Read returns the lineage entries of Parquet files, read with DuckDB from their key-value
+// metadata; source is a path, glob or list literal as read_parquet takes it. Files written
+// by different runs or from different sources give the distinct values of a key, sorted
+// and joined with commas. Files without lineage give an empty map.
+func Read(ctx context.Context, db *sql.DB, source string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT DISTINCT decode(key), decode(value) FROM parquet_kv_metadata(%s) WHERE starts_with(decode(key), '%s')", source, prefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the lineage metadata: %v", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string][]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan the lineage metadata: %v", err)
+		}
+		values[key] = append(values[key], value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]string, len(values))
+	for key, distinct := range values {
+		sort.Strings(distinct)
+		entries[key] = strings.Join(distinct, ", ")
+	}
+	return entries, nil
+}
+
+// Keys returns the keys of entries, sorted
+func Keys(entries map[string]string) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
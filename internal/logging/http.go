@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxLoggedBody is the length of the request and response bodies logged
+const maxLoggedBody = 2048
+
+// Transport logs the requests sent through it and their responses at debug level
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// NewHTTPClient returns an HTTP client logging its requests
+func NewHTTPClient() *http.Client {
+	return &http.Client{Transport: &Transport{Base: http.DefaultTransport}}
+}
+
+// truncate shortens a logged body, after its secrets are redacted so that a secret cut
+// by the limit is not left in the log
+func truncate(body string) string {
+	if len(body) > maxLoggedBody {
+		return body[:maxLoggedBody] + "…"
+	}
+	return body
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Debug() {
+		return t.Base.RoundTrip(req)
+	}
+
+	attrs := []any{"method", req.Method, "url", Redact(req.URL.String())}
+	if req.Header.Get("Authorization") != "" {
+		attrs = append(attrs, "authorization", "[REDACTED]")
+	}
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			attrs = append(attrs, "request", truncate(Redact(string(data))))
+		}
+	}
+
+	started := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+	attrs = append(attrs, "duration", time.Since(started))
+	if err != nil {
+		slog.DebugContext(req.Context(), "catalog request", append(attrs, "error", err)...)
+		return nil, err
+	}
+
+	// The body is read to be logged and replaced for the caller
+	data, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	attrs = append(attrs, "status", resp.StatusCode, "response", truncate(Redact(string(data))))
+	if readErr != nil {
+		slog.DebugContext(req.Context(), "catalog request", append(attrs, "error", readErr)...)
+		return nil, readErr
+	}
+	slog.DebugContext(req.Context(), "catalog request", attrs...)
+	return resp, nil
+}
@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc answers the requests of a Transport
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestTransportRedactsTruncatedBodies logs bodies whose secret straddles the truncation
+// limit: once cut, the secret would no longer match the patterns of Redact
+func TestTransportRedactsTruncatedBodies(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer level.Set(level.Level())
+	level.Set(slog.LevelDebug)
+
+	secret := strings.Repeat("s3cr3t", 20)
+	padding := strings.Repeat("x", maxLoggedBody-len(`{"padding": "", "token": "`)-len(secret)/2)
+	body := `{"padding": "` + padding + `", "token": "` + secret + `"}`
+	if start := strings.Index(body, secret); start >= maxLoggedBody || start+len(secret) <= maxLoggedBody {
+		t.Fatalf("the secret at %d-%d does not straddle the limit of %d", start, start+len(secret), maxLoggedBody)
+	}
+
+	transport := &Transport{Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+	})}
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8181/v1/oauth/tokens", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, _ := io.ReadAll(resp.Body)
+	if string(received) != body {
+		t.Error("the response body was not given back to the caller")
+	}
+
+	logged := logs.String()
+	if strings.Contains(logged, secret[:len(secret)/4]) {
+		t.Errorf("the log leaks part of the secret:\n%s", logged)
+	}
+	if strings.Count(logged, "[REDACTED]") != 2 {
+		t.Errorf("the log does not redact the secret of the request and the response:\n%s", logged)
+	}
+}
@@ -0,0 +1,96 @@
+// Package logging configures the slog logger of the commands from their --log-level and
+// --log-format flags, and logs the SQL run by DuckDB and the HTTP exchanges with the
+// catalog at debug level, with secrets redacted.
+package logging
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// level is the minimum level of the records logged
+var level = new(slog.LevelVar)
+
+// format is the format of the records: text or json
+var format = "text"
+
+// Install makes the logger of the flags the default slog logger, which the log package
+// also writes to
+func Install() {
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, options)
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// levelFlag sets the level of the logger
+type levelFlag struct{}
+
+func (levelFlag) String() string {
+	return strings.ToLower(level.Level().String())
+}
+
+func (levelFlag) Set(value string) error {
+	return level.UnmarshalText([]byte(value))
+}
+
+// formatFlag sets the format of the logger and installs it
+type formatFlag struct{}
+
+func (formatFlag) String() string {
+	return format
+}
+
+func (formatFlag) Set(value string) error {
+	if value != "text" && value != "json" {
+		return fmt.Errorf("expected text or json")
+	}
+	format = value
+	Install()
+	return nil
+}
+
+// AddFlags registers the --log-level and --log-format flags on a flag set
+func AddFlags(fs *flag.FlagSet) {
+	fs.Var(levelFlag{}, "log-level", "Minimum level of the logs on stderr: debug (with SQL and catalog requests), info, warn or error")
+	fs.Var(formatFlag{}, "log-format", "Format of the logs on stderr: text or json")
+}
+
+// Debug reports whether debug records are logged, to skip building costly attributes
+func Debug() bool {
+	return level.Level() <= slog.LevelDebug
+}
+
+// secretPatterns match the secrets of SQL statements, HTTP headers and JSON bodies; the
+// first group is kept and the rest replaced
+var secretPatterns = []*regexp.Regexp{
+	// DuckDB secrets and options, such as SECRET 'xxx' or PASSWORD = 'xxx'
+	regexp.MustCompile(`(?i)(\b(?:secret|password|token|key_id|session_token)\b\s*(?:=\s*)?)'[^']*'`),
+	// JSON fields, such as "token": "xxx" or "s3.secret-access-key": "xxx"
+	regexp.MustCompile(`(?i)("[^"]*(?:token|secret|password|credential)[^"]*"\s*:\s*)"[^"]*"`),
+	// Form fields of OAuth token requests, such as client_secret=xxx
+	regexp.MustCompile(`(?i)(\b(?:client_secret|token|access_token|refresh_token|password)=)[^&\s]*`),
+}
+
+// Redact replaces the secrets of a SQL statement, header or request body with [REDACTED]
+func Redact(text string) string {
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			prefix := pattern.FindStringSubmatch(match)[1]
+			if strings.HasSuffix(match, "'") {
+				return prefix + "'[REDACTED]'"
+			}
+			if strings.HasSuffix(match, `"`) {
+				return prefix + `"[REDACTED]"`
+			}
+			return prefix + "[REDACTED]"
+		})
+	}
+	return text
+}
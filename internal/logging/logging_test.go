@@ -0,0 +1,45 @@
+package logging
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{
+			"DuckDB secret",
+			"CREATE SECRET s3 (TYPE s3, KEY_ID 'AKIA123', SECRET 'abc/def', SESSION_TOKEN 'tok')",
+			"CREATE SECRET s3 (TYPE s3, KEY_ID '[REDACTED]', SECRET '[REDACTED]', SESSION_TOKEN '[REDACTED]')",
+		},
+		{
+			"DuckDB option with equals",
+			"ATTACH 'host=db' AS pg (TYPE postgres, PASSWORD = 'p4ss')",
+			"ATTACH 'host=db' AS pg (TYPE postgres, PASSWORD = '[REDACTED]')",
+		},
+		{
+			"JSON fields",
+			`{"access_token": "eyJ.abc", "token_type": "bearer", "config": {"s3.secret-access-key": "xyz", "s3.region": "eu-west-1"}}`,
+			`{"access_token": "[REDACTED]", "token_type": "[REDACTED]", "config": {"s3.secret-access-key": "[REDACTED]", "s3.region": "eu-west-1"}}`,
+		},
+		{
+			"OAuth form",
+			"grant_type=client_credentials&client_id=mds&client_secret=s3cr3t&scope=catalog",
+			"grant_type=client_credentials&client_id=mds&client_secret=[REDACTED]&scope=catalog",
+		},
+		{
+			"URL query",
+			"http://localhost:8181/v1/oauth/tokens?token=abc",
+			"http://localhost:8181/v1/oauth/tokens?token=[REDACTED]",
+		},
+		{
+			"nothing secret",
+			"SELECT secret_santa, token_count FROM gifts WHERE name = 'key'",
+			"SELECT secret_santa, token_count FROM gifts WHERE name = 'key'",
+		},
+	}
+	for _, test := range tests {
+		if got := Redact(test.text); got != test.want {
+			t.Errorf("%s:\n got %s\nwant %s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package project reads the project config, mds.yaml, which sets the defaults of the
+// command-line flags for a named profile. A flag set on the command line wins over its
+// MDS_<FLAG> environment variable, which wins over the project config, which wins over
+// the built-in default.
+package project
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is the project config read when --project is not set
+const DefaultPath = "mds.yaml"
+
+// CatalogSettings declares how to reach the Iceberg REST Catalog
+type CatalogSettings struct {
+	URL               string `yaml:"url" flag:"catalog-url"`
+	WarehouseDir      string `yaml:"warehouse_dir" flag:"warehouse-dir"`
+	WarehouseLocation string `yaml:"warehouse_location" flag:"warehouse-location"`
+	Credential        string `yaml:"credential" flag:"catalog-credential"`
+}
+
+// Settings are the flag defaults a profile declares
+type Settings struct {
+	SourceDir     string          `yaml:"source_dir" flag:"source-dir"`
+	ParquetDir    string          `yaml:"parquet_dir" flag:"parquet-dir,output-dir"`
+	Namespace     string          `yaml:"namespace" flag:"namespace"`
+	CatalogConfig string          `yaml:"catalog_config" flag:"config"`
+	Dictionary    string          `yaml:"dictionary" flag:"dictionary"`
+	ViewsDir      string          `yaml:"views_dir" flag:"dir"`
+	Catalog       CatalogSettings `yaml:"catalog"`
+	Parquet       ParquetOptions  `yaml:"parquet"`
+	DuckDB        DuckDBSettings  `yaml:"duckdb"`
+}
+
+// DuckDBSettings bound the resources DuckDB uses while converting files
+type DuckDBSettings struct {
+	MemoryLimit   string `yaml:"memory_limit" flag:"memory-limit"`     // such as 4GB
+	TempDirectory string `yaml:"temp_directory" flag:"temp-directory"` // where DuckDB spills beyond the memory limit
+}
+
+// ParquetOptions tune the Parquet files written by convert, passed to DuckDB's COPY. They
+// are set for all tables, and per table. Options left empty keep DuckDB's defaults.
+type ParquetOptions struct {
+	Compression      string `yaml:"compression" flag:"compression"`             // snappy, zstd, gzip, lz4, brotli or uncompressed
+	CompressionLevel int    `yaml:"compression_level" flag:"compression-level"` // level of zstd
+	RowGroupSize     int    `yaml:"row_group_size" flag:"row-group-size"`       // rows per row group
+	FileSize         string `yaml:"file_size" flag:"file-size"`                 // such as 256MB, splits a table into files of about this size
+	Version          string `yaml:"version" flag:"parquet-version"`             // v1 or v2
+}
+
+// TableOptions are the options of one table
+type TableOptions struct {
+	Format  string         `yaml:"format"` // csv, tsv, json, excel or fixed-width; by default, from the extension
+	CSV     CSVOptions     `yaml:"csv"`
+	Sheet   string         `yaml:"sheet"`  // sheet of an Excel file, by default the first one
+	Layout  string         `yaml:"layout"` // layout file of a fixed-width file, by default <file>.layout.yaml
+	Parquet ParquetOptions `yaml:"parquet"`
+
+	// PartitionBy are the columns of the Hive-style partition directories the Parquet
+	// files of the table are written to, such as annee=2023/departement=75/
+	PartitionBy []string `yaml:"partition_by"`
+}
+
+// CSVOptions are the options reading the CSV or TSV file of a table, passed to DuckDB's
+// read_csv. Options left empty are detected.
+type CSVOptions struct {
+	Delimiter       string `yaml:"delimiter"`
+	Quote           string `yaml:"quote"`
+	Header          *bool  `yaml:"header"`
+	NullString      string `yaml:"null_string"`
+	DateFormat      string `yaml:"date_format"`
+	TimestampFormat string `yaml:"timestamp_format"`
+	Skip            int    `yaml:"skip"`
+}
+
+// Config is the content of the project config. The top-level settings apply to every
+// profile; the settings of the selected profile override them.
+type Config struct {
+	Settings `yaml:",inline"`
+	Profile  string                  `yaml:"profile"`
+	Profiles map[string]Settings     `yaml:"profiles"`
+	Tables   map[string]TableOptions `yaml:"tables"`
+}
+
+// AddFlags registers the --project and --profile flags selecting the project config and
+// its profile, which Apply reads
+func AddFlags(fs *flag.FlagSet) {
+	fs.String("project", DefaultPath, "Project config setting the defaults of the flags (skipped when missing)")
+	fs.String("profile", "", "Profile of the project config to use (default: the profile it selects)")
+}
+
+// variablePattern matches ${VAR} and ${VAR:-default}
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolate replaces ${VAR} and ${VAR:-default} in the scalar values of a YAML
+// document with the value of the environment variable, or the default when it is
+// unset or empty. Comments and keys are left as is.
+func interpolate(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var missing []string
+		node.Value = variablePattern.ReplaceAllStringFunc(node.Value, func(match string) string {
+			groups := variablePattern.FindStringSubmatch(match)
+			if value := os.Getenv(groups[1]); value != "" {
+				return value
+			}
+			if groups[2] == "" {
+				missing = append(missing, groups[1])
+			}
+			return groups[3]
+		})
+		if len(missing) > 0 {
+			return fmt.Errorf("line %d: environment variable(s) %s are not set", node.Line, strings.Join(missing, ", "))
+		}
+	}
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+		if err := interpolate(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load reads a project config. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	config := &Config{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid project config %s: %v", path, err)
+	}
+	if err := interpolate(&document); err != nil {
+		return nil, fmt.Errorf("invalid project config %s: %v", path, err)
+	}
+	if data, err = yaml.Marshal(&document); err != nil {
+		return nil, err
+	}
+
+	// The interpolated document is decoded again to reject unknown keys
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid project config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// settingValues returns the non-empty settings of a struct by flag name
+func settingValues(v reflect.Value, values map[string]string) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			settingValues(v.Field(i), values)
+			continue
+		}
+		value := v.Field(i).String()
+		if field.Type.Kind() == reflect.Int {
+			value = ""
+			if n := v.Field(i).Int(); n != 0 {
+				value = strconv.FormatInt(n, 10)
+			}
+		}
+		if value != "" {
+			for _, name := range strings.Split(field.Tag.Get("flag"), ",") {
+				values[name] = value
+			}
+		}
+	}
+}
+
+// Values returns the settings of a profile by flag name, the profile overriding the
+// top-level settings. An empty name selects the default profile of the config.
+func (c *Config) Values(profile string) (map[string]string, error) {
+	if profile == "" {
+		profile = c.Profile
+	}
+	values := make(map[string]string)
+	settingValues(reflect.ValueOf(c.Settings), values)
+	if profile == "" {
+		return values, nil
+	}
+
+	settings, ok := c.Profiles[profile]
+	if !ok {
+		var names []string
+		for name := range c.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown profile %q, the project config declares %s", profile, strings.Join(names, ", "))
+	}
+	settingValues(reflect.ValueOf(settings), values)
+	return values, nil
+}
+
+// ResolveCredential returns the secret a credential reference points at: env:<VAR> reads
+// an environment variable and file:<path> the content of a file, trimmed
+func ResolveCredential(reference string) (string, error) {
+	kind, target, _ := strings.Cut(reference, ":")
+	switch kind {
+	case "":
+		return "", nil
+	case "env":
+		value := os.Getenv(target)
+		if value == "" {
+			return "", fmt.Errorf("environment variable %s of credential %q is not set", target, reference)
+		}
+		return value, nil
+	case "file":
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", fmt.Errorf("failed to read credential %q: %v", reference, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return "", fmt.Errorf("invalid credential reference %q, expected env:<VAR> or file:<path>", reference)
+	}
+}
+
+// EnvName returns the environment variable setting a flag, such as MDS_CATALOG_URL
+func EnvName(flagName string) string {
+	return "MDS_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Apply sets the flags that were not given on the command line from their environment
+// variable or else from the selected profile of the project config, and returns the config.
+// The flag set must have the flags of AddFlags.
+func Apply(fs *flag.FlagSet) (*Config, error) {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	path := fs.Lookup("project").Value.String()
+	if env := os.Getenv(EnvName("project")); env != "" && !explicit["project"] {
+		path = env
+	}
+	profile := fs.Lookup("profile").Value.String()
+	if !explicit["profile"] {
+		profile = os.Getenv(EnvName("profile"))
+	}
+
+	config, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	values, err := config.Values(profile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var applyErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || f.Name == "project" || f.Name == "profile" || applyErr != nil {
+			return
+		}
+		value, ok := os.LookupEnv(EnvName(f.Name))
+		source := EnvName(f.Name)
+		if !ok {
+			value, ok = values[f.Name]
+			source = path
+		}
+		if ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				applyErr = fmt.Errorf("invalid value %q for --%s from %s: %w", value, f.Name, source, err)
+			}
+		}
+	})
+	return config, applyErr
+}
@@ -0,0 +1,147 @@
+package project
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `
+namespace: from_file
+source_dir: ./data
+parquet_dir: ./parquet
+catalog:
+  url: http://file:8181
+profile: dev
+profiles:
+  dev:
+    source_dir: ./dev_data
+  prod:
+    source_dir: ./prod_data
+    duckdb:
+      memory_limit: 8GB
+`
+
+// testFlags returns a flag set with the project flags and a few settings, parsed from args
+func testFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	AddFlags(fs)
+	fs.String("namespace", "default_ns", "")
+	fs.String("source-dir", "default_source", "")
+	fs.String("output-dir", "default_output", "")
+	fs.String("catalog-url", "http://default:8181", "")
+	fs.String("memory-limit", "", "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestApplyPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mds.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want map[string]string
+	}{
+		{
+			name: "defaults without a config",
+			args: []string{"--project", filepath.Join(filepath.Dir(path), "missing.yaml")},
+			want: map[string]string{"namespace": "default_ns", "source-dir": "default_source", "catalog-url": "http://default:8181"},
+		},
+		{
+			name: "file over defaults, default profile over top level",
+			args: []string{"--project", path},
+			want: map[string]string{"namespace": "from_file", "source-dir": "./dev_data", "output-dir": "./parquet", "catalog-url": "http://file:8181", "memory-limit": ""},
+		},
+		{
+			name: "selected profile",
+			args: []string{"--project", path, "--profile", "prod"},
+			want: map[string]string{"source-dir": "./prod_data", "memory-limit": "8GB"},
+		},
+		{
+			name: "profile and project from the environment",
+			env:  map[string]string{"MDS_PROJECT": path, "MDS_PROFILE": "prod"},
+			want: map[string]string{"namespace": "from_file", "source-dir": "./prod_data"},
+		},
+		{
+			name: "environment over file",
+			args: []string{"--project", path},
+			env:  map[string]string{"MDS_NAMESPACE": "from_env", "MDS_CATALOG_URL": "http://env:8181"},
+			want: map[string]string{"namespace": "from_env", "source-dir": "./dev_data", "catalog-url": "http://env:8181"},
+		},
+		{
+			name: "flags over environment and file",
+			args: []string{"--project", path, "--namespace", "from_flag", "--source-dir", "./flag_data"},
+			env:  map[string]string{"MDS_NAMESPACE": "from_env"},
+			want: map[string]string{"namespace": "from_flag", "source-dir": "./flag_data", "catalog-url": "http://file:8181"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			fs := testFlags(t, test.args...)
+			if _, err := Apply(fs); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range test.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("--%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mds.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Apply(testFlags(t, "--project", path, "--profile", "staging")); err == nil {
+		t.Error("an unknown profile was accepted")
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	AddFlags(fs)
+	fs.Int("row-group-size", 0, "")
+	t.Setenv("MDS_ROW_GROUP_SIZE", "many")
+	if err := fs.Parse([]string{"--project", path}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Apply(fs); err == nil {
+		t.Error("an invalid value from the environment was accepted")
+	}
+}
+
+func TestLoadInterpolation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mds.yaml")
+	content := "namespace: ${MDS_TEST_NAMESPACE}\ncatalog:\n  url: ${MDS_TEST_URL:-http://localhost:8181}\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("MDS_TEST_NAMESPACE", "sales")
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Namespace != "sales" || config.Catalog.URL != "http://localhost:8181" {
+		t.Errorf("interpolated namespace %q and catalog URL %q", config.Namespace, config.Catalog.URL)
+	}
+
+	t.Setenv("MDS_TEST_NAMESPACE", "")
+	if _, err := Load(path); err == nil {
+		t.Error("an unset variable without a default was accepted")
+	}
+}
@@ -0,0 +1,156 @@
+// Package report records what a command did with each of its files, as a JSON report for
+// orchestration tools, and controls whether the human-readable output is shown.
+package report
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"the-modern-data-stack/internal/cli"
+)
+
+// Statuses of a file
+const (
+	StatusOK      = "ok"      // the file was processed
+	StatusFailed  = "failed"  // processing the file failed
+	StatusSkipped = "skipped" // there was nothing to do, such as a table that already exists
+	StatusPlanned = "planned" // a dry run would process the file
+)
+
+// Column is a column of the schema of a file
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// File is the report of one file
+type File struct {
+	Source     string   `json:"source"`
+	Target     string   `json:"target"`
+	Rows       int64    `json:"rows"`
+	Bytes      int64    `json:"bytes"`
+	Schema     []Column `json:"schema,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+	Status     string   `json:"status"`
+	Error      string   `json:"error,omitempty"`
+
+	started time.Time
+}
+
+// Finish records the status of the file and how long it took
+func (f *File) Finish(status string) {
+	f.Status = status
+	f.DurationMs = time.Since(f.started).Milliseconds()
+}
+
+// Fail records the error that stopped processing the file
+func (f *File) Fail(err error) {
+	f.Error = err.Error()
+	f.Finish(StatusFailed)
+}
+
+// Report is the report of a run of a command
+type Report struct {
+	Command    string    `json:"command"`
+	DryRun     bool      `json:"dry_run"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Succeeded  int       `json:"succeeded"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	Files      []*File   `json:"files"`
+	Errors     []string  `json:"errors,omitempty"`
+
+	// Out receives the human-readable output of the command: stdout, or nothing when the
+	// output is quiet or JSON
+	Out io.Writer `json:"-"`
+
+	output *Output
+}
+
+// Add starts the report of a file
+func (r *Report) Add(source, target string) *File {
+	file := &File{Source: source, Target: target, started: time.Now()}
+	r.Files = append(r.Files, file)
+	return file
+}
+
+// AddError records a failure of the run that is not about a single file
+func (r *Report) AddError(err error) {
+	r.Errors = append(r.Errors, err.Error())
+}
+
+// Close completes the report of a run that ended with err, prints it when the output is
+// JSON and returns an error if the run or any file failed, so that the command exits with
+// a non-zero status
+func (r *Report) Close(err error) error {
+	if err != nil {
+		r.AddError(err)
+	}
+	r.DurationMs = time.Since(r.StartedAt).Milliseconds()
+	for _, file := range r.Files {
+		switch file.Status {
+		case StatusFailed:
+			r.Failed++
+		case StatusSkipped:
+			r.Skipped++
+		default:
+			r.Succeeded++
+		}
+	}
+	r.Status = StatusOK
+	if r.Failed > 0 || len(r.Errors) > 0 {
+		r.Status = StatusFailed
+	}
+
+	if r.output.format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(r); err != nil {
+			return fmt.Errorf("failed to write the report: %v", err)
+		}
+	}
+
+	switch {
+	case err != nil:
+		return err
+	case r.Failed > 0:
+		return fmt.Errorf("%d of %d file(s) failed", r.Failed, len(r.Files))
+	case len(r.Errors) > 0:
+		return fmt.Errorf("%d step(s) failed", len(r.Errors))
+	}
+	return nil
+}
+
+// Output holds the flags choosing how a command reports what it did
+type Output struct {
+	format string
+	quiet  bool
+}
+
+// AddFlags registers the --output and --quiet flags on a flag set
+func AddFlags(fs *flag.FlagSet) *Output {
+	o := &Output{}
+	fs.StringVar(&o.format, "output", "text", "Output format: text, or json to print a report of each file on stdout")
+	fs.BoolVar(&o.quiet, "quiet", false, "Only print warnings and errors, on stderr")
+	return o
+}
+
+// Start starts the report of a run. Unless the output is text without --quiet, the
+// human-readable output the command writes to the Out of the report is discarded; errors
+// are still logged on stderr.
+func (o *Output) Start(command string, dryRun bool) (*Report, error) {
+	if o.format != "text" && o.format != "json" {
+		return nil, cli.Usagef("invalid --output %q, expected text or json", o.format)
+	}
+	var out io.Writer = os.Stdout
+	if o.quiet || o.format == "json" {
+		out = io.Discard
+	}
+	return &Report{Command: command, DryRun: dryRun, StartedAt: time.Now(), Files: []*File{}, Out: out, output: o}, nil
+}
@@ -0,0 +1,14 @@
+// Package sqlutil quotes the values and names the commands put into DuckDB statements.
+package sqlutil
+
+import "strings"
+
+// QuoteString quotes a value as a DuckDB string literal
+func QuoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// QuoteIdentifier quotes a DuckDB identifier, such as a column name
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,23 @@
+package sqlutil
+
+import "testing"
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		value, literal, identifier string
+	}{
+		{"sales", `'sales'`, `"sales"`},
+		{"", `''`, `""`},
+		{"l'année", `'l''année'`, `"l'année"`},
+		{`say "hi"`, `'say "hi"'`, `"say ""hi"""`},
+		{`data/o'brien's "files"/*.parquet`, `'data/o''brien''s "files"/*.parquet'`, `"data/o'brien's ""files""/*.parquet"`},
+	}
+	for _, test := range tests {
+		if got := QuoteString(test.value); got != test.literal {
+			t.Errorf("QuoteString(%q) = %s, want %s", test.value, got, test.literal)
+		}
+		if got := QuoteIdentifier(test.value); got != test.identifier {
+			t.Errorf("QuoteIdentifier(%q) = %s, want %s", test.value, got, test.identifier)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package warehouse implements the commands that manage the Iceberg tables of the
+// warehouse: creation, loads, queries, deletes, branches and tags, views and maintenance.
+package warehouse
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/project"
+)
+
+// TableCommands create the tables of the warehouse and change their layout
+var TableCommands = []cli.Command{
+	{Name: "create", Summary: "Create the Iceberg tables of the Parquet files with their schemas, properties and docs", Run: runCreateTables},
+	{Name: "set-partitioning", Summary: "Change how new data of a table is partitioned", Args: "<table> [<column> | <transform>(<column>)]...", Run: runSetPartitioning},
+	{Name: "set-identifier-fields", Summary: "Declare the columns identifying a row, used by merge loads", Args: "<table> <column>...", Run: runSetIdentifierFields},
+	{Name: "create-views", Summary: "Create or replace Iceberg views from the SQL files of a directory", Args: "[<view>...]", Run: runCreateViews},
+}
+
+// LoadCommands load Parquet files into the tables
+var LoadCommands = []cli.Command{
+	{Name: "load", Summary: "Load Parquet files into a table, optionally on a branch", Args: "<table> <file.parquet>...", Run: runLoad},
+	{Name: "load-all", Summary: "Load every Parquet file of a directory into its table, optionally atomically", Args: "[parquet-dir]", Run: runLoadAll},
+}
+
+// QueryCommand queries a table, optionally as of a past snapshot
+var QueryCommand = cli.Command{Name: "query", Summary: "Query a table with DuckDB, optionally as of a past snapshot", Args: "<table>", Run: runQuery}
+
+// InspectCommands show the state of the tables without changing them
+var InspectCommands = []cli.Command{
+	{Name: "schema", Summary: "Show the columns, partitioning and properties of a table", Args: "<table>", Run: runSchema},
+	{Name: "snapshots", Summary: "List the snapshots of a table", Args: "<table>", Run: runSnapshots},
+}
+
+// MaintainCommands change the rows, branches, tags and files of the tables
+var MaintainCommands = []cli.Command{
+	{Name: "delete", Summary: "Delete the rows matching a predicate", Args: "--table <table> --where <predicate>", Run: runDelete},
+	{Name: "create-branch", Summary: "Create a branch at a snapshot", Args: "<table> <branch>", Run: createRefCommand("branch")},
+	{Name: "delete-branch", Summary: "Delete a branch", Args: "<table> <branch>", Run: deleteRefCommand("branch")},
+	{Name: "create-tag", Summary: "Tag a snapshot", Args: "<table> <tag>", Run: createRefCommand("tag")},
+	{Name: "delete-tag", Summary: "Delete a tag", Args: "<table> <tag>", Run: deleteRefCommand("tag")},
+	{Name: "fast-forward", Summary: "Publish a branch to main after optional validation checks", Args: "--branch <branch> <table>", Run: runFastForward},
+	{Name: "rollback", Summary: "Move a branch back to an earlier snapshot or point in time", Args: "<table>", Run: runRollback},
+	{Name: "cherry-pick", Summary: "Apply the changes of an append snapshot to a branch", Args: "--snapshot <id> [--branch <name>] <table>", Run: runCherryPick},
+	{Name: "remove-orphan-files", Summary: "List or delete warehouse files no snapshot references", Run: runRemoveOrphanFiles},
+}
+
+// catalogOptions holds the flags shared by all subcommands
+type catalogOptions struct {
+	catalogURL        string
+	warehouseDir      string
+	warehouseLocation string
+	credential        credentialFlag
+	requestTimeout    time.Duration
+	retry             iceberg.RetryPolicy
+}
+
+// addCatalogFlags registers the catalog and warehouse flags, and the project flags
+// setting their defaults, on a subcommand flag set
+func addCatalogFlags(fs *flag.FlagSet) *catalogOptions {
+	opts := &catalogOptions{}
+	fs.StringVar(&opts.catalogURL, "catalog-url", "http://localhost:8181", "Iceberg REST Catalog URL")
+	fs.StringVar(&opts.warehouseDir, "warehouse-dir", "data/iceberg_warehouse", "Local directory holding the warehouse")
+	fs.StringVar(&opts.warehouseLocation, "warehouse-location", "/var/lib/iceberg/warehouse", "Warehouse location as seen by the catalog (CATALOG_WAREHOUSE)")
+	fs.Var(&opts.credential, "catalog-credential", "Reference to the bearer token of the catalog: env:<VAR> or file:<path>")
+	fs.DurationVar(&opts.requestTimeout, "request-timeout", 30*time.Second, "Timeout of each request to the catalog")
+	fs.IntVar(&opts.retry.MaxAttempts, "retry-attempts", iceberg.DefaultRetryPolicy.MaxAttempts, "Attempts of each catalog request and commit, including the first")
+	fs.DurationVar(&opts.retry.Backoff, "retry-backoff", iceberg.DefaultRetryPolicy.Backoff, "Wait before the first retry of a catalog request, doubled before each next one")
+	fs.DurationVar(&opts.retry.MaxBackoff, "retry-max-backoff", iceberg.DefaultRetryPolicy.MaxBackoff, "Longest wait between two attempts of a catalog request (0 for the default)")
+	opts.retry.Jitter = iceberg.DefaultRetryPolicy.Jitter
+	fs.Var((*jitterFlag)(&opts.retry.Jitter), "retry-jitter", "Fraction of each retry wait that is random, from 0 to 1")
+	project.AddFlags(fs)
+	return opts
+}
+
+// client creates a REST Catalog client from the options
+func (o *catalogOptions) client() *iceberg.Client {
+	client := iceberg.NewClient(o.catalogURL)
+	client.HTTPClient = logging.NewHTTPClient()
+	client.Token = o.credential.token
+	client.Timeout = o.requestTimeout
+	client.Retry = o.retry
+	return client
+}
+
+// credentialFlag holds a credential reference and the token it resolves to, so that the
+// token itself never appears in flags, environment variables or config files
+type credentialFlag struct {
+	reference string
+	token     string
+}
+
+func (c *credentialFlag) String() string {
+	return c.reference
+}
+
+func (c *credentialFlag) Set(value string) error {
+	token, err := project.ResolveCredential(value)
+	if err != nil {
+		return err
+	}
+	c.reference = value
+	c.token = token
+	return nil
+}
+
+// jitterFlag sets the jitter of the retry policy, a fraction from 0 to 1
+type jitterFlag float64
+
+func (j *jitterFlag) String() string {
+	return strconv.FormatFloat(float64(*j), 'g', -1, 64)
+}
+
+func (j *jitterFlag) Set(value string) error {
+	jitter, err := strconv.ParseFloat(value, 64)
+	if err != nil || !(jitter >= 0 && jitter <= 1) {
+		return cli.Usagef("expected a fraction from 0 to 1")
+	}
+	*j = jitterFlag(jitter)
+	return nil
+}
+
+// fileIO creates a FileIO mapping catalog locations to the local warehouse
+func (o *catalogOptions) fileIO() *iceberg.FileIO {
+	return iceberg.NewFileIO(o.warehouseDir, o.warehouseLocation)
+}
+
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// parseInterspersed parses flags that may appear before or after positional arguments,
+// sets the flags not given from the project config and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			if _, err := project.Apply(fs); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				var usageErr *cli.UsageError
+				if errors.As(err, &usageErr) {
+					os.Exit(cli.ExitUsage)
+				}
+				os.Exit(cli.ExitFailure)
+			}
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseTableIdentifier splits "namespace.table" into its parts, using defaultNamespace for bare table names
+func parseTableIdentifier(identifier, defaultNamespace string) (string, string) {
+	if i := strings.LastIndex(identifier, "."); i > 0 {
+		return identifier[:i], identifier[i+1:]
+	}
+	return defaultNamespace, identifier
+}
@@ -0,0 +1,36 @@
+package warehouse
+
+import (
+	"errors"
+	"testing"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+)
+
+func TestRetryFlags(t *testing.T) {
+	fs := cli.NewFlagSet("test")
+	opts := addCatalogFlags(fs)
+	if opts.retry != iceberg.DefaultRetryPolicy {
+		t.Errorf("default retry policy %+v, want %+v", opts.retry, iceberg.DefaultRetryPolicy)
+	}
+
+	for _, value := range []string{"0", "0.25", "1"} {
+		if err := fs.Set("retry-jitter", value); err != nil {
+			t.Errorf("--retry-jitter %s: %v", value, err)
+		}
+	}
+	if opts.retry.Jitter != 1 {
+		t.Errorf("jitter %v, want 1", opts.retry.Jitter)
+	}
+
+	for _, value := range []string{"-0.1", "1.5", "NaN", "half"} {
+		var usageErr *cli.UsageError
+		if err := fs.Set("retry-jitter", value); !errors.As(err, &usageErr) {
+			t.Errorf("--retry-jitter %s: error %v, want a usage error", value, err)
+		}
+	}
+	if opts.retry.Jitter != 1 {
+		t.Errorf("an invalid --retry-jitter changed the jitter to %v", opts.retry.Jitter)
+	}
+}
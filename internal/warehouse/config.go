@@ -0,0 +1,288 @@
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"the-modern-data-stack/internal/iceberg"
+)
+
+// CatalogConfig declares the namespaces and tables of the catalog and their properties
+type CatalogConfig struct {
+	Namespaces map[string]NamespaceConfig `yaml:"namespaces"`
+}
+
+// NamespaceConfig declares the properties of a namespace and of its tables
+type NamespaceConfig struct {
+	Owner       string                 `yaml:"owner"`
+	Description string                 `yaml:"description"`
+	Location    string                 `yaml:"location"`
+	Properties  map[string]*string     `yaml:"properties"`
+	Tables      map[string]TableConfig `yaml:"tables"`
+}
+
+// TableConfig declares the properties of a table and, for plan and apply, its source
+// files, column types, partitioning and load mode
+type TableConfig struct {
+	Comment     string             `yaml:"comment"`
+	Owner       string             `yaml:"owner"`
+	Retention   *RetentionConfig   `yaml:"retention"`
+	Properties  map[string]*string `yaml:"properties"`
+	Source      string             `yaml:"source"`
+	Columns     map[string]string  `yaml:"columns"`
+	PartitionBy []string           `yaml:"partition_by"`
+	LoadMode    string             `yaml:"load_mode"`
+}
+
+// RetentionConfig declares how long snapshot expiration keeps the history of a table
+type RetentionConfig struct {
+	MaxSnapshotAge     string `yaml:"max_snapshot_age"`
+	MinSnapshotsToKeep int    `yaml:"min_snapshots_to_keep"`
+	MaxRefAge          string `yaml:"max_ref_age"`
+}
+
+// LoadCatalogConfig reads a catalog config file. A missing file yields an empty config.
+func LoadCatalogConfig(path string) (*CatalogConfig, error) {
+	config := &CatalogConfig{}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid catalog config %s: %v", path, err)
+	}
+
+	// Report invalid retention settings before anything is changed
+	for nsName, ns := range config.Namespaces {
+		for tableName, table := range ns.Tables {
+			if _, _, err := table.DesiredProperties(); err != nil {
+				return nil, fmt.Errorf("invalid catalog config %s: table %s.%s: %v", path, nsName, tableName, err)
+			}
+		}
+	}
+	return config, nil
+}
+
+// parseRetention parses a duration such as "7d", "12h" or "90m" into milliseconds
+func parseRetention(value string) (int64, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return int64(n) * 24 * time.Hour.Milliseconds(), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q, expected e.g. 7d or 12h", value)
+	}
+	return d.Milliseconds(), nil
+}
+
+// customProperties splits declared custom properties into the ones to set and the ones
+// set to null, which are removed
+func customProperties(declared map[string]*string, set map[string]string) []string {
+	var removals []string
+	for key, value := range declared {
+		if value == nil {
+			removals = append(removals, key)
+		} else {
+			set[key] = *value
+		}
+	}
+	return removals
+}
+
+// DesiredProperties returns the namespace properties to set and to remove
+func (n NamespaceConfig) DesiredProperties() (map[string]string, []string) {
+	set := make(map[string]string)
+	removals := customProperties(n.Properties, set)
+	if n.Owner != "" {
+		set["owner"] = n.Owner
+	}
+	if n.Description != "" {
+		set["comment"] = n.Description
+	}
+	if n.Location != "" {
+		set["location"] = n.Location
+	}
+	return set, removals
+}
+
+// DesiredProperties returns the table properties to set and to remove
+func (t TableConfig) DesiredProperties() (map[string]string, []string, error) {
+	set := make(map[string]string)
+	removals := customProperties(t.Properties, set)
+	if t.Comment != "" {
+		set["comment"] = t.Comment
+	}
+	if t.Owner != "" {
+		set["owner"] = t.Owner
+	}
+	if r := t.Retention; r != nil {
+		if r.MaxSnapshotAge != "" {
+			ms, err := parseRetention(r.MaxSnapshotAge)
+			if err != nil {
+				return nil, nil, fmt.Errorf("max_snapshot_age: %v", err)
+			}
+			set["history.expire.max-snapshot-age-ms"] = strconv.FormatInt(ms, 10)
+		}
+		if r.MinSnapshotsToKeep < 0 {
+			return nil, nil, fmt.Errorf("min_snapshots_to_keep must be positive")
+		} else if r.MinSnapshotsToKeep > 0 {
+			set["history.expire.min-snapshots-to-keep"] = strconv.Itoa(r.MinSnapshotsToKeep)
+		}
+		if r.MaxRefAge != "" {
+			ms, err := parseRetention(r.MaxRefAge)
+			if err != nil {
+				return nil, nil, fmt.Errorf("max_ref_age: %v", err)
+			}
+			set["history.expire.max-ref-age-ms"] = strconv.FormatInt(ms, 10)
+		}
+	}
+	return set, removals, nil
+}
+
+// propertyDiff returns the properties that differ from the current ones and the
+// properties to remove that are currently set, as lines of a diff
+func propertyDiff(current, set map[string]string, removals []string) (map[string]string, []string, []string) {
+	updates := make(map[string]string)
+	var removed, lines []string
+
+	for key, value := range set {
+		old, ok := current[key]
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("+ %s = %q", key, value))
+		case old != value:
+			lines = append(lines, fmt.Sprintf("~ %s: %q → %q", key, old, value))
+		default:
+			continue
+		}
+		updates[key] = value
+	}
+	for _, key := range removals {
+		if old, ok := current[key]; ok {
+			removed = append(removed, key)
+			lines = append(lines, fmt.Sprintf("- %s (was %q)", key, old))
+		}
+	}
+
+	// Sort by key, whatever the kind of change
+	sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+	sort.Strings(removed)
+	return updates, removed, lines
+}
+
+// applyNamespaceProperties brings the properties of a namespace in line with its config
+func applyNamespaceProperties(ctx context.Context, out io.Writer, client *iceberg.Client, namespace string, config NamespaceConfig) error {
+	set, removals := config.DesiredProperties()
+	current, err := client.LoadNamespaceProperties(ctx, namespace)
+	if iceberg.IsNotFound(err) {
+		fmt.Fprintf(out, "   📝 Namespace '%s': created\n", namespace)
+		for _, key := range sortedKeys(set) {
+			fmt.Fprintf(out, "      + %s = %q\n", key, set[key])
+		}
+		return client.CreateNamespace(ctx, namespace, set)
+	}
+	if err != nil {
+		return err
+	}
+
+	updates, removed, lines := propertyDiff(current, set, removals)
+	if len(lines) == 0 {
+		fmt.Fprintf(out, "   ✅ Namespace '%s': up to date\n", namespace)
+		return nil
+	}
+	fmt.Fprintf(out, "   📝 Namespace '%s':\n", namespace)
+	for _, line := range lines {
+		fmt.Fprintf(out, "      %s\n", line)
+	}
+	return client.UpdateNamespaceProperties(ctx, namespace, removed, updates)
+}
+
+// applyTableProperties brings the properties of a table in line with its config
+func applyTableProperties(ctx context.Context, out io.Writer, client *iceberg.Client, namespace, tableName string, config TableConfig) error {
+	set, removals, err := config.DesiredProperties()
+	if err != nil {
+		return err
+	}
+	table, err := client.LoadTable(ctx, namespace, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &table.Metadata
+
+	updates, removed, lines := propertyDiff(metadata.Properties, set, removals)
+	if len(lines) == 0 {
+		fmt.Fprintf(out, "   ✅ Table '%s.%s': up to date\n", namespace, tableName)
+		return nil
+	}
+	fmt.Fprintf(out, "   📝 Table '%s.%s':\n", namespace, tableName)
+	for _, line := range lines {
+		fmt.Fprintf(out, "      %s\n", line)
+	}
+
+	commit := &iceberg.TableCommit{
+		Requirements: []iceberg.TableRequirement{iceberg.AssertTableUUID(metadata.TableUUID)},
+	}
+	if len(updates) > 0 {
+		commit.Updates = append(commit.Updates, iceberg.SetProperties(updates))
+	}
+	if len(removed) > 0 {
+		commit.Updates = append(commit.Updates, iceberg.RemoveProperties(removed))
+	}
+	_, err = client.CommitTable(ctx, namespace, tableName, commit)
+	return err
+}
+
+// ApplyCatalogConfig applies the declared properties of every namespace and table.
+// Properties the config does not mention are left untouched.
+func ApplyCatalogConfig(ctx context.Context, out io.Writer, client *iceberg.Client, config *CatalogConfig) int {
+	failed := 0
+	for _, namespace := range sortedKeys(config.Namespaces) {
+		ns := config.Namespaces[namespace]
+		if err := applyNamespaceProperties(ctx, out, client, namespace, ns); err != nil {
+			fmt.Fprintf(out, "   ❌ Namespace '%s': %v\n", namespace, err)
+			failed++
+			continue
+		}
+		for _, tableName := range sortedKeys(ns.Tables) {
+			if err := applyTableProperties(ctx, out, client, namespace, tableName, ns.Tables[tableName]); err != nil {
+				if iceberg.IsNotFound(err) {
+					fmt.Fprintf(out, "   ⚠️  Table '%s.%s' is declared but does not exist, skipping...\n", namespace, tableName)
+					continue
+				}
+				fmt.Fprintf(out, "   ❌ Table '%s.%s': %v\n", namespace, tableName, err)
+				failed++
+			}
+		}
+	}
+	return failed
+}
+
+// sortedKeys returns the keys of a map in alphabetical order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
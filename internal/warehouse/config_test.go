@@ -0,0 +1,149 @@
+package warehouse
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTableDesiredProperties(t *testing.T) {
+	team := "data"
+	config := TableConfig{
+		Comment: "Daily sales",
+		Owner:   "analytics",
+		Retention: &RetentionConfig{
+			MaxSnapshotAge:     "7d",
+			MinSnapshotsToKeep: 5,
+			MaxRefAge:          "12h",
+		},
+		Properties: map[string]*string{"team": &team, "legacy": nil, "write.format.default": nil},
+	}
+
+	set, removals, err := config.DesiredProperties()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"comment":                              "Daily sales",
+		"owner":                                "analytics",
+		"team":                                 "data",
+		"history.expire.max-snapshot-age-ms":   "604800000",
+		"history.expire.min-snapshots-to-keep": "5",
+		"history.expire.max-ref-age-ms":        "43200000",
+	}
+	if !reflect.DeepEqual(set, want) {
+		t.Errorf("properties to set %v, want %v", set, want)
+	}
+	sort.Strings(removals)
+	if want := []string{"legacy", "write.format.default"}; !reflect.DeepEqual(removals, want) {
+		t.Errorf("properties to remove %v, want %v", removals, want)
+	}
+
+	// The declared settings win over the custom properties of the same key
+	owner := "someone else"
+	set, _, err = TableConfig{Owner: "analytics", Properties: map[string]*string{"owner": &owner}}.DesiredProperties()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set["owner"] != "analytics" {
+		t.Errorf("owner = %q, want the declared owner", set["owner"])
+	}
+
+	for _, retention := range []RetentionConfig{
+		{MaxSnapshotAge: "a week"},
+		{MaxSnapshotAge: "0d"},
+		{MaxRefAge: "-1h"},
+		{MinSnapshotsToKeep: -1},
+	} {
+		if _, _, err := (TableConfig{Retention: &retention}).DesiredProperties(); err == nil {
+			t.Errorf("retention %+v was accepted", retention)
+		}
+	}
+}
+
+func TestNamespaceDesiredProperties(t *testing.T) {
+	config := NamespaceConfig{
+		Owner:       "analytics",
+		Description: "Sales data",
+		Location:    "s3://bucket/sales",
+		Properties:  map[string]*string{"old": nil},
+	}
+	set, removals := config.DesiredProperties()
+	want := map[string]string{"owner": "analytics", "comment": "Sales data", "location": "s3://bucket/sales"}
+	if !reflect.DeepEqual(set, want) {
+		t.Errorf("properties to set %v, want %v", set, want)
+	}
+	if !reflect.DeepEqual(removals, []string{"old"}) {
+		t.Errorf("properties to remove %v, want [old]", removals)
+	}
+}
+
+func TestPropertyDiff(t *testing.T) {
+	current := map[string]string{"owner": "analytics", "comment": "Sales", "legacy": "true", "team": "data"}
+	set := map[string]string{"owner": "analytics", "comment": "Daily sales", "write.format.default": "parquet"}
+	removals := []string{"team", "legacy", "absent"}
+
+	updates, removed, lines := propertyDiff(current, set, removals)
+	if want := map[string]string{"comment": "Daily sales", "write.format.default": "parquet"}; !reflect.DeepEqual(updates, want) {
+		t.Errorf("updates %v, want %v", updates, want)
+	}
+	if want := []string{"legacy", "team"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	want := []string{
+		`~ comment: "Sales" → "Daily sales"`,
+		`- legacy (was "true")`,
+		`- team (was "data")`,
+		`+ write.format.default = "parquet"`,
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("diff:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	updates, removed, lines = propertyDiff(current, map[string]string{"owner": "analytics"}, []string{"absent"})
+	if len(updates) != 0 || len(removed) != 0 || len(lines) != 0 {
+		t.Errorf("properties in line with the config gave updates %v, removals %v and diff %v", updates, removed, lines)
+	}
+}
+
+func TestLoadCatalogConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "catalog.yaml")
+	content := `
+namespaces:
+  sales:
+    owner: analytics
+    properties:
+      legacy: null
+    tables:
+      orders:
+        retention:
+          max_snapshot_age: 7d
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadCatalogConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, removals := config.Namespaces["sales"].DesiredProperties()
+	if !reflect.DeepEqual(removals, []string{"legacy"}) {
+		t.Errorf("properties to remove %v, want the property set to null", removals)
+	}
+
+	invalid := strings.Replace(content, "7d", "soon", 1)
+	if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCatalogConfig(path); err == nil || !strings.Contains(err.Error(), "sales.orders") {
+		t.Errorf("error %v, want one naming the table with an invalid retention", err)
+	}
+
+	if config, err := LoadCatalogConfig(filepath.Join(dir, "missing.yaml")); err != nil || len(config.Namespaces) != 0 {
+		t.Errorf("missing config gave %+v, %v, want an empty config", config, err)
+	}
+}
@@ -0,0 +1,525 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/dictionary"
+	"the-modern-data-stack/internal/files"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/lineage"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/report"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// waitForCatalog waits for the Iceberg REST Catalog to be available, retrying as the
+// retry policy of the client allows
+func waitForCatalog(ctx context.Context, out io.Writer, client *iceberg.Client) error {
+	fmt.Fprintln(out, "🔍 Checking HTTP connectivity to catalog...")
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("catalog HTTP endpoint not responding after %d attempt(s): %w", max(client.Retry.MaxAttempts, 1), err)
+	}
+	fmt.Fprintln(out, "✅ Catalog HTTP endpoint is responding")
+	return nil
+}
+
+// initDuckDB initializes a DuckDB connection and installs required extensions
+func initDuckDB(ctx context.Context) (*sql.DB, error) {
+	db, err := logging.OpenDB("duckdb", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DuckDB: %v", err)
+	}
+
+	// Install and load required extensions
+	extensions := []string{
+		"INSTALL parquet",
+		"LOAD parquet",
+	}
+
+	for _, ext := range extensions {
+		if _, err := db.ExecContext(ctx, ext); err != nil {
+			// Ignore errors for already installed extensions
+			slog.Debug("extension command failed, which is often normal", "command", ext, "error", err)
+		}
+	}
+
+	return db, nil
+}
+
+// readParquetSchemaWithDuckDB reads the schema from a Parquet file using DuckDB Go client
+func readParquetSchemaWithDuckDB(ctx context.Context, db *sql.DB, filePath string) (iceberg.Schema, error) {
+	// Build the DuckDB query to describe the Parquet file
+	query := fmt.Sprintf("DESCRIBE SELECT * FROM read_parquet(%s%s)", sqlutil.QuoteString(filePath), sourceOptions)
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return iceberg.Schema{}, fmt.Errorf("failed to execute DuckDB query: %v", err)
+	}
+	defer rows.Close()
+
+	// Convert each described column to an Iceberg field
+	var fields []iceberg.Field
+	for rows.Next() {
+		var name, typ, null string
+		var key, defaultVal, extra sql.NullString
+
+		err := rows.Scan(&name, &typ, &null, &key, &defaultVal, &extra)
+		if err != nil {
+			return iceberg.Schema{}, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		fieldType, err := icebergType(typ)
+		if err != nil {
+			return iceberg.Schema{}, fmt.Errorf("column %s: %v", name, err)
+		}
+
+		fields = append(fields, iceberg.Field{
+			ID:       len(fields) + 1, // Iceberg field IDs start from 1
+			Name:     name,
+			Required: null == "NO", // Convert NULL column to Required field
+			Type:     fieldType,
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		return iceberg.Schema{}, fmt.Errorf("error reading rows: %v", err)
+	}
+
+	if len(fields) == 0 {
+		return iceberg.Schema{}, fmt.Errorf("no columns found in parquet file schema")
+	}
+
+	return iceberg.Schema{
+		Type:     "struct",
+		SchemaID: 0,
+		Fields:   fields,
+	}, nil
+}
+
+// readParquetSampleDataWithDuckDB reads sample data from a Parquet file using DuckDB Go client
+func readParquetSampleDataWithDuckDB(ctx context.Context, db *sql.DB, filePath string, limit int) ([]map[string]interface{}, error) {
+	// Build the DuckDB query to read sample data
+	query := fmt.Sprintf("SELECT * FROM read_parquet(%s%s) LIMIT %d", sqlutil.QuoteString(filePath), sourceOptions, limit)
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute DuckDB query: %v", err)
+	}
+	defer rows.Close()
+
+	// Get column information
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %v", err)
+	}
+
+	var sampleData []map[string]interface{}
+
+	// Read the data rows
+	for rows.Next() {
+		// Create a slice of interface{} to hold the values
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+
+		for i := range columns {
+			valuePtrs[i] = &values[i]
+		}
+
+		// Scan the row
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		// Create a map for this row
+		row := make(map[string]interface{})
+		for i, col := range columns {
+			var value interface{}
+			if values[i] != nil {
+				// Convert byte slices to strings for better display
+				if b, ok := values[i].([]byte); ok {
+					value = string(b)
+				} else {
+					value = values[i]
+				}
+			}
+			row[col] = value
+		}
+
+		sampleData = append(sampleData, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %v", err)
+	}
+
+	return sampleData, nil
+}
+
+// getParquetRowCount gets the total number of rows in a Parquet file
+func getParquetRowCount(ctx context.Context, db *sql.DB, filePath string) (int64, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM read_parquet(%s%s)", sqlutil.QuoteString(filePath), sourceOptions)
+
+	var count int64
+	err := db.QueryRowContext(ctx, query).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get row count: %v", err)
+	}
+
+	return count, nil
+}
+
+// createBasicSchema creates a basic Iceberg schema for a table (fallback)
+func createBasicSchema() iceberg.Schema {
+	return iceberg.Schema{
+		Type:     "struct",
+		SchemaID: 0,
+		Fields: []iceberg.Field{
+			{ID: 1, Name: "id", Type: "long"},
+			{ID: 2, Name: "data", Type: "string"},
+			{ID: 3, Name: "timestamp", Type: "timestamp"},
+		},
+	}
+}
+
+// documentSchema sets the doc of the schema fields the data dictionary documents
+func documentSchema(schema *iceberg.Schema, dict *dictionary.Dictionary, tableName string) int {
+	documented := 0
+	for i := range schema.Fields {
+		if entry, ok := dict.Column(tableName, schema.Fields[i].Name); ok {
+			schema.Fields[i].Doc = entry.Doc()
+			documented++
+		}
+	}
+	return documented
+}
+
+// printDryRunRequest prints a request the catalog would receive without a dry run
+func printDryRunRequest(out io.Writer, method, url string, payload interface{}) {
+	jsonData, err := json.MarshalIndent(payload, "   ", "  ")
+	if err != nil {
+		fmt.Fprintf(out, "⚠️  Failed to marshal the request: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "📝 Would send %s %s\n   %s\n", method, url, jsonData)
+}
+
+// createNamespace creates a namespace with the given properties. An existing namespace is
+// left as is. With dryRun, it prints the request instead of sending it.
+func createNamespace(ctx context.Context, out io.Writer, client *iceberg.Client, catalogURL, namespace string, properties map[string]string, dryRun bool) error {
+	if dryRun {
+		if properties == nil {
+			properties = map[string]string{}
+		}
+		printDryRunRequest(out, http.MethodPost, catalogURL+"/v1/namespaces", map[string]interface{}{
+			"namespace":  []string{namespace},
+			"properties": properties,
+		})
+		return nil
+	}
+
+	if err := client.CreateNamespace(ctx, namespace, properties); err != nil && !iceberg.IsCommitConflict(err) {
+		return err
+	}
+	return nil
+}
+
+// createTable creates an Iceberg table. With dryRun, it prints the request instead of sending it.
+func createTable(ctx context.Context, out io.Writer, client *iceberg.Client, catalogURL, namespace string, request *iceberg.CreateTableRequest, dryRun bool) error {
+	if dryRun {
+		printDryRunRequest(out, http.MethodPost, fmt.Sprintf("%s/v1/namespaces/%s/tables", catalogURL, namespace), request)
+		return nil
+	}
+	_, err := client.CreateTable(ctx, namespace, request)
+	return err
+}
+
+// connectToCatalog waits for the Iceberg REST Catalog to come up
+func connectToCatalog(ctx context.Context, out io.Writer, client *iceberg.Client) error {
+	fmt.Fprintln(out, "\n🔗 Connecting to Iceberg REST Catalog...")
+	fmt.Fprintln(out, "💡 Make sure the Iceberg REST Catalog is running:")
+	fmt.Fprintln(out, "   docker run -d --rm -p 8181:8181 \\")
+	fmt.Fprintln(out, "     -v $PWD/data/iceberg_warehouse:/var/lib/iceberg/warehouse \\")
+	fmt.Fprintln(out, "     -e CATALOG_WAREHOUSE=/var/lib/iceberg/warehouse \\")
+	fmt.Fprintln(out, "     -e CATALOG_IO__IMPL=org.apache.iceberg.hadoop.HadoopFileIO \\")
+	fmt.Fprintln(out, "     --name iceberg-rest tabulario/iceberg-rest")
+
+	if err := waitForCatalog(ctx, out, client); err != nil {
+		return fmt.Errorf("failed to connect to Iceberg REST Catalog: %w", err)
+	}
+
+	fmt.Fprintln(out, "✅ Connected to Iceberg REST Catalog")
+	return nil
+}
+
+func runCreateTables(ctx context.Context, args []string) (err error) {
+	fs := cli.NewFlagSet("create")
+	opts := addCatalogFlags(fs)
+	namespaceName := fs.String("namespace", "my_data", "Namespace to create the tables in")
+	parquetDir := fs.String("parquet-dir", "data/parquet", "Directory holding the Parquet files, one table per file")
+	configPath := fs.String("config", "catalog.yaml", "Declarative config of namespace and table properties (skipped when missing)")
+	dictionaryPath := fs.String("dictionary", "data_dictionary.csv", "Data dictionary (.csv or .yaml) providing the column docs (skipped when missing)")
+	dryRun := fs.Bool("dry-run", false, "Infer the schemas and print the requests that would be sent to the catalog, without contacting it")
+	output := report.AddFlags(fs)
+	if positional := parseInterspersed(fs, args); len(positional) > 0 {
+		return cli.Usagef("unexpected arguments %s", strings.Join(positional, " "))
+	}
+	rep, err := output.Start("tables create", *dryRun)
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.Close(err) }()
+	out := rep.Out
+
+	fmt.Fprintln(out, "🧊 Iceberg Table Creator (Apache Iceberg Go - Enhanced with DuckDB Go Client)")
+	if *dryRun {
+		fmt.Fprintln(out, "🔍 Dry run: nothing is sent to the catalog")
+	}
+
+	config, err := LoadCatalogConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	dict, err := dictionary.Load(*dictionaryPath)
+	if err != nil {
+		return err
+	}
+
+	// Initialize DuckDB connection
+	fmt.Fprintln(out, "🦆 Initializing DuckDB connection...")
+	db, err := initDuckDB(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize DuckDB: %v", err)
+	}
+	defer db.Close()
+	fmt.Fprintln(out, "✅ DuckDB connection established")
+
+	// Check if the Parquet directory exists
+	if _, err := os.Stat(*parquetDir); os.IsNotExist(err) {
+		fmt.Fprintf(out, "⚠️  Parquet directory '%s' does not exist.\n", *parquetDir)
+		fmt.Fprintln(out, "💡 Please run 'mds convert' first to create Parquet files")
+		return nil
+	}
+
+	// Find all Parquet files, by table
+	parquetTables, err := files.FindParquetTables(*parquetDir)
+	if err != nil {
+		return fmt.Errorf("failed to search for Parquet files: %v", err)
+	}
+
+	if len(parquetTables) == 0 {
+		fmt.Fprintf(out, "⚠️  No Parquet files found in '%s' directory\n", *parquetDir)
+		fmt.Fprintln(out, "💡 Please run 'mds convert' first to create Parquet files")
+		return nil
+	}
+
+	fmt.Fprintf(out, "📊 Found %d Parquet table(s):\n", len(parquetTables))
+	for _, table := range parquetTables {
+		relPath, _ := filepath.Rel(*parquetDir, table.Path)
+		if table.Path != table.Source {
+			fmt.Fprintf(out, "   - %s/ (%d files)\n", relPath, len(table.Files))
+		} else {
+			fmt.Fprintf(out, "   - %s\n", relPath)
+		}
+	}
+
+	// Wait for and connect to Iceberg REST Catalog
+	catalogURL := opts.catalogURL
+	client := opts.client()
+	if *dryRun {
+		fmt.Fprintf(out, "\n🔗 Requests are shown for the Iceberg REST Catalog at %s\n", catalogURL)
+	} else if err := connectToCatalog(ctx, out, client); err != nil {
+		return err
+	}
+
+	// Create namespace
+	namespaceConfig := config.Namespaces[*namespaceName]
+	namespaceProperties, _ := namespaceConfig.DesiredProperties()
+	fmt.Fprintf(out, "📁 Creating namespace '%s'...\n", *namespaceName)
+
+	// Try to create namespace, ignore if it already exists
+	err = createNamespace(ctx, out, client, catalogURL, *namespaceName, namespaceProperties, *dryRun)
+	if err != nil {
+		fmt.Fprintf(out, "ℹ️  Namespace may already exist: %v\n", err)
+	} else if !*dryRun {
+		fmt.Fprintf(out, "✅ Namespace '%s' created successfully\n", *namespaceName)
+	}
+
+	// Create Iceberg tables from Parquet files
+	fmt.Fprintln(out, "\n🧊 Creating Iceberg tables with real schemas...")
+	successCount := 0
+
+	for _, parquetTable := range parquetTables {
+		relPath, _ := filepath.Rel(*parquetDir, parquetTable.Path)
+		tableName := parquetTable.Name
+		parquetFile := parquetTable.Source
+
+		file := rep.Add(parquetTable.Path, *namespaceName+"."+tableName)
+		for _, path := range parquetTable.Files {
+			if info, err := os.Stat(path); err == nil {
+				file.Bytes += info.Size()
+			}
+		}
+
+		logger := slog.With("namespace", *namespaceName, "table", tableName, "file", relPath)
+
+		fmt.Fprintf(out, "\n🔄 Processing table '%s.%s' from %s...\n", *namespaceName, tableName, relPath)
+
+		// Get row count first
+		rowCount, err := getParquetRowCount(ctx, db, parquetFile)
+		if err != nil {
+			logger.Warn("failed to get the row count", "error", err)
+		} else {
+			file.Rows = rowCount
+			logger = logger.With("rows", rowCount)
+			fmt.Fprintf(out, "📊 Data: %d rows in Parquet file\n", rowCount)
+		}
+
+		// Show where the data comes from; loads record it in the summary of their snapshots
+		if entries, err := lineage.Read(ctx, db, sqlutil.QuoteString(parquetFile)); err != nil {
+			logger.Warn("failed to read the lineage metadata", "error", err)
+		} else if len(entries) > 0 {
+			fmt.Fprintln(out, "🧬 Lineage (recorded in the snapshot summary of each load):")
+			for _, key := range lineage.Keys(entries) {
+				fmt.Fprintf(out, "   - %s: %s\n", key, entries[key])
+			}
+		}
+
+		// Read the actual Parquet schema using DuckDB Go client
+		fmt.Fprintln(out, "📋 Reading Parquet schema with DuckDB Go client...")
+		icebergSchema, err := readParquetSchemaWithDuckDB(ctx, db, parquetFile)
+		if err != nil {
+			logger.Warn("failed to read the Parquet schema, using a basic template", "error", err)
+			icebergSchema = createBasicSchema()
+		}
+
+		fmt.Fprintf(out, "📊 Schema: %d fields (from Parquet file)\n", len(icebergSchema.Fields))
+		if documented := documentSchema(&icebergSchema, dict, tableName); documented > 0 {
+			fmt.Fprintf(out, "📖 Docs: %d fields documented by %s\n", documented, *dictionaryPath)
+		}
+		for _, field := range icebergSchema.Fields {
+			file.Schema = append(file.Schema, report.Column{Name: field.Name, Type: fmt.Sprint(field.Type)})
+		}
+		for i, field := range icebergSchema.Fields {
+			if i < 5 { // Show first 5 fields
+				required := ""
+				if field.Required {
+					required = " (required)"
+				}
+				fmt.Fprintf(out, "   - %s: %s%s\n", field.Name, field.Type, required)
+			} else if i == 5 {
+				fmt.Fprintf(out, "   ... and %d more fields\n", len(icebergSchema.Fields)-5)
+				break
+			}
+		}
+
+		// Create Iceberg table
+		fmt.Fprintf(out, "🔨 Creating Iceberg table '%s.%s'...\n", *namespaceName, tableName)
+
+		// Properties are validated when the config is loaded
+		tableProperties, _, _ := namespaceConfig.Tables[tableName].DesiredProperties()
+		request := &iceberg.CreateTableRequest{Name: tableName, Schema: icebergSchema, Properties: tableProperties}
+		err = createTable(ctx, out, client, catalogURL, *namespaceName, request, *dryRun)
+		if err != nil {
+			if iceberg.IsCommitConflict(err) {
+				fmt.Fprintf(out, "⚠️  Table '%s.%s' already exists, skipping...\n", *namespaceName, tableName)
+				file.Finish(report.StatusSkipped)
+				continue
+			}
+			logger.Error("failed to create the table", "error", err)
+			file.Fail(fmt.Errorf("failed to create table: %v", err))
+			continue
+		}
+		if *dryRun {
+			file.Finish(report.StatusPlanned)
+			successCount++
+			continue
+		}
+
+		fmt.Fprintf(out, "✅ Created Iceberg table '%s.%s'\n", *namespaceName, tableName)
+
+		// Read and display sample data
+		fmt.Fprintln(out, "📖 Reading sample data from Parquet file...")
+		sampleData, err := readParquetSampleDataWithDuckDB(ctx, db, parquetFile, 3)
+		if err != nil {
+			logger.Warn("failed to read sample data", "error", err)
+		} else {
+			fmt.Fprintf(out, "📊 Sample data (%d rows shown):\n", len(sampleData))
+			for i, row := range sampleData {
+				fmt.Fprintf(out, "   Row %d: ", i+1)
+				fieldCount := 0
+				for key, value := range row {
+					if fieldCount >= 3 { // Show only first 3 fields per row
+						fmt.Fprintf(out, "...")
+						break
+					}
+					fmt.Fprintf(out, "%s=%v ", key, value)
+					fieldCount++
+				}
+				fmt.Fprintln(out)
+			}
+		}
+
+		file.Finish(report.StatusOK)
+		logger.Debug("created table", "duration_ms", file.DurationMs)
+		successCount++
+	}
+
+	if *dryRun {
+		fmt.Fprintf(out, "\n🔍 Dry run complete: %d table(s) would be created unless they already exist\n", successCount)
+		if len(config.Namespaces) > 0 || len(dict.Tables()) > 0 {
+			fmt.Fprintf(out, "💡 Property and doc updates depend on the current catalog state; run 'mds plan' to see them\n")
+		}
+		return nil
+	}
+
+	fmt.Fprintf(out, "\n🎉 Successfully processed %d Iceberg tables!\n", successCount)
+
+	// Bring existing namespaces and tables in line with the declared properties
+	updateFailures := 0
+	if len(config.Namespaces) > 0 {
+		fmt.Fprintf(out, "\n⚙️  Applying properties from %s...\n", *configPath)
+		updateFailures = ApplyCatalogConfig(ctx, out, client, config)
+	}
+
+	// Existing tables get the docs added or changed in the dictionary since they were created
+	if len(dict.Tables()) > 0 {
+		fmt.Fprintf(out, "\n📖 Applying column docs from %s...\n", *dictionaryPath)
+		updateFailures += ApplyDataDictionary(ctx, out, client, *namespaceName, dict)
+	}
+	if updateFailures > 0 {
+		rep.AddError(fmt.Errorf("%d property or doc update(s) failed", updateFailures))
+	}
+
+	// Show summary
+	fmt.Fprintln(out, "\n📊 Summary:")
+	fmt.Fprintf(out, "   - Namespace: %s\n", *namespaceName)
+	fmt.Fprintf(out, "   - Parquet tables processed: %d\n", len(parquetTables))
+	fmt.Fprintf(out, "   - Iceberg tables created: %d\n", successCount)
+	if updateFailures > 0 {
+		fmt.Fprintf(out, "   - Property and doc updates failed: %d\n", updateFailures)
+	}
+	fmt.Fprintf(out, "   - Catalog URI: %s\n", catalogURL)
+	fmt.Fprintf(out, "   - Warehouse location: %s\n", opts.warehouseDir)
+
+	fmt.Fprintln(out, "\n💡 Tables created with real Parquet schemas!")
+	fmt.Fprintln(out, "   - Tables now have the actual column structure from your data")
+	fmt.Fprintln(out, "   - Schema information is stored in Iceberg metadata")
+	fmt.Fprintln(out, "   - Nullability information is preserved from Parquet files")
+
+	fmt.Fprintln(out, "\n🔧 Next steps:")
+	fmt.Fprintln(out, "   - Load the Parquet files with 'mds load-all'")
+	fmt.Fprintln(out, "   - Inspect a table with 'mds inspect schema <table>'")
+	fmt.Fprintln(out, "   - Change its partitioning with 'mds tables set-partitioning'")
+	fmt.Fprintln(out, "   - Set up table maintenance with 'mds maintain'")
+	return nil
+}
@@ -0,0 +1,253 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// Delete modes, named after the write.delete.mode table property
+const (
+	deleteModeMergeOnRead = "merge-on-read"
+	deleteModeCopyOnWrite = "copy-on-write"
+)
+
+// positionDeleteFields are the reserved columns of position delete files
+var positionDeleteFields = []iceberg.Field{
+	{ID: 2147483546, Name: "file_path", Required: true, Type: "string"},
+	{ID: 2147483545, Name: "pos", Required: true, Type: "long"},
+}
+
+// affectedFile is a live data file holding rows matched by a delete predicate
+type affectedFile struct {
+	entry   iceberg.ManifestEntry
+	matched int64
+}
+
+// findAffectedFiles stores the positions of the rows matching the predicate in a
+// temporary table named matched and returns the data files they belong to
+func findAffectedFiles(ctx context.Context, db *sql.DB, entries []iceberg.ManifestEntry, tableName, where string) ([]affectedFile, error) {
+	createSQL := fmt.Sprintf("CREATE TEMP TABLE matched AS SELECT %s, %s FROM %s WHERE coalesce((%s), false)",
+		filePathColumn, positionColumn, sqlutil.QuoteIdentifier(tableName), where)
+	if _, err := db.ExecContext(ctx, createSQL); err != nil {
+		return nil, fmt.Errorf("invalid --where predicate: %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s, count(*) FROM matched GROUP BY 1 ORDER BY 1", filePathColumn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count matched rows: %v", err)
+	}
+	defer rows.Close()
+
+	byPath := make(map[string]iceberg.ManifestEntry)
+	for _, entry := range entries {
+		byPath[entry.DataFile.FilePath] = entry
+	}
+
+	var affected []affectedFile
+	for rows.Next() {
+		var path string
+		var count int64
+		if err := rows.Scan(&path, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		affected = append(affected, affectedFile{entry: byPath[path], matched: count})
+	}
+
+	return affected, rows.Err()
+}
+
+// writePositionDeletes writes a position delete file for the matched rows of a data file
+func writePositionDeletes(ctx context.Context, db *sql.DB, fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, file affectedFile) (iceberg.DataFile, error) {
+	selectSQL := fmt.Sprintf("SELECT %s AS file_path, %s AS pos FROM matched WHERE %s = %s ORDER BY pos",
+		filePathColumn, positionColumn, filePathColumn, sqlutil.QuoteString(file.entry.DataFile.FilePath))
+
+	deletes, err := writeParquetFile(ctx, db, fileIO, metadata, selectSQL, positionDeleteFields)
+	if err != nil {
+		return iceberg.DataFile{}, err
+	}
+	deletes.Content = iceberg.FileContentPositionDeletes
+	deletes.Partition = file.entry.DataFile.Partition
+	return deletes, nil
+}
+
+// rewriteDataFile writes the rows of a data file that are not matched into a new data file.
+// It reports false when no row is left, in which case nothing is written.
+func rewriteDataFile(ctx context.Context, db *sql.DB, fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, tableName string, file affectedFile) (iceberg.DataFile, bool, error) {
+	columns, err := queryColumns(ctx, db, fmt.Sprintf("SELECT * EXCLUDE (%s, %s) FROM %s", filePathColumn, positionColumn, sqlutil.QuoteIdentifier(tableName)))
+	if err != nil {
+		return iceberg.DataFile{}, false, fmt.Errorf("failed to describe %s: %v", tableName, err)
+	}
+	selects, err := schemaProjection(metadata.CurrentSchema(), columns, tableName)
+	if err != nil {
+		return iceberg.DataFile{}, false, err
+	}
+
+	remaining := fmt.Sprintf(`FROM %s t WHERE t.%s = %s
+AND NOT EXISTS (SELECT 1 FROM matched m WHERE m.%s = t.%s AND m.%s = t.%s)`,
+		sqlutil.QuoteIdentifier(tableName), filePathColumn, sqlutil.QuoteString(file.entry.DataFile.FilePath),
+		filePathColumn, filePathColumn, positionColumn, positionColumn)
+
+	var count int64
+	if err := db.QueryRowContext(ctx, "SELECT count(*) "+remaining).Scan(&count); err != nil {
+		return iceberg.DataFile{}, false, fmt.Errorf("failed to count remaining rows: %v", err)
+	}
+	if count == 0 {
+		return iceberg.DataFile{}, false, nil
+	}
+
+	rewritten, err := writeDataFile(ctx, db, fileIO, metadata, fmt.Sprintf("SELECT %s %s", strings.Join(selects, ", "), remaining))
+	if err != nil {
+		return iceberg.DataFile{}, false, err
+	}
+	rewritten.Partition = file.entry.DataFile.Partition
+	return rewritten, true, nil
+}
+
+func runDelete(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("delete")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	table := fs.String("table", "", "Table to delete rows from")
+	where := fs.String("where", "", "SQL predicate selecting the rows to delete")
+	branch := fs.String("branch", "main", "Branch to delete the rows from")
+	mode := fs.String("mode", "", "merge-on-read writes position delete files, copy-on-write rewrites the affected data files (default: the write.delete.mode table property, else merge-on-read)")
+	positional := parseInterspersed(fs, args)
+
+	if *table == "" && len(positional) == 1 {
+		*table = positional[0]
+	} else if len(positional) > 0 {
+		return cli.Usagef("expected at most one table")
+	}
+	if *table == "" || strings.TrimSpace(*where) == "" {
+		return cli.Usagef("expected --table and --where")
+	}
+	ns, tableName := parseTableIdentifier(*table, *namespace)
+
+	client := opts.client()
+	fileIO := opts.fileIO()
+
+	loaded, err := client.LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &loaded.Metadata
+
+	if *mode == "" {
+		*mode = metadata.Properties["write.delete.mode"]
+		if *mode == "" {
+			*mode = deleteModeMergeOnRead
+		}
+	}
+	if *mode != deleteModeMergeOnRead && *mode != deleteModeCopyOnWrite {
+		return fmt.Errorf("unknown delete mode %q, expected %s or %s", *mode, deleteModeMergeOnRead, deleteModeCopyOnWrite)
+	}
+
+	snapshot := metadata.BranchSnapshot(*branch)
+	if snapshot == nil {
+		fmt.Printf("ℹ️  Branch %s of '%s.%s' has no data, nothing to delete\n", *branch, ns, tableName)
+		return nil
+	}
+	schema := metadata.CurrentSchema()
+	if schema == nil {
+		return fmt.Errorf("table metadata has no current schema")
+	}
+
+	entries, err := fileIO.ReadSnapshotEntries(snapshot)
+	if err != nil {
+		return err
+	}
+	scanSQL, err := buildPositionScanSQL(fileIO, schema, entries)
+	if err != nil {
+		return err
+	}
+	if scanSQL == "" {
+		fmt.Printf("ℹ️  Branch %s of '%s.%s' has no data, nothing to delete\n", *branch, ns, tableName)
+		return nil
+	}
+
+	db, err := logging.OpenDB("duckdb", "")
+	if err != nil {
+		return fmt.Errorf("failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	// The temporary matched table must live on the same connection as the view
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE VIEW %s AS %s", sqlutil.QuoteIdentifier(tableName), scanSQL)); err != nil {
+		return fmt.Errorf("failed to create view for %s: %v", tableName, err)
+	}
+
+	affected, err := findAffectedFiles(ctx, db, entries, tableName, *where)
+	if err != nil {
+		return err
+	}
+	if len(affected) == 0 {
+		fmt.Printf("ℹ️  No rows of '%s.%s' match %s\n", ns, tableName, *where)
+		return nil
+	}
+
+	var total int64
+	for _, file := range affected {
+		total += file.matched
+	}
+	fmt.Printf("🗑️  Deleting %d row(s) in %d data file(s) of branch %s of '%s.%s' (%s)...\n",
+		total, len(affected), *branch, ns, tableName, *mode)
+
+	// Rewriting files replaces data, while deleting rows or whole files only removes it
+	operation := iceberg.OperationDelete
+	var written []iceberg.DataFile
+	var removed []string
+	for _, file := range affected {
+		if *mode == deleteModeMergeOnRead {
+			deletes, err := writePositionDeletes(ctx, db, fileIO, metadata, file)
+			if err != nil {
+				removeDataFiles(fileIO, written)
+				return err
+			}
+			written = append(written, deletes)
+			fmt.Printf("   - %s: %d position delete(s)\n", file.entry.DataFile.FilePath, file.matched)
+			continue
+		}
+
+		rewritten, ok, err := rewriteDataFile(ctx, db, fileIO, metadata, tableName, file)
+		if err != nil {
+			removeDataFiles(fileIO, written)
+			return err
+		}
+		removed = append(removed, file.entry.DataFile.FilePath)
+		if ok {
+			written = append(written, rewritten)
+			operation = iceberg.OperationOverwrite
+			fmt.Printf("   - %s: rewritten with %d row(s)\n", file.entry.DataFile.FilePath, rewritten.RecordCount)
+		} else {
+			fmt.Printf("   - %s: removed, no row left\n", file.entry.DataFile.FilePath)
+		}
+	}
+
+	update := fileIO.NewSnapshotUpdate(metadata, *branch, operation)
+	for _, file := range written {
+		update.AddFile(file)
+	}
+	for _, location := range removed {
+		update.DeleteFile(location)
+	}
+
+	commit, created, err := update.Stage()
+	if err != nil {
+		removeDataFiles(fileIO, written)
+		return err
+	}
+	if _, err := client.CommitTable(ctx, ns, tableName, commit); err != nil {
+		return commitFailure(fileIO, written, iceberg.IsCommitRejected(err), err)
+	}
+
+	fmt.Printf("✅ Committed %s snapshot %d to branch %s\n", created.Summary["operation"], created.SnapshotID, *branch)
+	return nil
+}
@@ -0,0 +1,90 @@
+package warehouse
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"the-modern-data-stack/internal/dictionary"
+	"the-modern-data-stack/internal/iceberg"
+)
+
+// applyColumnDocs brings the column docs of a table in line with the data dictionary.
+// Docs change through a new schema, so the table keeps its columns and field IDs.
+// Columns the dictionary does not document keep their doc.
+func applyColumnDocs(ctx context.Context, out io.Writer, client *iceberg.Client, namespace, tableName string, entries []dictionary.Entry) error {
+	table, err := client.LoadTable(ctx, namespace, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &table.Metadata
+	current := metadata.CurrentSchema()
+	if current == nil {
+		return fmt.Errorf("table metadata has no current schema")
+	}
+
+	schema := *current
+	schema.Fields = append([]iceberg.Field(nil), current.Fields...)
+	var lines []string
+	for _, entry := range entries {
+		field := schema.FieldByName(entry.Column)
+		if field == nil {
+			fmt.Fprintf(out, "   ⚠️  Data dictionary documents %s.%s, which the table does not have\n", tableName, entry.Column)
+			continue
+		}
+		doc := entry.Doc()
+		switch {
+		case field.Doc == doc:
+			continue
+		case field.Doc == "":
+			lines = append(lines, fmt.Sprintf("+ %s: %q", field.Name, doc))
+		default:
+			lines = append(lines, fmt.Sprintf("~ %s: %q → %q", field.Name, field.Doc, doc))
+		}
+		field.Doc = doc
+	}
+
+	if len(lines) == 0 {
+		fmt.Fprintf(out, "   ✅ Table '%s.%s': column docs up to date\n", namespace, tableName)
+		return nil
+	}
+	fmt.Fprintf(out, "   📝 Table '%s.%s':\n", namespace, tableName)
+	for _, line := range lines {
+		fmt.Fprintf(out, "      %s\n", line)
+	}
+
+	for _, s := range metadata.Schemas {
+		if s.SchemaID >= schema.SchemaID {
+			schema.SchemaID = s.SchemaID + 1
+		}
+	}
+	commit := &iceberg.TableCommit{
+		Requirements: []iceberg.TableRequirement{
+			iceberg.AssertTableUUID(metadata.TableUUID),
+			iceberg.AssertCurrentSchemaID(current.SchemaID),
+		},
+		Updates: []iceberg.TableUpdate{
+			iceberg.AddSchema(&schema, metadata.LastColumnID),
+			iceberg.SetCurrentSchema(-1),
+		},
+	}
+	_, err = client.CommitTable(ctx, namespace, tableName, commit)
+	return err
+}
+
+// ApplyDataDictionary updates the column docs of every table of the namespace the
+// data dictionary documents
+func ApplyDataDictionary(ctx context.Context, out io.Writer, client *iceberg.Client, namespace string, dict *dictionary.Dictionary) int {
+	failed := 0
+	for _, tableName := range dict.Tables() {
+		if err := applyColumnDocs(ctx, out, client, namespace, tableName, dict.Table(tableName)); err != nil {
+			if iceberg.IsNotFound(err) {
+				fmt.Fprintf(out, "   ⚠️  Table '%s.%s' is documented but does not exist, skipping...\n", namespace, tableName)
+				continue
+			}
+			fmt.Fprintf(out, "   ❌ Table '%s.%s': %v\n", namespace, tableName, err)
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,560 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/files"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/lineage"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// queryColumns returns the column names of a query result
+func queryColumns(ctx context.Context, db *sql.DB, query string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, "DESCRIBE "+query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name, typ string
+		var null, key, defaultVal, extra sql.NullString
+		if err := rows.Scan(&name, &typ, &null, &key, &defaultVal, &extra); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
+
+// parquetColumns returns the column names of a Parquet file
+func parquetColumns(ctx context.Context, db *sql.DB, path string) (map[string]bool, error) {
+	columns, err := queryColumns(ctx, db, fmt.Sprintf("SELECT * FROM read_parquet(%s%s)", sqlutil.QuoteString(path), sourceOptions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe %s: %w", path, err)
+	}
+	return columns, nil
+}
+
+// writeParquetFile copies a query result into a new Parquet file of the table, with
+// the Iceberg field IDs of the given columns embedded so that engines can resolve them
+func writeParquetFile(ctx context.Context, db *sql.DB, fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, selectSQL string, fields []iceberg.Field) (iceberg.DataFile, error) {
+	var fieldIDs []string
+	for _, field := range fields {
+		fieldIDs = append(fieldIDs, fmt.Sprintf("%s: %d", sqlutil.QuoteIdentifier(field.Name), field.ID))
+	}
+
+	location := iceberg.NewDataFileLocation(metadata)
+	localPath := fileIO.LocalPath(location)
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return iceberg.DataFile{}, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	var rowCount int64
+	err := files.WriteAtomic(localPath, func(tempPath string) error {
+		copySQL := fmt.Sprintf("COPY (%s) TO %s (FORMAT 'parquet', FIELD_IDS {%s})",
+			selectSQL, sqlutil.QuoteString(tempPath), strings.Join(fieldIDs, ", "))
+		result, err := db.ExecContext(ctx, copySQL)
+		if err != nil {
+			return fmt.Errorf("failed to write data file: %w", err)
+		}
+		if rowCount, err = result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to get written row count: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return iceberg.DataFile{}, err
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return iceberg.DataFile{}, err
+	}
+
+	return iceberg.DataFile{
+		Content:         iceberg.FileContentData,
+		FilePath:        location,
+		FileFormat:      "PARQUET",
+		Partition:       map[string]interface{}{},
+		RecordCount:     rowCount,
+		FileSizeInBytes: info.Size(),
+	}, nil
+}
+
+// writeDataFile copies a query returning the columns of the table schema into a new data file
+func writeDataFile(ctx context.Context, db *sql.DB, fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, selectSQL string) (iceberg.DataFile, error) {
+	schema := metadata.CurrentSchema()
+	if schema == nil {
+		return iceberg.DataFile{}, fmt.Errorf("table metadata has no current schema")
+	}
+	return writeParquetFile(ctx, db, fileIO, metadata, selectSQL, schema.Fields)
+}
+
+// schemaProjection returns the select expressions turning a relation with the given
+// columns into the columns and types of the table schema. Matched columns are removed
+// from the map; missing optional columns are filled with NULLs.
+func schemaProjection(schema *iceberg.Schema, columns map[string]bool, source string) ([]string, error) {
+	var selects []string
+	for _, field := range schema.Fields {
+		typ, err := duckDBType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", field.Name, err)
+		}
+
+		if columns[field.Name] {
+			selects = append(selects, fmt.Sprintf("CAST(%s AS %s) AS %s", sqlutil.QuoteIdentifier(field.Name), typ, sqlutil.QuoteIdentifier(field.Name)))
+			delete(columns, field.Name)
+		} else if field.Required {
+			return nil, fmt.Errorf("%s has no value for required column %s", source, field.Name)
+		} else {
+			selects = append(selects, fmt.Sprintf("CAST(NULL AS %s) AS %s", typ, sqlutil.QuoteIdentifier(field.Name)))
+		}
+	}
+	return selects, nil
+}
+
+// selectForSchema builds a query reading a Parquet file with the columns and types of the
+// table schema. Missing optional columns are filled with NULLs.
+func selectForSchema(ctx context.Context, db *sql.DB, schema *iceberg.Schema, sourcePath string) (string, error) {
+	columns, err := parquetColumns(ctx, db, sourcePath)
+	if err != nil {
+		return "", err
+	}
+
+	selects, err := schemaProjection(schema, columns, sourcePath)
+	if err != nil {
+		return "", err
+	}
+
+	for column := range columns {
+		fmt.Printf("⚠️  Column '%s' of %s is not in the table schema and is ignored\n", column, sourcePath)
+	}
+
+	return fmt.Sprintf("SELECT %s FROM read_parquet(%s%s)", strings.Join(selects, ", "), sqlutil.QuoteString(sourcePath), sourceOptions), nil
+}
+
+// removeDataFiles deletes data files written for a commit that did not go through
+func removeDataFiles(fileIO *iceberg.FileIO, files []iceberg.DataFile) {
+	for _, file := range files {
+		os.Remove(fileIO.LocalPath(file.FilePath))
+	}
+}
+
+// errCommitUnknown is wrapped by the errors of commits that may have been applied
+var errCommitUnknown = errors.New("the catalog failed without rejecting the commit, which may have been applied: its files are kept, check the table history before committing again")
+
+// commitFailure returns the error of a failed commit. The files written for it are removed
+// when the catalog rejected it; after any other failure, such as a 500 or a timeout, the
+// table may reference them, so they are kept.
+func commitFailure(fileIO *iceberg.FileIO, files []iceberg.DataFile, rejected bool, err error) error {
+	if !rejected {
+		return fmt.Errorf("%w (%w)", err, errCommitUnknown)
+	}
+	removeDataFiles(fileIO, files)
+	if iceberg.IsCommitConflict(err) {
+		return fmt.Errorf("%w (%s)", err, conflictHint)
+	}
+	return err
+}
+
+// Load modes: append adds the rows, merge replaces the rows with the same identifier fields,
+// overwrite replaces all rows and overwrite-partitions replaces the partitions present in
+// the loaded data
+const (
+	loadModeAppend              = "append"
+	loadModeMerge               = "merge"
+	loadModeOverwrite           = "overwrite"
+	loadModeOverwritePartitions = "overwrite-partitions"
+)
+
+// stagedLoad is a load whose files are written and whose snapshot is staged, ready to commit
+type stagedLoad struct {
+	namespace   string
+	table       string
+	branch      string
+	mode        string
+	dataFiles   []iceberg.DataFile
+	deleteFiles []iceberg.DataFile
+	lineage     map[string]string // lineage entries of the source files, for the snapshot summary
+	commit      *iceberg.TableCommit
+	snapshot    *iceberg.Snapshot
+}
+
+// files returns all files written by the load
+func (l *stagedLoad) files() []iceberg.DataFile {
+	return append(append([]iceberg.DataFile{}, l.dataFiles...), l.deleteFiles...)
+}
+
+// stageLoad writes the source files as new data files of the table and stages a snapshot
+// on the branch. In merge mode, equality delete files remove the previous rows of the
+// loaded keys; in overwrite mode, all files of the branch are removed, and in
+// overwrite-partitions mode only the files of the loaded partitions. Written files are
+// removed again if staging fails. The lineage metadata convert wrote into the source files
+// goes into the summary of the snapshot.
+func stageLoad(ctx context.Context, db *sql.DB, fileIO *iceberg.FileIO, ns, tableName string, metadata *iceberg.TableMetadata, branch, mode string, sources []string) (*stagedLoad, error) {
+	var keys []iceberg.Field
+	switch mode {
+	case loadModeAppend, loadModeOverwrite, loadModeOverwritePartitions:
+	case loadModeMerge:
+		var err error
+		if keys, err = identifierFields(metadata.CurrentSchema()); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", ns, tableName, err)
+		}
+		if err := checkPartitionKeys(metadata.CurrentSchema(), metadata.DefaultSpec(), metadata.CurrentSchema().IdentifierFieldIDs); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", ns, tableName, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown load mode %q, expected %s, %s, %s or %s", mode, loadModeAppend, loadModeMerge, loadModeOverwrite, loadModeOverwritePartitions)
+	}
+
+	load := &stagedLoad{namespace: ns, table: tableName, branch: branch, mode: mode}
+	fail := func(err error) (*stagedLoad, error) {
+		removeDataFiles(fileIO, load.files())
+		return nil, err
+	}
+
+	for _, source := range sources {
+		selectSQL, err := selectForSchema(ctx, db, metadata.CurrentSchema(), source)
+		if err != nil {
+			return fail(err)
+		}
+
+		files, err := writePartitionedFiles(ctx, db, fileIO, metadata, selectSQL)
+		if err != nil {
+			return fail(fmt.Errorf("failed to load %s: %w", source, err))
+		}
+		load.dataFiles = append(load.dataFiles, files...)
+
+		var rows int64
+		for _, file := range files {
+			rows += file.RecordCount
+		}
+		if len(metadata.DefaultSpec().Fields) > 0 {
+			fmt.Printf("   - %s: %d rows in %d partition(s)\n", source, rows, len(files))
+		} else {
+			fmt.Printf("   - %s: %d rows\n", source, rows)
+		}
+	}
+
+	entries, err := lineage.Read(ctx, db, parquetList(sources))
+	if err != nil {
+		return fail(err)
+	}
+	load.lineage = entries
+
+	if mode == loadModeMerge {
+		var paths []string
+		for _, file := range load.dataFiles {
+			paths = append(paths, fileIO.LocalPath(file.FilePath))
+		}
+		if err := checkUniqueKeys(ctx, db, paths, keys); err != nil {
+			return fail(err)
+		}
+
+		for _, file := range load.dataFiles {
+			deletes, err := writeEqualityDeleteFile(ctx, db, fileIO, metadata, file, keys)
+			if err != nil {
+				return fail(fmt.Errorf("failed to merge %s: %w", file.FilePath, err))
+			}
+			load.deleteFiles = append(load.deleteFiles, deletes)
+		}
+	}
+
+	if err := load.stage(fileIO, metadata); err != nil {
+		return fail(err)
+	}
+	return load, nil
+}
+
+// stage stages the snapshot of the written files against the metadata of the table. It
+// runs again against the refreshed metadata when the commit hit a conflict, so that the
+// files replaced in overwrite modes are those of the new parent snapshot.
+func (l *stagedLoad) stage(fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata) error {
+	operation := iceberg.OperationOverwrite
+	if l.mode == loadModeAppend {
+		operation = iceberg.OperationAppend
+	}
+
+	update := fileIO.NewSnapshotUpdate(metadata, l.branch, operation)
+	for _, file := range l.files() {
+		update.AddFile(file)
+	}
+	for key, value := range l.lineage {
+		update.SetSummaryProperty(key, value)
+	}
+
+	switch l.mode {
+	case loadModeOverwrite:
+		if parent := update.Parent(); parent != nil {
+			replaced, err := fileIO.ReadSnapshotEntries(parent)
+			if err != nil {
+				return err
+			}
+			for _, entry := range replaced {
+				update.DeleteFile(entry.DataFile.FilePath)
+			}
+			fmt.Printf("   - replacing %d existing file(s)\n", len(replaced))
+		}
+
+	case loadModeOverwritePartitions:
+		replaced, err := replacedFiles(fileIO, metadata, update.Parent(), l.dataFiles)
+		if err != nil {
+			return err
+		}
+		for _, entry := range replaced {
+			update.DeleteFile(entry.DataFile.FilePath)
+		}
+		update.SetSummaryProperty("replace-partitions", "true")
+		fmt.Printf("   - replacing %d existing file(s)\n", len(replaced))
+	}
+
+	commit, snapshot, err := update.Stage()
+	if err != nil {
+		return err
+	}
+	l.commit = commit
+	l.snapshot = snapshot
+	return nil
+}
+
+// restage reloads the table of a load and stages its snapshot again against it
+func (l *stagedLoad) restage(ctx context.Context, client *iceberg.Client, fileIO *iceberg.FileIO) error {
+	table, err := client.LoadTable(ctx, l.namespace, l.table)
+	if err != nil {
+		return err
+	}
+	return l.stage(fileIO, &table.Metadata)
+}
+
+// replacedFiles returns the live files of a snapshot that belong to the partitions of the
+// new data files. Files written with another partition spec are kept.
+func replacedFiles(fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, snapshot *iceberg.Snapshot, newFiles []iceberg.DataFile) ([]iceberg.ManifestEntry, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+
+	spec := metadata.DefaultSpec()
+	partitions := make(map[string]bool)
+	for _, file := range newFiles {
+		partitions[partitionKey(spec, file.Partition)] = true
+	}
+
+	entries, err := fileIO.ReadSnapshotEntries(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	var replaced []iceberg.ManifestEntry
+	for _, entry := range entries {
+		if int(entry.PartitionSpecID) == spec.SpecID && partitions[partitionKey(spec, entry.DataFile.Partition)] {
+			replaced = append(replaced, entry)
+		}
+	}
+	return replaced, nil
+}
+
+// commitLoad commits a staged load on its own, staging it again against the refreshed
+// table after a commit conflict. Its data files are removed if the catalog rejects the
+// commit, and kept if it fails in a way that leaves the outcome unknown.
+func commitLoad(ctx context.Context, client *iceberg.Client, fileIO *iceberg.FileIO, load *stagedLoad) error {
+	rejected := true // whether the catalog rejected every commit it was sent
+	err := client.RetryCommit(ctx, func(refresh bool) error {
+		if refresh {
+			if err := load.restage(ctx, client, fileIO); err != nil {
+				return err
+			}
+		}
+		_, err := client.CommitTable(ctx, load.namespace, load.table, load.commit)
+		rejected = err == nil || iceberg.IsCommitRejected(err)
+		return err
+	})
+	if err != nil {
+		return commitFailure(fileIO, load.files(), rejected, err)
+	}
+	return nil
+}
+
+func runLoad(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("load")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	branch := fs.String("branch", "main", "Branch to load the data into; a missing branch is created from main")
+	mode := fs.String("mode", loadModeAppend, "Load mode: append, merge to replace the rows with the same identifier fields, overwrite to replace all rows, or overwrite-partitions to replace the partitions present in the data")
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) < 2 {
+		return cli.Usagef("expected a table and at least one Parquet file")
+	}
+	ns, tableName := parseTableIdentifier(positional[0], *namespace)
+	sources := positional[1:]
+
+	client := opts.client()
+	fileIO := opts.fileIO()
+
+	table, err := client.LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+
+	db, err := logging.OpenDB("duckdb", "")
+	if err != nil {
+		return fmt.Errorf("failed to open DuckDB: %w", err)
+	}
+	defer db.Close()
+
+	fmt.Printf("📥 Loading %d file(s) into branch %s of '%s.%s'...\n", len(sources), *branch, ns, tableName)
+
+	load, err := stageLoad(ctx, db, fileIO, ns, tableName, &table.Metadata, *branch, *mode, sources)
+	if err != nil {
+		return err
+	}
+	if err := commitLoad(ctx, client, fileIO, load); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Committed snapshot %d to branch %s\n", load.snapshot.SnapshotID, *branch)
+	if *branch != "main" {
+		fmt.Printf("💡 Validate with 'mds query %s --as-of %s', then publish with 'mds maintain fast-forward %s --branch %s'\n", tableName, *branch, tableName, *branch)
+	}
+	return nil
+}
+
+// commitTransaction commits all staged loads in one multi-table transaction, staging them
+// again against the refreshed tables after a commit conflict. It reports false without
+// committing anything when the catalog has no transactions endpoint. As with commitLoad,
+// the files are only removed when the catalog rejects the transaction.
+func commitTransaction(ctx context.Context, client *iceberg.Client, fileIO *iceberg.FileIO, loads []*stagedLoad) (bool, error) {
+	rejected := true
+	err := client.RetryCommit(ctx, func(refresh bool) error {
+		var changes []iceberg.TableChange
+		for _, load := range loads {
+			if refresh {
+				if err := load.restage(ctx, client, fileIO); err != nil {
+					return err
+				}
+			}
+			changes = append(changes, iceberg.NewTableChange(load.namespace, load.table, load.commit))
+		}
+		err := client.CommitTransaction(ctx, changes)
+		rejected = err == nil || iceberg.IsCommitRejected(err)
+		return err
+	})
+	if err != nil && iceberg.IsUnsupportedEndpoint(err) {
+		return false, nil
+	}
+	if err != nil {
+		var files []iceberg.DataFile
+		for _, load := range loads {
+			files = append(files, load.files()...)
+		}
+		return true, commitFailure(fileIO, files, rejected, err)
+	}
+	return true, nil
+}
+
+func runLoadAll(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("load-all")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the tables")
+	branch := fs.String("branch", "main", "Branch to load the data into; a missing branch is created from main")
+	mode := fs.String("mode", loadModeAppend, "Load mode: append, merge to replace the rows with the same identifier fields, overwrite to replace all rows, or overwrite-partitions to replace the partitions present in the data")
+	atomic := fs.Bool("atomic", false, "Commit all tables in a single transaction, so that either all of them or none are updated")
+	positional := parseInterspersed(fs, args)
+
+	parquetDir := "data/parquet"
+	if len(positional) > 1 {
+		return cli.Usagef("expected at most one directory")
+	} else if len(positional) == 1 {
+		parquetDir = positional[0]
+	}
+
+	parquetTables, err := files.FindParquetTables(parquetDir)
+	if err != nil {
+		return fmt.Errorf("failed to search for Parquet files: %w", err)
+	}
+	if len(parquetTables) == 0 {
+		fmt.Printf("⚠️  No Parquet files found in '%s' directory\n", parquetDir)
+		return nil
+	}
+
+	client := opts.client()
+	fileIO := opts.fileIO()
+
+	db, err := logging.OpenDB("duckdb", "")
+	if err != nil {
+		return fmt.Errorf("failed to open DuckDB: %w", err)
+	}
+	defer db.Close()
+
+	fmt.Printf("📥 Loading %d Parquet table(s) into namespace '%s' (branch %s)...\n", len(parquetTables), *namespace, *branch)
+
+	var loads []*stagedLoad
+	var failed []string
+	for _, parquetTable := range parquetTables {
+		tableName := parquetTable.Name
+		fmt.Printf("\n🔄 Staging '%s.%s'...\n", *namespace, tableName)
+
+		table, err := client.LoadTable(ctx, *namespace, tableName)
+		if err == nil {
+			var load *stagedLoad
+			load, err = stageLoad(ctx, db, fileIO, *namespace, tableName, &table.Metadata, *branch, *mode, parquetTable.Files)
+			if err == nil {
+				loads = append(loads, load)
+				continue
+			}
+		}
+
+		if *atomic {
+			for _, load := range loads {
+				removeDataFiles(fileIO, load.files())
+			}
+			return fmt.Errorf("no table was updated, staging %s.%s failed: %w", *namespace, tableName, err)
+		}
+		fmt.Printf("❌ %v\n", err)
+		failed = append(failed, tableName)
+	}
+
+	if *atomic && len(loads) > 0 {
+		fmt.Printf("\n🔒 Committing %d table(s) in a single transaction...\n", len(loads))
+		supported, err := commitTransaction(ctx, client, fileIO, loads)
+		if errors.Is(err, errCommitUnknown) {
+			return fmt.Errorf("all or none of the tables were updated: %w", err)
+		}
+		if err != nil {
+			return fmt.Errorf("no table was updated: %w", err)
+		}
+		if supported {
+			fmt.Printf("✅ Committed %d table(s) atomically\n", len(loads))
+			return nil
+		}
+		fmt.Println("⚠️  The catalog does not support multi-table transactions (POST /v1/transactions/commit)")
+		fmt.Println("⚠️  Falling back to one commit per table: a failure midway leaves the tables inconsistent")
+	}
+
+	fmt.Println()
+	for _, load := range loads {
+		if err := commitLoad(ctx, client, fileIO, load); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			failed = append(failed, load.table)
+			continue
+		}
+		fmt.Printf("✅ Committed snapshot %d to '%s.%s'\n", load.snapshot.SnapshotID, load.namespace, load.table)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to load %d table(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
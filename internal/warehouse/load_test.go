@@ -0,0 +1,117 @@
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"the-modern-data-stack/internal/iceberg"
+)
+
+// fakeCatalog serves the sales table of regionTable and answers its commits with a status,
+// after applying them when applies is set
+type fakeCatalog struct {
+	status  int
+	applies bool
+
+	mu      sync.Mutex
+	commits int
+	applied int
+}
+
+func (c *fakeCatalog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/namespaces/db/tables/sales":
+		json.NewEncoder(w).Encode(iceberg.LoadTableResult{MetadataLocation: "file:/warehouse/db/sales/metadata/v1.metadata.json", Metadata: *regionTable(1)})
+	case r.Method == http.MethodPost && (r.URL.Path == "/v1/namespaces/db/tables/sales" || r.URL.Path == "/v1/transactions/commit"):
+		c.commits++
+		if c.applies {
+			c.applied++
+		}
+		w.WriteHeader(c.status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{
+			"message": http.StatusText(c.status), "type": "TestException", "code": c.status,
+		}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// TestCommitFailures checks that the files of a failed commit are only removed when the
+// catalog certainly did not apply it
+func TestCommitFailures(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	dir := t.TempDir()
+	source := writeParquet(t, db, dir, "sales.parquet", "SELECT 1::BIGINT AS id, 'a' AS region, 10.0 AS amount")
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name        string
+		catalog     *fakeCatalog // nil for a catalog that cannot be reached
+		transaction bool
+		commits     int  // commits the catalog receives
+		kept        bool // whether the files are kept
+	}{
+		{"500 after applying the commit", &fakeCatalog{status: http.StatusInternalServerError, applies: true}, false, 1, true},
+		{"504", &fakeCatalog{status: http.StatusGatewayTimeout}, false, 1, true},
+		{"500 after applying the transaction", &fakeCatalog{status: http.StatusInternalServerError, applies: true}, true, 1, true},
+		{"conflicts", &fakeCatalog{status: http.StatusConflict}, false, 2, false},
+		{"conflicting transaction", &fakeCatalog{status: http.StatusConflict}, true, 2, false},
+		{"invalid commit", &fakeCatalog{status: http.StatusBadRequest}, false, 1, false},
+		{"unreachable catalog", nil, false, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url := closed.URL
+			if test.catalog != nil {
+				server := httptest.NewServer(test.catalog)
+				defer server.Close()
+				url = server.URL
+			}
+			client := iceberg.NewClient(url)
+			client.Retry = iceberg.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+			fileIO := iceberg.NewFileIO(filepath.Join(t.TempDir(), "warehouse"), "file:/warehouse")
+
+			load, err := stageLoad(ctx, db, fileIO, "db", "sales", regionTable(1), "main", loadModeAppend, []string{source})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.transaction {
+				_, err = commitTransaction(ctx, client, fileIO, []*stagedLoad{load})
+			} else {
+				err = commitLoad(ctx, client, fileIO, load)
+			}
+			if err == nil {
+				t.Fatal("the commit succeeded")
+			}
+
+			if unknown := errors.Is(err, errCommitUnknown); unknown != test.kept {
+				t.Errorf("error %v, want an unknown outcome: %v", err, test.kept)
+			}
+			if test.catalog != nil && test.catalog.commits != test.commits {
+				t.Errorf("the catalog received %d commit(s), want %d", test.catalog.commits, test.commits)
+			}
+			if test.catalog != nil && test.catalog.applies && test.catalog.applied != 1 {
+				t.Errorf("the catalog applied %d commit(s), want 1", test.catalog.applied)
+			}
+			for _, file := range load.files() {
+				_, statErr := os.Stat(fileIO.LocalPath(file.FilePath))
+				if exists := statErr == nil; exists != test.kept {
+					t.Errorf("data file %s exists: %v, want %v", file.FilePath, exists, test.kept)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// identifierFields returns the identifier fields of a schema, which merge loads match rows on
+func identifierFields(schema *iceberg.Schema) ([]iceberg.Field, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("table metadata has no current schema")
+	}
+	if len(schema.IdentifierFieldIDs) == 0 {
+		return nil, fmt.Errorf("the table has no identifier fields, declare them with 'mds tables set-identifier-fields' first")
+	}
+
+	var fields []iceberg.Field
+	for _, id := range schema.IdentifierFieldIDs {
+		field := schema.FieldByID(id)
+		if field == nil {
+			return nil, fmt.Errorf("identifier field %d is not a top-level column", id)
+		}
+		fields = append(fields, *field)
+	}
+	return fields, nil
+}
+
+// keyColumns returns the quoted column names of fields, separated by commas
+func keyColumns(fields []iceberg.Field) string {
+	var columns []string
+	for _, field := range fields {
+		columns = append(columns, sqlutil.QuoteIdentifier(field.Name))
+	}
+	return strings.Join(columns, ", ")
+}
+
+// checkUniqueKeys fails if several rows of the Parquet files share the same key
+func checkUniqueKeys(ctx context.Context, db *sql.DB, paths []string, keys []iceberg.Field) error {
+	query := fmt.Sprintf("SELECT count(*) FROM (SELECT %s FROM read_parquet(%s) GROUP BY ALL HAVING count(*) > 1)",
+		keyColumns(keys), parquetList(paths))
+
+	var duplicates int64
+	if err := db.QueryRowContext(ctx, query).Scan(&duplicates); err != nil {
+		return fmt.Errorf("failed to check for duplicate keys: %v", err)
+	}
+	if duplicates > 0 {
+		return fmt.Errorf("%d key(s) of (%s) appear more than once in the loaded data", duplicates, keyColumns(keys))
+	}
+	return nil
+}
+
+// checkPartitionKeys fails unless the source columns of every partition field are among
+// the identifier fields. The equality deletes of a merge go into the partition of the data
+// file they are written for, and only apply to data files of that partition: a row whose
+// key stayed the same but whose partition changed would keep its old copy.
+func checkPartitionKeys(schema *iceberg.Schema, spec *iceberg.PartitionSpec, keyIDs []int) error {
+	keys := make(map[int]bool)
+	for _, id := range keyIDs {
+		keys[id] = true
+	}
+
+	var missing []string
+	seen := make(map[int]bool)
+	for _, field := range spec.Fields {
+		if keys[field.SourceID] || seen[field.SourceID] || field.Transform == "void" {
+			continue
+		}
+		seen[field.SourceID] = true
+		name := fmt.Sprintf("field %d", field.SourceID)
+		if source := schema.FieldByID(field.SourceID); source != nil {
+			name = source.Name
+		}
+		missing = append(missing, name)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the identifier fields must include the partition column(s) %s, as merge loads only replace rows within a partition", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// writeEqualityDeleteFile writes the keys of a new data file as an equality delete file
+// in the partition of the data file. Equality deletes only apply to data files with a
+// lower sequence number, so the rows of the data file committed alongside it stay live
+// while older rows with the same keys are removed.
+func writeEqualityDeleteFile(ctx context.Context, db *sql.DB, fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, dataFile iceberg.DataFile, keys []iceberg.Field) (iceberg.DataFile, error) {
+	selectSQL := fmt.Sprintf("SELECT DISTINCT %s FROM read_parquet(%s)",
+		keyColumns(keys), sqlutil.QuoteString(fileIO.LocalPath(dataFile.FilePath)))
+
+	deletes, err := writeParquetFile(ctx, db, fileIO, metadata, selectSQL, keys)
+	if err != nil {
+		return iceberg.DataFile{}, err
+	}
+
+	deletes.Content = iceberg.FileContentEqualityDeletes
+	deletes.Partition = dataFile.Partition
+	for _, key := range keys {
+		deletes.EqualityIDs = append(deletes.EqualityIDs, key.ID)
+	}
+	return deletes, nil
+}
+
+func runSetIdentifierFields(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("set-identifier-fields")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) < 2 {
+		return cli.Usagef("expected a table and at least one column")
+	}
+	ns, tableName := parseTableIdentifier(positional[0], *namespace)
+	columns := positional[1:]
+
+	client := opts.client()
+	fileIO := opts.fileIO()
+
+	table, err := client.LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &table.Metadata
+
+	current := metadata.CurrentSchema()
+	if current == nil {
+		return fmt.Errorf("table metadata has no current schema")
+	}
+
+	schema := *current
+	schema.Fields = append([]iceberg.Field(nil), current.Fields...)
+	schema.IdentifierFieldIDs = nil
+	for _, s := range metadata.Schemas {
+		if s.SchemaID >= schema.SchemaID {
+			schema.SchemaID = s.SchemaID + 1
+		}
+	}
+
+	var optional []iceberg.Field
+	for _, column := range columns {
+		field := schema.FieldByName(column)
+		if field == nil {
+			return fmt.Errorf("%s.%s has no column %s", ns, tableName, column)
+		}
+		switch field.Type {
+		case "float", "double":
+			return fmt.Errorf("column %s: %v columns cannot be identifier fields", column, field.Type)
+		}
+		if _, err := duckDBType(field.Type); err != nil {
+			return fmt.Errorf("column %s cannot be an identifier field: %v", column, err)
+		}
+
+		// Identifier fields must be required
+		if !field.Required {
+			optional = append(optional, *field)
+			field.Required = true
+		}
+		schema.IdentifierFieldIDs = append(schema.IdentifierFieldIDs, field.ID)
+	}
+
+	if err := checkPartitionKeys(&schema, metadata.DefaultSpec(), schema.IdentifierFieldIDs); err != nil {
+		return err
+	}
+
+	// Making a column required is only safe if the table holds no NULLs in it
+	if snapshot := metadata.CurrentSnapshot(); snapshot != nil && len(optional) > 0 {
+		db, err := logging.OpenDB("duckdb", "")
+		if err != nil {
+			return fmt.Errorf("failed to open DuckDB: %v", err)
+		}
+		defer db.Close()
+
+		if err := createSnapshotView(ctx, db, fileIO, metadata, tableName, snapshot); err != nil {
+			return err
+		}
+		for _, field := range optional {
+			var nulls int64
+			query := fmt.Sprintf("SELECT count(*) FROM %s WHERE %s IS NULL", sqlutil.QuoteIdentifier(tableName), sqlutil.QuoteIdentifier(field.Name))
+			if err := db.QueryRowContext(ctx, query).Scan(&nulls); err != nil {
+				return fmt.Errorf("failed to check column %s for NULLs: %v", field.Name, err)
+			}
+			if nulls > 0 {
+				return fmt.Errorf("column %s has %d NULL value(s) and cannot be an identifier field", field.Name, nulls)
+			}
+		}
+	}
+
+	commit := &iceberg.TableCommit{
+		Requirements: []iceberg.TableRequirement{
+			iceberg.AssertTableUUID(metadata.TableUUID),
+			iceberg.AssertCurrentSchemaID(current.SchemaID),
+		},
+		Updates: []iceberg.TableUpdate{
+			iceberg.AddSchema(&schema, metadata.LastColumnID),
+			iceberg.SetCurrentSchema(-1),
+		},
+	}
+	if _, err := client.CommitTable(ctx, ns, tableName, commit); err != nil {
+		if iceberg.IsCommitConflict(err) {
+			return fmt.Errorf("%w (%s)", err, conflictHint)
+		}
+		return err
+	}
+
+	fmt.Printf("✅ Identifier fields of '%s.%s' set to %s (schema %d)\n", ns, tableName, strings.Join(columns, ", "), schema.SchemaID)
+	for _, field := range optional {
+		fmt.Printf("   - %s is now required\n", field.Name)
+	}
+	fmt.Printf("💡 Re-load corrected rows with 'mds load %s --mode merge <file.parquet>'\n", tableName)
+	return nil
+}
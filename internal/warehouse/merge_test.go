@@ -0,0 +1,172 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := logging.OpenDB("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// writeParquet writes the rows of a query to a Parquet file of dir
+func writeParquet(t *testing.T, db *sql.DB, dir, name, query string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if _, err := db.Exec("COPY (" + query + ") TO " + sqlutil.QuoteString(path) + " (FORMAT 'parquet')"); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// regionTable returns the metadata of a table partitioned by region, with the given
+// identifier fields
+func regionTable(keyIDs ...int) *iceberg.TableMetadata {
+	return &iceberg.TableMetadata{
+		FormatVersion:   2,
+		TableUUID:       "6f1b2a43-5c3e-4d2a-9b8e-0c7d6e5f4a3b",
+		Location:        "file:/warehouse/db/sales",
+		LastColumnID:    3,
+		CurrentSchemaID: 0,
+		Schemas: []iceberg.Schema{{
+			Type:     "struct",
+			SchemaID: 0,
+			Fields: []iceberg.Field{
+				{ID: 1, Name: "id", Type: "long", Required: true},
+				{ID: 2, Name: "region", Type: "string", Required: true},
+				{ID: 3, Name: "amount", Type: "double"},
+			},
+			IdentifierFieldIDs: keyIDs,
+		}},
+		PartitionSpecs: []iceberg.PartitionSpec{{SpecID: 0, Fields: []iceberg.PartitionField{
+			{SourceID: 2, FieldID: 1000, Name: "region", Transform: "identity"},
+		}}},
+		LastPartitionID: 1000,
+	}
+}
+
+// applyLoad makes the staged snapshot of a load the current snapshot of the metadata, as
+// the catalog does when it accepts the commit
+func applyLoad(metadata *iceberg.TableMetadata, load *stagedLoad) {
+	snapshot := *load.snapshot
+	metadata.Snapshots = append(metadata.Snapshots, snapshot)
+	metadata.CurrentSnapshotID = &snapshot.SnapshotID
+	metadata.LastSequenceNumber = snapshot.SequenceNumber
+	metadata.Refs = map[string]iceberg.SnapshotRef{"main": {SnapshotID: snapshot.SnapshotID, Type: "branch"}}
+}
+
+// countFiles returns the number of files under a directory
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			count++
+		}
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestCheckPartitionKeys(t *testing.T) {
+	schema := &iceberg.Schema{Fields: []iceberg.Field{
+		{ID: 1, Name: "id", Type: "long"},
+		{ID: 2, Name: "region", Type: "string"},
+		{ID: 3, Name: "sold_at", Type: "timestamp"},
+	}}
+	tests := []struct {
+		name    string
+		fields  []iceberg.PartitionField
+		keys    []int
+		missing string
+	}{
+		{"unpartitioned", nil, []int{1}, ""},
+		{"identity on a key", []iceberg.PartitionField{{SourceID: 2, Transform: "identity"}}, []int{1, 2}, ""},
+		{"transform of a key", []iceberg.PartitionField{{SourceID: 3, Transform: "month"}}, []int{3, 1}, ""},
+		{"void", []iceberg.PartitionField{{SourceID: 2, Transform: "void"}}, []int{1}, ""},
+		{"identity on another column", []iceberg.PartitionField{{SourceID: 2, Transform: "identity"}}, []int{1}, "region"},
+		{"two transforms of a column", []iceberg.PartitionField{{SourceID: 3, Transform: "year"}, {SourceID: 3, Transform: "day"}}, []int{1}, "sold_at"},
+		{"several columns", []iceberg.PartitionField{{SourceID: 2, Transform: "identity"}, {SourceID: 3, Transform: "day"}}, []int{2}, "sold_at"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkPartitionKeys(schema, &iceberg.PartitionSpec{Fields: test.fields}, test.keys)
+			if test.missing == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "column(s) "+test.missing+",") {
+				t.Errorf("error %v, want one naming only %s", err, test.missing)
+			}
+		})
+	}
+}
+
+// TestMergeMovingKeyToAnotherPartition loads a row into partition a, then merges the same
+// key with partition b. The equality delete of the merge would only apply to partition b
+// and leave the old row in a, so merge is refused unless region is part of the key.
+func TestMergeMovingKeyToAnotherPartition(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	dir := t.TempDir()
+	fileIO := iceberg.NewFileIO(filepath.Join(dir, "warehouse"), "file:/warehouse")
+
+	first := writeParquet(t, db, dir, "first.parquet", "SELECT 1::BIGINT AS id, 'a' AS region, 10.0 AS amount")
+	moved := writeParquet(t, db, dir, "moved.parquet", "SELECT 1::BIGINT AS id, 'b' AS region, 12.5 AS amount")
+
+	t.Run("key without the partition column", func(t *testing.T) {
+		metadata := regionTable(1)
+		load, err := stageLoad(ctx, db, fileIO, "db", "sales", metadata, "main", loadModeAppend, []string{first})
+		if err != nil {
+			t.Fatal(err)
+		}
+		applyLoad(metadata, load)
+		files := countFiles(t, fileIO.WarehouseDir)
+
+		_, err = stageLoad(ctx, db, fileIO, "db", "sales", metadata, "main", loadModeMerge, []string{moved})
+		if err == nil || !strings.Contains(err.Error(), "partition column(s) region") {
+			t.Fatalf("merge error %v, want one asking for region among the identifier fields", err)
+		}
+		if got := countFiles(t, fileIO.WarehouseDir); got != files {
+			t.Errorf("the refused merge left %d files in the warehouse, want %d", got, files)
+		}
+	})
+
+	t.Run("key with the partition column", func(t *testing.T) {
+		metadata := regionTable(1, 2)
+		load, err := stageLoad(ctx, db, fileIO, "db", "sales", metadata, "main", loadModeMerge, []string{moved})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(load.dataFiles) != 1 || len(load.deleteFiles) != 1 {
+			t.Fatalf("merge wrote %d data and %d delete files, want one of each", len(load.dataFiles), len(load.deleteFiles))
+		}
+		deletes := load.deleteFiles[0]
+		if want := map[string]interface{}{"region": "b"}; !reflect.DeepEqual(deletes.Partition, want) {
+			t.Errorf("equality deletes in partition %v, want %v", deletes.Partition, want)
+		}
+		if !reflect.DeepEqual(deletes.EqualityIDs, []int{1, 2}) {
+			t.Errorf("equality field IDs %v, want [1 2]", deletes.EqualityIDs)
+		}
+	})
+}
@@ -0,0 +1,264 @@
+package warehouse
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+)
+
+// orphanFile is a file under a table location that no metadata references
+type orphanFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// reachableFiles collects the local paths of every file referenced by the table metadata:
+// metadata files, statistics, and the manifest lists, manifests and data files of all snapshots
+func reachableFiles(fileIO *iceberg.FileIO, table *iceberg.LoadTableResult) (map[string]bool, error) {
+	reachable := make(map[string]bool)
+	add := func(location string) {
+		reachable[filepath.Clean(fileIO.LocalPath(location))] = true
+	}
+
+	metadata := table.Metadata
+	add(table.MetadataLocation)
+	for _, entry := range metadata.MetadataLog {
+		add(entry.MetadataFile)
+	}
+	for _, stats := range metadata.Statistics {
+		add(stats.StatisticsPath)
+	}
+	for _, stats := range metadata.PartitionStatistics {
+		add(stats.StatisticsPath)
+	}
+
+	// Manifests are shared between snapshots, only read each one once
+	readManifests := make(map[string]bool)
+	addManifest := func(location string) error {
+		if readManifests[location] {
+			return nil
+		}
+		readManifests[location] = true
+		add(location)
+
+		entries, err := fileIO.ReadManifest(location)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			add(entry.DataFile.FilePath)
+		}
+		return nil
+	}
+
+	for _, snapshot := range metadata.Snapshots {
+		// Format v1 tables may list manifests directly in the snapshot
+		for _, manifest := range snapshot.Manifests {
+			if err := addManifest(manifest); err != nil {
+				return nil, err
+			}
+		}
+
+		if snapshot.ManifestList == "" {
+			continue
+		}
+		add(snapshot.ManifestList)
+
+		manifests, err := fileIO.ReadManifestList(snapshot.ManifestList)
+		if err != nil {
+			return nil, err
+		}
+		for _, manifest := range manifests {
+			if err := addManifest(manifest.Path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return reachable, nil
+}
+
+// isReachable reports whether a file is referenced, treating Hadoop checksum
+// files (.name.crc) and version hints as belonging to the table
+func isReachable(path string, reachable map[string]bool) bool {
+	if reachable[path] {
+		return true
+	}
+
+	name := filepath.Base(path)
+	if name == "version-hint.text" || name == ".version-hint.text.crc" {
+		return true
+	}
+	if strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".crc") {
+		original := strings.TrimSuffix(strings.TrimPrefix(name, "."), ".crc")
+		return reachable[filepath.Join(filepath.Dir(path), original)]
+	}
+
+	return false
+}
+
+// findOrphanFiles walks a table directory and returns unreferenced files last modified before cutoff
+func findOrphanFiles(tableDir string, reachable map[string]bool, cutoff time.Time) ([]orphanFile, error) {
+	var orphans []orphanFile
+
+	err := filepath.Walk(tableDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || isReachable(filepath.Clean(path), reachable) {
+			return nil
+		}
+
+		// Recent files may belong to a commit that is still in progress
+		if info.ModTime().After(cutoff) {
+			return nil
+		}
+
+		orphans = append(orphans, orphanFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+
+	return orphans, err
+}
+
+// isWithinDir reports whether path is located inside dir
+func isWithinDir(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func runRemoveOrphanFiles(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("remove-orphan-files")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "", "Only check tables in this namespace (default: all namespaces)")
+	tableName := fs.String("table", "", "Only check this table (requires --namespace)")
+	olderThan := fs.Duration("older-than", 72*time.Hour, "Only report files last modified more than this long ago")
+	deleteFiles := fs.Bool("delete", false, "Delete the orphan files instead of only listing them")
+	if positional := parseInterspersed(fs, args); len(positional) > 0 {
+		return cli.Usagef("unexpected arguments %s", strings.Join(positional, " "))
+	}
+
+	if *tableName != "" && *namespace == "" {
+		return cli.Usagef("--table requires --namespace")
+	}
+	if *olderThan < time.Hour {
+		return cli.Usagef("--older-than must be at least 1h to avoid deleting files of in-flight commits")
+	}
+
+	client := opts.client()
+	fileIO := opts.fileIO()
+	cutoff := time.Now().Add(-*olderThan)
+
+	namespaces := []string{*namespace}
+	if *namespace == "" {
+		var err error
+		namespaces, err = client.ListNamespaces(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("🔍 Looking for orphan files older than %s in %s\n", *olderThan, opts.warehouseDir)
+
+	tableCount := 0
+	var orphanCount, orphanBytes int64
+	var failed []string
+
+	for _, ns := range namespaces {
+		tables := []string{*tableName}
+		if *tableName == "" {
+			var err error
+			tables, err = client.ListTables(ctx, ns)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, t := range tables {
+			fmt.Printf("\n🧊 Table '%s.%s'\n", ns, t)
+
+			table, err := client.LoadTable(ctx, ns, t)
+			if err != nil {
+				fmt.Printf("⚠️  %v\n", err)
+				failed = append(failed, ns+"."+t)
+				continue
+			}
+
+			tableDir := fileIO.LocalPath(table.Metadata.Location)
+			if !isWithinDir(tableDir, opts.warehouseDir) {
+				fmt.Printf("⚠️  Table location %s is outside the warehouse directory, skipping...\n", table.Metadata.Location)
+				failed = append(failed, ns+"."+t)
+				continue
+			}
+			if _, err := os.Stat(tableDir); os.IsNotExist(err) {
+				fmt.Printf("ℹ️  Table directory %s does not exist, nothing to clean\n", tableDir)
+				continue
+			}
+
+			// Never delete anything unless the whole metadata tree could be read
+			reachable, err := reachableFiles(fileIO, table)
+			if err != nil {
+				fmt.Printf("⚠️  Failed to read table metadata, skipping: %v\n", err)
+				failed = append(failed, ns+"."+t)
+				continue
+			}
+
+			orphans, err := findOrphanFiles(tableDir, reachable, cutoff)
+			if err != nil {
+				fmt.Printf("⚠️  Failed to walk %s: %v\n", tableDir, err)
+				failed = append(failed, ns+"."+t)
+				continue
+			}
+
+			tableCount++
+			fmt.Printf("📊 %d referenced files, %d orphan files\n", len(reachable), len(orphans))
+
+			for _, orphan := range orphans {
+				relPath, _ := filepath.Rel(opts.warehouseDir, orphan.Path)
+				fmt.Printf("   - %s (%d bytes, modified %s)\n", relPath, orphan.Size, orphan.ModTime.Format(time.RFC3339))
+
+				if *deleteFiles {
+					if err := os.Remove(orphan.Path); err != nil {
+						fmt.Printf("     ⚠️  Failed to delete: %v\n", err)
+						continue
+					}
+				}
+				orphanCount++
+				orphanBytes += orphan.Size
+			}
+		}
+	}
+
+	fmt.Println("\n📊 Summary:")
+	fmt.Printf("   - Tables checked: %d\n", tableCount)
+	if *deleteFiles {
+		fmt.Printf("   - Orphan files deleted: %d (%d bytes)\n", orphanCount, orphanBytes)
+	} else {
+		fmt.Printf("   - Orphan files found: %d (%d bytes)\n", orphanCount, orphanBytes)
+		if orphanCount > 0 {
+			fmt.Println("💡 Re-run with --delete to remove them")
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to check %d table(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
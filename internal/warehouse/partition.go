@@ -0,0 +1,333 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// partitionExpression returns the DuckDB expression computing a partition field the way
+// Iceberg stores it in manifests: dates as days and timestamps as microseconds since the
+// epoch, and time transforms as the number of years, months, days or hours since 1970
+func partitionExpression(schema *iceberg.Schema, field iceberg.PartitionField) (string, error) {
+	source := schema.FieldByID(field.SourceID)
+	if source == nil {
+		return "", fmt.Errorf("partition field %s references unknown column %d", field.Name, field.SourceID)
+	}
+	column := sqlutil.QuoteIdentifier(source.Name)
+	typ, _ := source.Type.(string)
+	isTime := typ == "date" || typ == "timestamp" || typ == "timestamptz"
+
+	// Iceberg derives the time transforms of timestamptz values from UTC. DuckDB would
+	// extract their parts in the TimeZone of the session, so they are first turned into the
+	// UTC timestamp of the same instant.
+	utc := column
+	if typ == "timestamptz" {
+		utc = fmt.Sprintf("make_timestamp(epoch_us(%s))", column)
+	}
+
+	switch transform := field.Transform; {
+	case transform == "identity":
+		switch typ {
+		case "date":
+			return fmt.Sprintf("date_diff('day', DATE '1970-01-01', %s)", column), nil
+		case "timestamp", "timestamptz":
+			return fmt.Sprintf("epoch_us(%s)", column), nil
+		case "time":
+			return "", fmt.Errorf("identity partitioning on time column %s is not supported", source.Name)
+		}
+		return column, nil
+	case transform == "void":
+		return "CAST(NULL AS INTEGER)", nil
+	case transform == "year" && isTime:
+		return fmt.Sprintf("year(%s) - 1970", utc), nil
+	case transform == "month" && isTime:
+		return fmt.Sprintf("(year(%s) - 1970) * 12 + month(%s) - 1", utc, utc), nil
+	case transform == "day" && isTime:
+		return fmt.Sprintf("date_diff('day', DATE '1970-01-01', CAST(%s AS DATE))", utc), nil
+	case transform == "hour" && (typ == "timestamp" || typ == "timestamptz"):
+		return fmt.Sprintf("date_diff('hour', TIMESTAMP '1970-01-01', CAST(%s AS TIMESTAMP))", utc), nil
+	case strings.HasPrefix(transform, "truncate["):
+		width, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(transform, "truncate["), "]"))
+		if err != nil || width <= 0 {
+			return "", fmt.Errorf("invalid transform %s", transform)
+		}
+		switch typ {
+		case "int", "long":
+			return fmt.Sprintf("%s - (((%s %% %d) + %d) %% %d)", column, column, width, width, width), nil
+		case "string":
+			return fmt.Sprintf("left(%s, %d)", column, width), nil
+		}
+	}
+
+	return "", fmt.Errorf("transform %s is not supported on column %s of type %v", field.Transform, source.Name, source.Type)
+}
+
+// partitionKey identifies the partition of a file within a spec
+func partitionKey(spec *iceberg.PartitionSpec, partition map[string]interface{}) string {
+	values := make([]string, len(spec.Fields))
+	for i, field := range spec.Fields {
+		values[i] = fmt.Sprintf("%v", partition[field.Name])
+	}
+	return strings.Join(values, "/")
+}
+
+// writePartitionedFiles writes the rows of a query returning the columns of the table
+// schema as data files, one per partition of the table's default spec
+func writePartitionedFiles(ctx context.Context, db *sql.DB, fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, selectSQL string) ([]iceberg.DataFile, error) {
+	spec := metadata.DefaultSpec()
+	if len(spec.Fields) == 0 {
+		file, err := writeDataFile(ctx, db, fileIO, metadata, selectSQL)
+		if err != nil {
+			return nil, err
+		}
+		return []iceberg.DataFile{file}, nil
+	}
+
+	var exprs, columns []string
+	for i, field := range spec.Fields {
+		expr, err := partitionExpression(metadata.CurrentSchema(), field)
+		if err != nil {
+			return nil, err
+		}
+		column := fmt.Sprintf("__partition_%d", i)
+		exprs = append(exprs, fmt.Sprintf("%s AS %s", expr, column))
+		columns = append(columns, column)
+	}
+
+	stagingSQL := fmt.Sprintf(`CREATE TABLE load_staging AS
+SELECT *, dense_rank() OVER (ORDER BY %s) AS __partition
+FROM (SELECT *, %s FROM (%s))`, strings.Join(columns, ", "), strings.Join(exprs, ", "), selectSQL)
+	if _, err := db.ExecContext(ctx, stagingSQL); err != nil {
+		return nil, fmt.Errorf("failed to compute partitions: %v", err)
+	}
+	defer db.ExecContext(ctx, "DROP TABLE load_staging")
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT DISTINCT __partition, %s FROM load_staging ORDER BY 1", strings.Join(columns, ", ")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %v", err)
+	}
+	type partition struct {
+		rank   int64
+		values map[string]interface{}
+	}
+	var partitions []partition
+	for rows.Next() {
+		var rank int64
+		values := make([]interface{}, len(columns))
+		targets := []interface{}{&rank}
+		for i := range values {
+			targets = append(targets, &values[i])
+		}
+		if err := rows.Scan(targets...); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		p := partition{rank: rank, values: make(map[string]interface{})}
+		for i, field := range spec.Fields {
+			p.values[field.Name] = values[i]
+		}
+		partitions = append(partitions, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var files []iceberg.DataFile
+	for _, p := range partitions {
+		file, err := writeDataFile(ctx, db, fileIO, metadata, fmt.Sprintf(
+			"SELECT * EXCLUDE (__partition, %s) FROM load_staging WHERE __partition = %d", strings.Join(columns, ", "), p.rank))
+		if err != nil {
+			removeDataFiles(fileIO, files)
+			return nil, err
+		}
+		file.Partition = p.values
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// partitionTermPattern matches partition terms such as "month(date_mutation)" or "truncate(code, 2)"
+var partitionTermPattern = regexp.MustCompile(`^(\w+)\(\s*([^,\s)]+)\s*(?:,\s*(\d+)\s*)?\)$`)
+
+// parsePartitionTerm parses a column name or a transform applied to a column into the
+// source column, the Iceberg transform and the name of the partition field
+func parsePartitionTerm(term string) (column, transform, name string, err error) {
+	term = strings.TrimSpace(term)
+	m := partitionTermPattern.FindStringSubmatch(term)
+	if m == nil {
+		return term, "identity", term, nil
+	}
+
+	column = m[2]
+	switch fn := strings.ToLower(m[1]); fn {
+	case "year", "month", "day", "hour", "void":
+		if m[3] != "" {
+			return "", "", "", fmt.Errorf("%s takes a single column: %s", fn, term)
+		}
+		return column, fn, column + "_" + fn, nil
+	case "truncate":
+		if m[3] == "" {
+			return "", "", "", fmt.Errorf("truncate needs a width, e.g. truncate(%s, 4)", column)
+		}
+		return column, "truncate[" + m[3] + "]", column + "_trunc", nil
+	}
+	return "", "", "", fmt.Errorf("unsupported partition transform in %q, expected year, month, day, hour, truncate or void", term)
+}
+
+// buildPartitionSpec builds the partition spec of the given terms over a schema. Partition
+// fields keep the ID they had in earlier specs of the table; new ones get the next free ID.
+func buildPartitionSpec(metadata *iceberg.TableMetadata, schema *iceberg.Schema, terms []string) (*iceberg.PartitionSpec, error) {
+	spec := &iceberg.PartitionSpec{Fields: []iceberg.PartitionField{}}
+	lastPartitionID := metadata.LastPartitionID
+	if lastPartitionID < 999 {
+		lastPartitionID = 999
+	}
+	for _, term := range terms {
+		column, transform, name, err := parsePartitionTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		source := schema.FieldByName(column)
+		if source == nil {
+			return nil, fmt.Errorf("no column %s to partition by", column)
+		}
+		field := iceberg.PartitionField{SourceID: source.ID, Name: name, Transform: transform}
+		if _, err := partitionExpression(schema, field); err != nil {
+			return nil, err
+		}
+
+		// Partition fields keep their ID across specs
+		for _, previous := range metadata.PartitionSpecs {
+			for _, f := range previous.Fields {
+				if f.SourceID == field.SourceID && f.Transform == field.Transform {
+					field.FieldID = f.FieldID
+				}
+			}
+		}
+		if field.FieldID == 0 {
+			lastPartitionID++
+			field.FieldID = lastPartitionID
+		}
+		spec.Fields = append(spec.Fields, field)
+	}
+	return spec, nil
+}
+
+// describeSpec identifies a partition spec by its transforms and source columns
+func describeSpec(spec *iceberg.PartitionSpec) string {
+	var terms []string
+	for _, f := range spec.Fields {
+		terms = append(terms, fmt.Sprintf("%s(%d)", f.Transform, f.SourceID))
+	}
+	return strings.Join(terms, ",")
+}
+
+// formatSpec returns a readable form of a partition spec, such as "month(date_mutation)"
+func formatSpec(spec *iceberg.PartitionSpec, schema *iceberg.Schema) string {
+	if len(spec.Fields) == 0 {
+		return "unpartitioned"
+	}
+	var terms []string
+	for _, f := range spec.Fields {
+		column := fmt.Sprintf("%d", f.SourceID)
+		if source := schema.FieldByID(f.SourceID); source != nil {
+			column = source.Name
+		}
+		terms = append(terms, fmt.Sprintf("%s(%s)", f.Transform, column))
+	}
+	return strings.Join(terms, ", ")
+}
+
+// partitionSpecChange returns the requirements and updates making spec the default
+// spec of the table, reusing an identical earlier spec. It returns no updates when the
+// table is already partitioned this way.
+func partitionSpecChange(metadata *iceberg.TableMetadata, spec *iceberg.PartitionSpec) ([]iceberg.TableRequirement, []iceberg.TableUpdate) {
+	existing := -1
+	for _, previous := range metadata.PartitionSpecs {
+		if describeSpec(&previous) == describeSpec(spec) {
+			existing = previous.SpecID
+		}
+	}
+
+	requirements := []iceberg.TableRequirement{iceberg.AssertDefaultSpecID(metadata.DefaultSpecID)}
+	switch {
+	case existing == metadata.DefaultSpecID:
+		return nil, nil
+	case existing >= 0:
+		return requirements, []iceberg.TableUpdate{iceberg.SetDefaultSpec(existing)}
+	}
+
+	for _, previous := range metadata.PartitionSpecs {
+		if previous.SpecID >= spec.SpecID {
+			spec.SpecID = previous.SpecID + 1
+		}
+	}
+	requirements = append(requirements, iceberg.AssertLastAssignedPartitionID(metadata.LastPartitionID))
+	return requirements, []iceberg.TableUpdate{iceberg.AddPartitionSpec(spec), iceberg.SetDefaultSpec(-1)}
+}
+
+func runSetPartitioning(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("set-partitioning")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) < 1 {
+		return cli.Usagef("expected a table")
+	}
+	ns, tableName := parseTableIdentifier(positional[0], *namespace)
+
+	client := opts.client()
+	table, err := client.LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &table.Metadata
+	schema := metadata.CurrentSchema()
+	if schema == nil {
+		return fmt.Errorf("table metadata has no current schema")
+	}
+
+	spec, err := buildPartitionSpec(metadata, schema, positional[1:])
+	if err != nil {
+		return fmt.Errorf("%s.%s: %v", ns, tableName, err)
+	}
+
+	requirements, updates := partitionSpecChange(metadata, spec)
+	if len(updates) == 0 {
+		fmt.Printf("ℹ️  '%s.%s' is already partitioned this way\n", ns, tableName)
+		return nil
+	}
+	commit := &iceberg.TableCommit{
+		Requirements: append([]iceberg.TableRequirement{iceberg.AssertTableUUID(metadata.TableUUID)}, requirements...),
+		Updates:      updates,
+	}
+
+	if _, err := client.CommitTable(ctx, ns, tableName, commit); err != nil {
+		if iceberg.IsCommitConflict(err) {
+			return fmt.Errorf("%w (%s)", err, conflictHint)
+		}
+		return err
+	}
+
+	if len(spec.Fields) == 0 {
+		fmt.Printf("✅ '%s.%s' is now unpartitioned\n", ns, tableName)
+		return nil
+	}
+	fmt.Printf("✅ '%s.%s' is now partitioned by:\n", ns, tableName)
+	for _, field := range spec.Fields {
+		fmt.Printf("   - %s: %s(%s)\n", field.Name, field.Transform, schema.FieldByID(field.SourceID).Name)
+	}
+	fmt.Println("💡 Existing data files keep their partitioning, new loads use the new one")
+	return nil
+}
@@ -0,0 +1,623 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/dictionary"
+	"the-modern-data-stack/internal/files"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/logging"
+)
+
+// PlanCommands are the subcommands comparing the catalog with the declared state of the
+// warehouse and applying the differences
+var PlanCommands = []cli.Command{
+	{Name: "plan", Summary: "Show the changes that would bring the catalog in line with the source files and config", Run: runPlan},
+	{Name: "apply", Summary: "Make the changes shown by plan", Run: runApply},
+}
+
+// plannedTable is a table as declared by its source files and config
+type plannedTable struct {
+	namespace  string
+	name       string
+	sources    []string
+	modifiedMs int64
+	config     TableConfig
+}
+
+// planAction is one change of a plan: the lines describing it and the function making it
+type planAction struct {
+	symbol  string
+	summary string
+	details []string
+	apply   func() error
+}
+
+// planner computes the changes bringing the catalog in line with the source files, the
+// catalog config and the data dictionary
+type planner struct {
+	db         *sql.DB
+	client     *iceberg.Client
+	fileIO     *iceberg.FileIO
+	config     *CatalogConfig
+	dict       *dictionary.Dictionary
+	namespace  string
+	parquetDir string
+	// allowReplace lets the plan drop and recreate the tables whose column types cannot
+	// change in place, which otherwise fail it
+	allowReplace bool
+	warnings     []string
+}
+
+// declaredTables returns the tables of the Parquet directory, which belong to the default
+// namespace, and the tables of the catalog config, sorted by namespace and name. A table
+// of the config without a source uses the file of the Parquet directory with its name.
+func (p *planner) declaredTables() ([]*plannedTable, error) {
+	tables := make(map[string]*plannedTable)
+
+	parquetTables, err := files.FindParquetTables(p.parquetDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, parquetTable := range parquetTables {
+		key := p.namespace + "." + parquetTable.Name
+		if tables[key] == nil {
+			tables[key] = &plannedTable{namespace: p.namespace, name: parquetTable.Name}
+		}
+		tables[key].sources = append(tables[key].sources, parquetTable.Files...)
+	}
+
+	for namespace, ns := range p.config.Namespaces {
+		for name, config := range ns.Tables {
+			key := namespace + "." + name
+			table := tables[key]
+			if table == nil {
+				table = &plannedTable{namespace: namespace, name: name}
+				tables[key] = table
+			}
+			table.config = config
+
+			if config.Source != "" {
+				sources, err := filepath.Glob(config.Source)
+				if err != nil {
+					return nil, fmt.Errorf("table %s: invalid source %q: %v", key, config.Source, err)
+				}
+				if len(sources) == 0 {
+					return nil, fmt.Errorf("table %s: source %q matches no files", key, config.Source)
+				}
+				table.sources = sources
+			}
+			if mode := config.LoadMode; mode != "" && mode != loadModeAppend && mode != loadModeMerge &&
+				mode != loadModeOverwrite && mode != loadModeOverwritePartitions {
+				return nil, fmt.Errorf("table %s: unknown load mode %q, expected %s, %s, %s or %s", key, mode, loadModeAppend, loadModeMerge, loadModeOverwrite, loadModeOverwritePartitions)
+			}
+		}
+	}
+
+	var declared []*plannedTable
+	for _, key := range sortedKeys(tables) {
+		table := tables[key]
+		sort.Strings(table.sources)
+		for _, source := range table.sources {
+			info, err := os.Stat(source)
+			if err != nil {
+				return nil, err
+			}
+			if ms := info.ModTime().UnixMilli(); ms > table.modifiedMs {
+				table.modifiedMs = ms
+			}
+		}
+		declared = append(declared, table)
+	}
+	return declared, nil
+}
+
+var decimalTypePattern = regexp.MustCompile(`^decimal\((\d+),(\d+)\)$`)
+
+// normalizeType writes an Iceberg primitive type the way the catalog does, such as
+// "decimal(12, 2)" for "DECIMAL(12,2)"
+func normalizeType(t string) string {
+	t = strings.ToLower(strings.ReplaceAll(t, " ", ""))
+	return strings.ReplaceAll(t, ",", ", ")
+}
+
+// isPromotion reports whether Iceberg can widen a column from one primitive type to another
+// without rewriting data files
+func isPromotion(from, to string) bool {
+	switch {
+	case from == "int" && to == "long", from == "float" && to == "double":
+		return true
+	}
+	f := decimalTypePattern.FindStringSubmatch(strings.ReplaceAll(from, " ", ""))
+	t := decimalTypePattern.FindStringSubmatch(strings.ReplaceAll(to, " ", ""))
+	if f == nil || t == nil || f[2] != t[2] {
+		return false
+	}
+	fromPrecision, _ := strconv.Atoi(f[1])
+	toPrecision, _ := strconv.Atoi(t[1])
+	return toPrecision > fromPrecision
+}
+
+// inferSchema reads the combined schema of the source files of a table, then applies the
+// column types of the config and the docs of the data dictionary. Field IDs start from 1.
+func (p *planner) inferSchema(ctx context.Context, table *plannedTable) (*iceberg.Schema, error) {
+	rows, err := p.db.QueryContext(ctx, fmt.Sprintf("DESCRIBE SELECT * FROM read_parquet(%s, union_by_name = true%s)", parquetList(table.sources), sourceOptions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema of %s: %v", strings.Join(table.sources, ", "), err)
+	}
+	defer rows.Close()
+
+	schema := &iceberg.Schema{Type: "struct", Fields: []iceberg.Field{}}
+	for rows.Next() {
+		var name, typ, null string
+		var key, defaultValue, extra sql.NullString
+		if err := rows.Scan(&name, &typ, &null, &key, &defaultValue, &extra); err != nil {
+			return nil, err
+		}
+		field := iceberg.Field{ID: len(schema.Fields) + 1, Name: name, Required: null == "NO"}
+
+		if override, ok := table.config.Columns[name]; ok {
+			if _, err := duckDBType(normalizeType(override)); err != nil {
+				return nil, fmt.Errorf("column %s: %v", name, err)
+			}
+			field.Type = normalizeType(override)
+		} else if field.Type, err = icebergType(typ); err != nil {
+			return nil, fmt.Errorf("column %s: %v, set its type under columns in the catalog config", name, err)
+		}
+		if entry, ok := p.dict.Column(table.name, name); ok {
+			field.Doc = entry.Doc()
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for column := range table.config.Columns {
+		if schema.FieldByName(column) == nil {
+			return nil, fmt.Errorf("the type of column %s is set, but the source files have no such column", column)
+		}
+	}
+	return schema, nil
+}
+
+// documentedSchema returns a copy of a schema with the docs of the data dictionary, for
+// tables whose columns are not declared by source files
+func (p *planner) documentedSchema(tableName string, current *iceberg.Schema) *iceberg.Schema {
+	schema := *current
+	schema.Fields = append([]iceberg.Field(nil), current.Fields...)
+	for i := range schema.Fields {
+		if entry, ok := p.dict.Column(tableName, schema.Fields[i].Name); ok {
+			schema.Fields[i].Doc = entry.Doc()
+		}
+	}
+	return &schema
+}
+
+// evolveSchema returns the current schema evolved towards the desired one: new columns
+// are added as optional columns, types are widened and docs updated. Columns missing
+// from the desired schema are kept. It also returns the lines describing the changes and
+// the type changes Iceberg cannot make in place.
+func evolveSchema(current, desired *iceberg.Schema, lastColumnID int) (*iceberg.Schema, int, []string, []string) {
+	schema := *current
+	schema.Fields = append([]iceberg.Field(nil), current.Fields...)
+	var lines, incompatible []string
+
+	for _, want := range desired.Fields {
+		field := schema.FieldByName(want.Name)
+		if field == nil {
+			lastColumnID++
+			schema.Fields = append(schema.Fields, iceberg.Field{ID: lastColumnID, Name: want.Name, Type: want.Type, Doc: want.Doc})
+			lines = append(lines, fmt.Sprintf("+ column %s: %v", want.Name, want.Type))
+			continue
+		}
+
+		from, to := fmt.Sprint(field.Type), fmt.Sprint(want.Type)
+		switch {
+		case normalizeType(from) == normalizeType(to), isPromotion(to, from):
+			// Narrower source values are cast to the wider column type on load
+		case isPromotion(from, to):
+			field.Type = want.Type
+			lines = append(lines, fmt.Sprintf("~ column %s: %s → %s", field.Name, from, to))
+		default:
+			incompatible = append(incompatible, fmt.Sprintf("column %s: %s → %s", field.Name, from, to))
+		}
+		if want.Doc != "" && want.Doc != field.Doc {
+			lines = append(lines, fmt.Sprintf("~ doc of %s: %q", field.Name, want.Doc))
+			field.Doc = want.Doc
+		}
+	}
+	return &schema, lastColumnID, lines, incompatible
+}
+
+// loadMode returns the load mode of a table, overwrite unless the config sets one
+func (t *plannedTable) loadMode() string {
+	if t.config.LoadMode != "" {
+		return t.config.LoadMode
+	}
+	return loadModeOverwrite
+}
+
+// load loads the source files of a table into its main branch
+func (p *planner) load(ctx context.Context, table *plannedTable, mode string) error {
+	result, err := p.client.LoadTable(ctx, table.namespace, table.name)
+	if err != nil {
+		return err
+	}
+	load, err := stageLoad(ctx, p.db, p.fileIO, table.namespace, table.name, &result.Metadata, "main", mode, table.sources)
+	if err != nil {
+		return err
+	}
+	if err := commitLoad(ctx, p.client, p.fileIO, load); err != nil {
+		return err
+	}
+	fmt.Printf("   - committed snapshot %d\n", load.snapshot.SnapshotID)
+	return nil
+}
+
+// planNamespace plans the creation of a namespace or the update of its properties
+func (p *planner) planNamespace(ctx context.Context, namespace string) (*planAction, bool, error) {
+	set, removals := p.config.Namespaces[namespace].DesiredProperties()
+	current, err := p.client.LoadNamespaceProperties(ctx, namespace)
+	if iceberg.IsNotFound(err) {
+		action := &planAction{
+			symbol:  "+",
+			summary: "create namespace " + namespace,
+			apply:   func() error { return p.client.CreateNamespace(ctx, namespace, set) },
+		}
+		for _, key := range sortedKeys(set) {
+			action.details = append(action.details, fmt.Sprintf("+ %s = %q", key, set[key]))
+		}
+		return action, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	updates, removed, lines := propertyDiff(current, set, removals)
+	if len(lines) == 0 {
+		return nil, true, nil
+	}
+	return &planAction{
+		symbol:  "~",
+		summary: "update namespace " + namespace,
+		details: lines,
+		apply:   func() error { return p.client.UpdateNamespaceProperties(ctx, namespace, removed, updates) },
+	}, true, nil
+}
+
+// planCreate plans the creation of a table and the load of its source files
+func (p *planner) planCreate(ctx context.Context, table *plannedTable, schema *iceberg.Schema, symbol, summary string) (*planAction, error) {
+	set, _, err := table.config.DesiredProperties()
+	if err != nil {
+		return nil, err
+	}
+	request := &iceberg.CreateTableRequest{Name: table.name, Schema: *schema, Properties: set}
+	if len(table.config.PartitionBy) > 0 {
+		if request.PartitionSpec, err = buildPartitionSpec(&iceberg.TableMetadata{}, schema, table.config.PartitionBy); err != nil {
+			return nil, err
+		}
+	}
+
+	action := &planAction{symbol: symbol, summary: summary}
+	for _, field := range schema.Fields {
+		line := fmt.Sprintf("+ column %s: %v", field.Name, field.Type)
+		if field.Doc != "" {
+			line += fmt.Sprintf(" (%s)", field.Doc)
+		}
+		action.details = append(action.details, line)
+	}
+	if request.PartitionSpec != nil {
+		action.details = append(action.details, "+ partitioned by "+formatSpec(request.PartitionSpec, schema))
+	}
+	for _, key := range sortedKeys(set) {
+		action.details = append(action.details, fmt.Sprintf("+ %s = %q", key, set[key]))
+	}
+	action.details = append(action.details, fmt.Sprintf("load %d file(s)", len(table.sources)))
+
+	create := func() error {
+		if _, err := p.client.CreateTable(ctx, table.namespace, request); err != nil {
+			return err
+		}
+		// The table is empty, so overwriting is the same as any other mode
+		return p.load(ctx, table, loadModeOverwrite)
+	}
+	action.apply = create
+	if symbol == "-/+" {
+		action.details = append(action.details, "! the snapshots, branches and tags of the table are lost")
+		action.apply = func() error {
+			if err := p.client.DropTable(ctx, table.namespace, table.name, true); err != nil {
+				return err
+			}
+			return create()
+		}
+	}
+	return action, nil
+}
+
+// planUpdate plans the schema, partitioning and property changes of an existing table,
+// its replacement when a column type cannot change in place, and the load of source files
+// modified since the current snapshot
+func (p *planner) planUpdate(ctx context.Context, table *plannedTable, desired *iceberg.Schema, metadata *iceberg.TableMetadata) ([]*planAction, error) {
+	key := table.namespace + "." + table.name
+	current := metadata.CurrentSchema()
+	if current == nil {
+		return nil, fmt.Errorf("table metadata has no current schema")
+	}
+	if desired == nil {
+		desired = p.documentedSchema(table.name, current)
+	}
+
+	schema, lastColumnID, lines, incompatible := evolveSchema(current, desired, metadata.LastColumnID)
+	if len(incompatible) > 0 {
+		if !p.allowReplace {
+			return nil, fmt.Errorf("column types cannot change in place (%s), pass --allow-replace to drop and recreate the table, losing its snapshots, branches and tags",
+				strings.Join(incompatible, "; "))
+		}
+		action, err := p.planCreate(ctx, table, desired, "-/+", "replace table "+key)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(incompatible) - 1; i >= 0; i-- {
+			action.details = append([]string{"! " + incompatible[i] + " cannot change in place"}, action.details...)
+		}
+		return []*planAction{action}, nil
+	}
+
+	commit := &iceberg.TableCommit{Requirements: []iceberg.TableRequirement{iceberg.AssertTableUUID(metadata.TableUUID)}}
+	if len(lines) > 0 {
+		for _, s := range metadata.Schemas {
+			if s.SchemaID >= schema.SchemaID {
+				schema.SchemaID = s.SchemaID + 1
+			}
+		}
+		commit.Requirements = append(commit.Requirements, iceberg.AssertCurrentSchemaID(current.SchemaID))
+		commit.Updates = append(commit.Updates, iceberg.AddSchema(schema, lastColumnID), iceberg.SetCurrentSchema(-1))
+	}
+
+	if table.config.PartitionBy != nil {
+		spec, err := buildPartitionSpec(metadata, schema, table.config.PartitionBy)
+		if err != nil {
+			return nil, err
+		}
+		requirements, updates := partitionSpecChange(metadata, spec)
+		if len(updates) > 0 {
+			lines = append(lines, fmt.Sprintf("~ partitioning: %s → %s", formatSpec(metadata.DefaultSpec(), schema), formatSpec(spec, schema)))
+			commit.Requirements = append(commit.Requirements, requirements...)
+			commit.Updates = append(commit.Updates, updates...)
+		}
+	}
+
+	set, removals, err := table.config.DesiredProperties()
+	if err != nil {
+		return nil, err
+	}
+	updates, removed, propertyLines := propertyDiff(metadata.Properties, set, removals)
+	lines = append(lines, propertyLines...)
+	if len(updates) > 0 {
+		commit.Updates = append(commit.Updates, iceberg.SetProperties(updates))
+	}
+	if len(removed) > 0 {
+		commit.Updates = append(commit.Updates, iceberg.RemoveProperties(removed))
+	}
+
+	var actions []*planAction
+	if len(lines) > 0 {
+		actions = append(actions, &planAction{
+			symbol:  "~",
+			summary: "update table " + key,
+			details: lines,
+			apply: func() error {
+				_, err := p.client.CommitTable(ctx, table.namespace, table.name, commit)
+				return err
+			},
+		})
+	}
+
+	if len(table.sources) > 0 {
+		var reason string
+		if snapshot := metadata.CurrentSnapshot(); snapshot == nil {
+			reason = "the table is empty"
+		} else if table.modifiedMs > snapshot.TimestampMs {
+			reason = fmt.Sprintf("source files modified since snapshot %d of %s", snapshot.SnapshotID, formatTimestampMs(snapshot.TimestampMs))
+		}
+		if reason != "" {
+			mode := table.loadMode()
+			actions = append(actions, &planAction{
+				symbol:  "↻",
+				summary: fmt.Sprintf("load table %s (%s)", key, mode),
+				details: []string{fmt.Sprintf("%d file(s): %s", len(table.sources), reason)},
+				apply:   func() error { return p.load(ctx, table, mode) },
+			})
+		}
+	}
+	return actions, nil
+}
+
+// plan returns the actions bringing the catalog in line with the declared state, in the
+// order they must be applied
+func (p *planner) plan(ctx context.Context) ([]*planAction, error) {
+	tables, err := p.declaredTables()
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make(map[string]bool)
+	for namespace := range p.config.Namespaces {
+		namespaces[namespace] = true
+	}
+	for _, table := range tables {
+		if len(table.sources) > 0 {
+			namespaces[table.namespace] = true
+		}
+	}
+
+	var actions []*planAction
+	exists := make(map[string]bool)
+	for _, namespace := range sortedKeys(namespaces) {
+		action, found, err := p.planNamespace(ctx, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %v", namespace, err)
+		}
+		exists[namespace] = found
+		if action != nil {
+			actions = append(actions, action)
+		}
+	}
+
+	for _, table := range tables {
+		key := table.namespace + "." + table.name
+		var desired *iceberg.Schema
+		if len(table.sources) > 0 {
+			if desired, err = p.inferSchema(ctx, table); err != nil {
+				return nil, fmt.Errorf("table %s: %v", key, err)
+			}
+		}
+
+		var metadata *iceberg.TableMetadata
+		if exists[table.namespace] {
+			result, err := p.client.LoadTable(ctx, table.namespace, table.name)
+			if err != nil && !iceberg.IsNotFound(err) {
+				return nil, err
+			}
+			if err == nil {
+				metadata = &result.Metadata
+			}
+		}
+
+		switch {
+		case metadata == nil && desired == nil:
+			p.warnings = append(p.warnings, fmt.Sprintf("Table %s is declared but has no source files and does not exist, skipping...", key))
+		case metadata == nil:
+			action, err := p.planCreate(ctx, table, desired, "+", "create table "+key)
+			if err != nil {
+				return nil, fmt.Errorf("table %s: %v", key, err)
+			}
+			actions = append(actions, action)
+		default:
+			tableActions, err := p.planUpdate(ctx, table, desired, metadata)
+			if err != nil {
+				return nil, fmt.Errorf("table %s: %v", key, err)
+			}
+			actions = append(actions, tableActions...)
+		}
+	}
+	return actions, nil
+}
+
+// printPlan prints the actions of a plan and a count of them by kind
+func printPlan(warnings []string, actions []*planAction) {
+	for _, warning := range warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	if len(actions) == 0 {
+		fmt.Println("✅ No changes: the catalog matches the source files and config")
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, action := range actions {
+		fmt.Printf("\n%s %s\n", action.symbol, action.summary)
+		for _, line := range action.details {
+			fmt.Printf("    %s\n", line)
+		}
+		counts[action.symbol]++
+	}
+	fmt.Printf("\nPlan: %d to create, %d to update, %d to replace, %d to load.\n", counts["+"], counts["~"], counts["-/+"], counts["↻"])
+}
+
+// newPlanner registers the flags of plan and apply and returns the planner they configure,
+// once opened
+func newPlanner(name string, args []string) (*planner, error) {
+	fs := cli.NewFlagSet(name)
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the tables of the Parquet directory")
+	parquetDir := fs.String("parquet-dir", "data/parquet", "Directory holding the Parquet files, one table per file")
+	configPath := fs.String("config", "catalog.yaml", "Catalog config declaring namespaces, tables, column types, partitioning and properties")
+	dictionaryPath := fs.String("dictionary", "data_dictionary.csv", "Data dictionary documenting the columns (.csv, .yaml or .yml)")
+	allowReplace := fs.Bool("allow-replace", false, "Drop and recreate the tables whose column types cannot change in place")
+	if positional := parseInterspersed(fs, args); len(positional) > 0 {
+		return nil, cli.Usagef("unexpected arguments %s", strings.Join(positional, " "))
+	}
+
+	config, err := LoadCatalogConfig(*configPath)
+	if err != nil {
+		return nil, err
+	}
+	dict, err := dictionary.Load(*dictionaryPath)
+	if err != nil {
+		return nil, err
+	}
+	db, err := logging.OpenDB("duckdb", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DuckDB: %v", err)
+	}
+	return &planner{
+		db:           db,
+		client:       opts.client(),
+		fileIO:       opts.fileIO(),
+		config:       config,
+		dict:         dict,
+		namespace:    *namespace,
+		parquetDir:   *parquetDir,
+		allowReplace: *allowReplace,
+	}, nil
+}
+
+func runPlan(ctx context.Context, args []string) error {
+	p, err := newPlanner("plan", args)
+	if err != nil {
+		return err
+	}
+	defer p.db.Close()
+
+	actions, err := p.plan(ctx)
+	if err != nil {
+		return err
+	}
+	printPlan(p.warnings, actions)
+	if len(actions) > 0 {
+		command := "mds apply"
+		if p.allowReplace {
+			command += " --allow-replace"
+		}
+		fmt.Printf("💡 Run '%s' to make these changes\n", command)
+	}
+	return nil
+}
+
+func runApply(ctx context.Context, args []string) error {
+	p, err := newPlanner("apply", args)
+	if err != nil {
+		return err
+	}
+	defer p.db.Close()
+
+	actions, err := p.plan(ctx)
+	if err != nil {
+		return err
+	}
+	printPlan(p.warnings, actions)
+	if len(actions) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	for i, action := range actions {
+		fmt.Printf("▶ %s %s\n", action.symbol, action.summary)
+		if err := action.apply(); err != nil {
+			return fmt.Errorf("%s: %v (%d of %d change(s) applied)", action.summary, err, i, len(actions))
+		}
+	}
+	fmt.Printf("✅ Apply complete: %d change(s) applied\n", len(actions))
+	return nil
+}
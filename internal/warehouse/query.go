@@ -0,0 +1,206 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// timestampLayouts are the accepted formats of timestamp flags such as --as-of
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseTimestamp parses a timestamp in one of the accepted layouts, in local time unless a zone is given
+func parseTimestamp(value string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
+// resolveSnapshot finds the snapshot to read: the current one when asOf is empty,
+// otherwise the head of the branch or tag, the snapshot with that ID, or the one
+// current at that timestamp
+func resolveSnapshot(metadata *iceberg.TableMetadata, asOf string) (*iceberg.Snapshot, error) {
+	if asOf == "" {
+		return metadata.CurrentSnapshot(), nil
+	}
+
+	if ref, ok := metadata.Refs[asOf]; ok {
+		return metadata.SnapshotByID(ref.SnapshotID), nil
+	}
+
+	if id, err := strconv.ParseInt(asOf, 10, 64); err == nil {
+		snapshot := metadata.SnapshotByID(id)
+		if snapshot == nil {
+			return nil, fmt.Errorf("no snapshot with ID %d", id)
+		}
+		return snapshot, nil
+	}
+
+	t, err := parseTimestamp(asOf)
+	if err != nil {
+		return nil, cli.Usagef("invalid --as-of value %q: expected a snapshot ID or a timestamp", asOf)
+	}
+	snapshot := metadata.SnapshotAsOf(t.UnixMilli())
+	if snapshot == nil {
+		return nil, fmt.Errorf("table has no snapshot as of %s", t.Format(time.RFC3339))
+	}
+	return snapshot, nil
+}
+
+// createSnapshotView creates a DuckDB view named after the table that reads the given snapshot
+func createSnapshotView(ctx context.Context, db *sql.DB, fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, viewName string, snapshot *iceberg.Snapshot) error {
+	entries, err := fileIO.ReadSnapshotEntries(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// Snapshots are read with the schema they were written with
+	schema := metadata.CurrentSchema()
+	if snapshot.SchemaID != nil {
+		for i := range metadata.Schemas {
+			if metadata.Schemas[i].SchemaID == *snapshot.SchemaID {
+				schema = &metadata.Schemas[i]
+			}
+		}
+	}
+	if schema == nil {
+		return fmt.Errorf("table metadata has no current schema")
+	}
+
+	scanSQL, err := buildScanSQL(fileIO, schema, entries)
+	if err != nil {
+		return err
+	}
+	if scanSQL == "" {
+		// Keep the columns queryable even when the snapshot holds no data files
+		if scanSQL, err = emptyScanSQL(schema); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE VIEW %s AS %s", sqlutil.QuoteIdentifier(viewName), scanSQL)); err != nil {
+		return fmt.Errorf("failed to create view for %s: %v", viewName, err)
+	}
+	return nil
+}
+
+// printRows prints query results as an aligned text table
+func printRows(rows *sql.Rows) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get columns: %v", err)
+	}
+
+	for i, col := range columns {
+		if i > 0 {
+			fmt.Print(" | ")
+		}
+		fmt.Printf("%-15s", col)
+	}
+	fmt.Println()
+	fmt.Println(strings.Repeat("-", len(columns)*18))
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return count, fmt.Errorf("failed to scan row: %v", err)
+		}
+		for i, val := range values {
+			if i > 0 {
+				fmt.Print(" | ")
+			}
+			if val == nil {
+				fmt.Printf("%-15s", "NULL")
+			} else {
+				fmt.Printf("%-15v", val)
+			}
+		}
+		fmt.Println()
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func runQuery(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("query")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	asOf := fs.String("as-of", "", "Branch, tag, snapshot ID or timestamp (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or 'YYYY-MM-DD') to read the table at")
+	query := fs.String("sql", "", "SQL to run; the table is available as a view named after it (default: SELECT * ... LIMIT --limit)")
+	limit := fs.Int("limit", 10, "Number of rows shown when no --sql is given")
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) != 1 {
+		return cli.Usagef("expected a single table")
+	}
+	ns, tableName := parseTableIdentifier(positional[0], *namespace)
+
+	table, err := opts.client().LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := resolveSnapshot(&table.Metadata, *asOf)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		fmt.Printf("ℹ️  Table '%s.%s' has no snapshots yet\n", ns, tableName)
+		return nil
+	}
+
+	fmt.Printf("🦆 Reading '%s.%s' at snapshot %d (%s)\n", ns, tableName, snapshot.SnapshotID, formatTimestampMs(snapshot.TimestampMs))
+
+	db, err := logging.OpenDB("duckdb", "")
+	if err != nil {
+		return fmt.Errorf("failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+
+	if err := createSnapshotView(ctx, db, opts.fileIO(), &table.Metadata, tableName, snapshot); err != nil {
+		return err
+	}
+
+	if *query == "" {
+		*query = fmt.Sprintf("SELECT * FROM %s LIMIT %d", sqlutil.QuoteIdentifier(tableName), *limit)
+	}
+
+	rows, err := db.QueryContext(ctx, *query)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	count, err := printRows(rows)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n📊 %d row(s)\n", count)
+
+	return nil
+}
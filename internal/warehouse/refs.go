@@ -0,0 +1,228 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/logging"
+)
+
+// createRefCommand returns the subcommand creating a branch or a tag
+func createRefCommand(refType string) func(context.Context, []string) error {
+	return func(ctx context.Context, args []string) error {
+		fs := cli.NewFlagSet("create-" + refType)
+		opts := addCatalogFlags(fs)
+		namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+		snapshotID := fs.Int64("snapshot", 0, "Snapshot the "+refType+" points at (default: current snapshot of main)")
+		maxRefAge := fs.Duration("max-ref-age", 0, "Expire the "+refType+" after this duration (default: keep forever)")
+		positional := parseInterspersed(fs, args)
+
+		if len(positional) != 2 {
+			return cli.Usagef("expected a table and the name of the %s", refType)
+		}
+		ns, tableName := parseTableIdentifier(positional[0], *namespace)
+		name := positional[1]
+
+		client := opts.client()
+		table, err := client.LoadTable(ctx, ns, tableName)
+		if err != nil {
+			return err
+		}
+		metadata := &table.Metadata
+
+		if _, exists := metadata.Refs[name]; exists {
+			return fmt.Errorf("%s.%s already has a branch or tag named %s", ns, tableName, name)
+		}
+
+		var snapshot *iceberg.Snapshot
+		if *snapshotID != 0 {
+			snapshot = metadata.SnapshotByID(*snapshotID)
+			if snapshot == nil {
+				return fmt.Errorf("no snapshot with ID %d", *snapshotID)
+			}
+		} else if snapshot = metadata.CurrentSnapshot(); snapshot == nil {
+			return fmt.Errorf("%s.%s has no snapshot yet, load data first or pass --snapshot", ns, tableName)
+		}
+
+		ref := iceberg.SnapshotRef{SnapshotID: snapshot.SnapshotID, Type: refType}
+		if *maxRefAge > 0 {
+			ageMs := maxRefAge.Milliseconds()
+			ref.MaxRefAgeMs = &ageMs
+		}
+
+		commit := &iceberg.TableCommit{
+			Requirements: []iceberg.TableRequirement{
+				iceberg.AssertTableUUID(metadata.TableUUID),
+				iceberg.AssertRefSnapshotID(name, nil),
+			},
+			Updates: []iceberg.TableUpdate{
+				iceberg.SetSnapshotRef(name, ref),
+			},
+		}
+		if _, err := client.CommitTable(ctx, ns, tableName, commit); err != nil {
+			if iceberg.IsCommitConflict(err) {
+				return fmt.Errorf("%w (%s)", err, conflictHint)
+			}
+			return err
+		}
+
+		fmt.Printf("✅ Created %s '%s' of '%s.%s' at snapshot %d\n", refType, name, ns, tableName, snapshot.SnapshotID)
+		return nil
+	}
+}
+
+// removeRef commits the removal of a branch or tag, provided it did not move since metadata was loaded
+func removeRef(ctx context.Context, client *iceberg.Client, ns, tableName string, metadata *iceberg.TableMetadata, refType, name string) error {
+	ref, exists := metadata.Refs[name]
+	if !exists || ref.Type != refType {
+		return fmt.Errorf("%s.%s has no %s named %s", ns, tableName, refType, name)
+	}
+
+	commit := &iceberg.TableCommit{
+		Requirements: []iceberg.TableRequirement{
+			iceberg.AssertTableUUID(metadata.TableUUID),
+			iceberg.AssertRefSnapshotID(name, &ref.SnapshotID),
+		},
+		Updates: []iceberg.TableUpdate{
+			iceberg.RemoveSnapshotRef(name),
+		},
+	}
+	if _, err := client.CommitTable(ctx, ns, tableName, commit); err != nil {
+		if iceberg.IsCommitConflict(err) {
+			return fmt.Errorf("%w (%s)", err, conflictHint)
+		}
+		return err
+	}
+	return nil
+}
+
+// deleteRefCommand returns the subcommand deleting a branch or a tag
+func deleteRefCommand(refType string) func(context.Context, []string) error {
+	return func(ctx context.Context, args []string) error {
+		fs := cli.NewFlagSet("delete-" + refType)
+		opts := addCatalogFlags(fs)
+		namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+		positional := parseInterspersed(fs, args)
+
+		if len(positional) != 2 {
+			return cli.Usagef("expected a table and the name of the %s", refType)
+		}
+		ns, tableName := parseTableIdentifier(positional[0], *namespace)
+		name := positional[1]
+
+		if name == "main" {
+			return cli.Usagef("the main branch cannot be deleted")
+		}
+
+		client := opts.client()
+		table, err := client.LoadTable(ctx, ns, tableName)
+		if err != nil {
+			return err
+		}
+		metadata := &table.Metadata
+
+		if err := removeRef(ctx, client, ns, tableName, metadata, refType, name); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Deleted %s '%s' of '%s.%s'\n", refType, name, ns, tableName)
+		return nil
+	}
+}
+
+// runChecks runs validation queries against a snapshot; each must return a single true value
+func runChecks(ctx context.Context, fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, tableName string, snapshot *iceberg.Snapshot, checks []string) error {
+	db, err := logging.OpenDB("duckdb", "")
+	if err != nil {
+		return fmt.Errorf("failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+
+	if err := createSnapshotView(ctx, db, fileIO, metadata, tableName, snapshot); err != nil {
+		return err
+	}
+
+	for _, check := range checks {
+		var passed sql.NullBool
+		if err := db.QueryRowContext(ctx, check).Scan(&passed); err != nil {
+			return fmt.Errorf("check %q failed to run: %v", check, err)
+		}
+		if !passed.Valid || !passed.Bool {
+			return fmt.Errorf("check failed: %s", check)
+		}
+		fmt.Printf("   ✅ %s\n", check)
+	}
+
+	return nil
+}
+
+func runFastForward(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("fast-forward")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	from := fs.String("branch", "", "Branch whose changes are published")
+	to := fs.String("to", "main", "Branch to fast-forward")
+	deleteBranch := fs.Bool("delete-branch", false, "Delete the published branch afterwards")
+	var checks stringList
+	fs.Var(&checks, "check", "SQL returning a single boolean that must be true on the branch; the table is a view named after it (repeatable)")
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) != 1 || *from == "" {
+		return cli.Usagef("expected a single table and --branch")
+	}
+	ns, tableName := parseTableIdentifier(positional[0], *namespace)
+
+	client := opts.client()
+	fileIO := opts.fileIO()
+
+	table, err := client.LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &table.Metadata
+
+	source, ok := metadata.Refs[*from]
+	if !ok || source.Type != "branch" {
+		return fmt.Errorf("%s.%s has no branch named %s", ns, tableName, *from)
+	}
+	head := metadata.SnapshotByID(source.SnapshotID)
+	if head == nil {
+		return fmt.Errorf("branch %s points at missing snapshot %d", *from, source.SnapshotID)
+	}
+
+	target := metadata.BranchSnapshot(*to)
+	if target != nil && !metadata.IsAncestorOf(target.SnapshotID, head.SnapshotID) {
+		return fmt.Errorf("cannot fast-forward %s: it has changes that are not in %s", *to, *from)
+	}
+	if target != nil && target.SnapshotID == head.SnapshotID {
+		fmt.Printf("ℹ️  %s already points at snapshot %d\n", *to, head.SnapshotID)
+		return nil
+	}
+
+	if len(checks) > 0 {
+		fmt.Printf("🔍 Running %d check(s) on branch %s...\n", len(checks), *from)
+		start := time.Now()
+		if err := runChecks(ctx, fileIO, metadata, tableName, head, checks); err != nil {
+			return fmt.Errorf("not publishing branch %s: %v", *from, err)
+		}
+		fmt.Printf("✅ All checks passed in %s\n", time.Since(start).Round(time.Millisecond))
+	}
+
+	fmt.Printf("⏩ Fast-forwarding %s of '%s.%s' to snapshot %d of branch %s...\n", *to, ns, tableName, head.SnapshotID, *from)
+	if err := moveBranch(ctx, client, ns, tableName, metadata, *to, target, head.SnapshotID); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Branch %s now points at snapshot %d\n", *to, head.SnapshotID)
+
+	if *deleteBranch {
+		if err := removeRef(ctx, client, ns, tableName, metadata, "branch", *from); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  Deleted branch %s\n", *from)
+	}
+	return nil
+}
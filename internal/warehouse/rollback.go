@@ -0,0 +1,250 @@
+package warehouse
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+)
+
+// conflictHint explains a rejected commit to the user
+const conflictHint = "another writer changed the table since it was loaded, re-run the command against the new state"
+
+// moveBranch commits a branch move from its current snapshot to snapshotID. The
+// assert-ref-snapshot-id requirement makes the catalog reject the commit if the
+// branch moved in the meantime.
+func moveBranch(ctx context.Context, client *iceberg.Client, ns, tableName string, metadata *iceberg.TableMetadata, branch string, current *iceberg.Snapshot, snapshotID int64) error {
+	ref := iceberg.SnapshotRef{Type: "branch"}
+	if existing, ok := metadata.Refs[branch]; ok {
+		ref = existing
+	}
+	ref.SnapshotID = snapshotID
+
+	var currentID *int64
+	if current != nil {
+		currentID = &current.SnapshotID
+	}
+
+	commit := &iceberg.TableCommit{
+		Requirements: []iceberg.TableRequirement{
+			iceberg.AssertTableUUID(metadata.TableUUID),
+			iceberg.AssertRefSnapshotID(branch, currentID),
+		},
+		Updates: []iceberg.TableUpdate{
+			iceberg.SetSnapshotRef(branch, ref),
+		},
+	}
+
+	if _, err := client.CommitTable(ctx, ns, tableName, commit); err != nil {
+		if iceberg.IsCommitConflict(err) {
+			return fmt.Errorf("%w (%s)", err, conflictHint)
+		}
+		return err
+	}
+	return nil
+}
+
+// ancestorAsOf returns the latest ancestor of a snapshot created at or before timestampMs
+func ancestorAsOf(metadata *iceberg.TableMetadata, snapshot *iceberg.Snapshot, timestampMs int64) *iceberg.Snapshot {
+	for snapshot != nil {
+		if snapshot.TimestampMs <= timestampMs {
+			return snapshot
+		}
+		if snapshot.ParentSnapshotID == nil {
+			return nil
+		}
+		snapshot = metadata.SnapshotByID(*snapshot.ParentSnapshotID)
+	}
+	return nil
+}
+
+func runRollback(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("rollback")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	branch := fs.String("branch", "main", "Branch to roll back")
+	toSnapshot := fs.Int64("to-snapshot", 0, "Snapshot ID to roll back to (must be an ancestor of the branch)")
+	toTimestamp := fs.String("to-timestamp", "", "Roll back to the latest ancestor created at or before this timestamp")
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) != 1 {
+		return cli.Usagef("expected a single table")
+	}
+	if (*toSnapshot == 0) == (*toTimestamp == "") {
+		return cli.Usagef("exactly one of --to-snapshot or --to-timestamp is required")
+	}
+	var asOf time.Time
+	if *toTimestamp != "" {
+		var err error
+		if asOf, err = parseTimestamp(*toTimestamp); err != nil {
+			return cli.Usagef("invalid --to-timestamp: %v", err)
+		}
+	}
+	ns, tableName := parseTableIdentifier(positional[0], *namespace)
+
+	client := opts.client()
+	table, err := client.LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &table.Metadata
+
+	current := metadata.BranchSnapshot(*branch)
+	if current == nil {
+		return fmt.Errorf("branch %s of %s.%s has no snapshot to roll back", *branch, ns, tableName)
+	}
+
+	var target *iceberg.Snapshot
+	if *toSnapshot != 0 {
+		target = metadata.SnapshotByID(*toSnapshot)
+		if target == nil {
+			return fmt.Errorf("no snapshot with ID %d", *toSnapshot)
+		}
+		if !metadata.IsAncestorOf(target.SnapshotID, current.SnapshotID) {
+			return fmt.Errorf("snapshot %d is not an ancestor of branch %s", target.SnapshotID, *branch)
+		}
+	} else {
+		target = ancestorAsOf(metadata, current, asOf.UnixMilli())
+		if target == nil {
+			return fmt.Errorf("branch %s has no snapshot at or before %s", *branch, *toTimestamp)
+		}
+	}
+
+	if target.SnapshotID == current.SnapshotID {
+		fmt.Printf("ℹ️  Branch %s of '%s.%s' already points at snapshot %d\n", *branch, ns, tableName, target.SnapshotID)
+		return nil
+	}
+
+	fmt.Printf("⏪ Rolling back branch %s of '%s.%s' from snapshot %d to %d (%s)...\n",
+		*branch, ns, tableName, current.SnapshotID, target.SnapshotID, formatTimestampMs(target.TimestampMs))
+
+	if err := moveBranch(ctx, client, ns, tableName, metadata, *branch, current, target.SnapshotID); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Branch %s now points at snapshot %d\n", *branch, target.SnapshotID)
+	fmt.Printf("💡 Snapshot %d is still available, e.g. for 'mds maintain cherry-pick' or 'mds maintain rollback' in the other direction\n", current.SnapshotID)
+	return nil
+}
+
+// addedDataFiles returns the data files a snapshot added, read from the manifests it wrote
+func addedDataFiles(fileIO *iceberg.FileIO, snapshot *iceberg.Snapshot) ([]iceberg.DataFile, error) {
+	manifests, err := fileIO.ReadManifestList(snapshot.ManifestList)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []iceberg.DataFile
+	for _, manifest := range manifests {
+		if manifest.AddedSnapshotID != snapshot.SnapshotID || manifest.Content != iceberg.ManifestContentData {
+			continue
+		}
+		entries, err := fileIO.ReadManifest(manifest.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Status == iceberg.EntryStatusAdded && entry.SnapshotID == snapshot.SnapshotID {
+				files = append(files, entry.DataFile)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+func runCherryPick(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("cherry-pick")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	snapshotID := fs.Int64("snapshot", 0, "ID of the append snapshot to apply to the branch")
+	branch := fs.String("branch", "main", "Branch to apply the snapshot to")
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) != 1 || *snapshotID == 0 {
+		return cli.Usagef("expected a single table and --snapshot")
+	}
+	ns, tableName := parseTableIdentifier(positional[0], *namespace)
+
+	client := opts.client()
+	fileIO := opts.fileIO()
+
+	table, err := client.LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &table.Metadata
+
+	picked := metadata.SnapshotByID(*snapshotID)
+	if picked == nil {
+		return fmt.Errorf("no snapshot with ID %d", *snapshotID)
+	}
+	if operation := picked.Summary["operation"]; operation != iceberg.OperationAppend {
+		if operation == "" {
+			operation = "unknown"
+		}
+		return fmt.Errorf("snapshot %d was written by a %s operation, only append snapshots can be cherry-picked", picked.SnapshotID, operation)
+	}
+	// main exists as soon as the table does, other branches must have been created
+	if ref, ok := metadata.Refs[*branch]; (!ok && *branch != "main") || (ok && ref.Type != "branch") {
+		return fmt.Errorf("%s.%s has no branch named %s", ns, tableName, *branch)
+	}
+
+	current := metadata.BranchSnapshot(*branch)
+	pickedID := strconv.FormatInt(picked.SnapshotID, 10)
+	for s := current; s != nil; {
+		if s.SnapshotID == picked.SnapshotID || s.Summary["source-snapshot-id"] == pickedID {
+			return fmt.Errorf("snapshot %d is already part of branch %s", picked.SnapshotID, *branch)
+		}
+		if s.ParentSnapshotID == nil {
+			break
+		}
+		s = metadata.SnapshotByID(*s.ParentSnapshotID)
+	}
+
+	// A snapshot staged directly on top of the branch is simply published
+	parentIsCurrent := (current == nil && picked.ParentSnapshotID == nil) ||
+		(current != nil && picked.ParentSnapshotID != nil && *picked.ParentSnapshotID == current.SnapshotID)
+	if parentIsCurrent {
+		fmt.Printf("⏩ Fast-forwarding %s of '%s.%s' to snapshot %d...\n", *branch, ns, tableName, picked.SnapshotID)
+		if err := moveBranch(ctx, client, ns, tableName, metadata, *branch, current, picked.SnapshotID); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Branch %s now points at snapshot %d\n", *branch, picked.SnapshotID)
+		return nil
+	}
+
+	files, err := addedDataFiles(fileIO, picked)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("snapshot %d did not add any data files", picked.SnapshotID)
+	}
+
+	fmt.Printf("🍒 Cherry-picking snapshot %d (%d data files) onto %s of '%s.%s'...\n", picked.SnapshotID, len(files), *branch, ns, tableName)
+
+	update := fileIO.NewSnapshotUpdate(metadata, *branch, iceberg.OperationAppend)
+	for _, file := range files {
+		update.AddFile(file)
+	}
+	update.SetSummaryProperty("source-snapshot-id", pickedID)
+
+	commit, snapshot, err := update.Stage()
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.CommitTable(ctx, ns, tableName, commit); err != nil {
+		if iceberg.IsCommitConflict(err) {
+			return fmt.Errorf("%w (%s)", err, conflictHint)
+		}
+		return err
+	}
+
+	fmt.Printf("✅ Created snapshot %d on %s with the changes of snapshot %d\n", snapshot.SnapshotID, *branch, picked.SnapshotID)
+	return nil
+}
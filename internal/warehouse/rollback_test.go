@@ -0,0 +1,52 @@
+package warehouse
+
+import (
+	"testing"
+
+	"the-modern-data-stack/internal/iceberg"
+)
+
+func TestAncestorAsOf(t *testing.T) {
+	parent := func(id int64) *int64 { return &id }
+	// 1 ← 2 ← 4 on main, and 1 ← 3 on another branch created between 2 and 4
+	metadata := &iceberg.TableMetadata{Snapshots: []iceberg.Snapshot{
+		{SnapshotID: 1, TimestampMs: 1000},
+		{SnapshotID: 2, ParentSnapshotID: parent(1), TimestampMs: 2000},
+		{SnapshotID: 3, ParentSnapshotID: parent(1), TimestampMs: 2500},
+		{SnapshotID: 4, ParentSnapshotID: parent(2), TimestampMs: 3000},
+	}}
+	current := metadata.SnapshotByID(4)
+
+	tests := []struct {
+		timestampMs int64
+		want        int64 // 0 when no ancestor is old enough
+	}{
+		{5000, 4},
+		{3000, 4},
+		{2999, 2},
+		{2500, 2}, // snapshot 3 is newer but not an ancestor
+		{2000, 2},
+		{1000, 1},
+		{999, 0},
+	}
+	for _, test := range tests {
+		got := ancestorAsOf(metadata, current, test.timestampMs)
+		switch {
+		case test.want == 0 && got != nil:
+			t.Errorf("ancestor as of %d = %d, want none", test.timestampMs, got.SnapshotID)
+		case test.want != 0 && (got == nil || got.SnapshotID != test.want):
+			t.Errorf("ancestor as of %d = %v, want %d", test.timestampMs, got, test.want)
+		}
+	}
+
+	// The history of a snapshot whose parent was expired stops there
+	expired := &iceberg.TableMetadata{Snapshots: []iceberg.Snapshot{
+		{SnapshotID: 4, ParentSnapshotID: parent(2), TimestampMs: 3000},
+	}}
+	if got := ancestorAsOf(expired, expired.SnapshotByID(4), 2000); got != nil {
+		t.Errorf("ancestor of an expired parent = %d, want none", got.SnapshotID)
+	}
+	if got := ancestorAsOf(metadata, nil, 5000); got != nil {
+		t.Errorf("ancestor of no snapshot = %d, want none", got.SnapshotID)
+	}
+}
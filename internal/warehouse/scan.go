@@ -0,0 +1,222 @@
+package warehouse
+
+import (
+	"fmt"
+	"strings"
+
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// sourceOptions are the options of read_parquet reading the files of the Parquet directory.
+// Hive-style partition directories, such as annee=2023/, are not parsed: convert writes the
+// partition columns into the files, with their exact types, which the directory names lose.
+const sourceOptions = ", hive_partitioning = false"
+
+// parquetList builds a DuckDB list literal of file paths
+func parquetList(paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = sqlutil.QuoteString(p)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// duckDBType returns the DuckDB type matching an Iceberg primitive type
+func duckDBType(icebergType interface{}) (string, error) {
+	t, ok := icebergType.(string)
+	if !ok {
+		return "", fmt.Errorf("nested type %v is not supported", icebergType)
+	}
+
+	switch {
+	case t == "boolean":
+		return "BOOLEAN", nil
+	case t == "int":
+		return "INTEGER", nil
+	case t == "long":
+		return "BIGINT", nil
+	case t == "float":
+		return "FLOAT", nil
+	case t == "double":
+		return "DOUBLE", nil
+	case t == "string":
+		return "VARCHAR", nil
+	case t == "binary" || strings.HasPrefix(t, "fixed"):
+		return "BLOB", nil
+	case t == "uuid":
+		return "UUID", nil
+	case t == "date":
+		return "DATE", nil
+	case t == "time":
+		return "TIME", nil
+	case t == "timestamp":
+		return "TIMESTAMP", nil
+	case t == "timestamptz":
+		return "TIMESTAMPTZ", nil
+	case strings.HasPrefix(t, "decimal"):
+		return strings.ToUpper(strings.ReplaceAll(t, " ", "")), nil
+	}
+
+	return "", fmt.Errorf("unsupported Iceberg type %q", t)
+}
+
+// icebergType returns the Iceberg primitive type matching a DuckDB column type
+func icebergType(duckdbType string) (string, error) {
+	t := strings.ToUpper(strings.TrimSpace(duckdbType))
+
+	switch {
+	case t == "BOOLEAN":
+		return "boolean", nil
+	case t == "TINYINT" || t == "SMALLINT" || t == "INTEGER" || t == "UTINYINT" || t == "USMALLINT":
+		return "int", nil
+	case t == "BIGINT" || t == "UINTEGER":
+		return "long", nil
+	case t == "HUGEINT" || t == "UBIGINT":
+		// sum() of BIGINT columns returns HUGEINT, which only fits a 38 digit decimal
+		return "decimal(38, 0)", nil
+	case t == "FLOAT" || t == "REAL":
+		return "float", nil
+	case t == "DOUBLE":
+		return "double", nil
+	case t == "VARCHAR":
+		return "string", nil
+	case t == "BLOB":
+		return "binary", nil
+	case t == "UUID":
+		return "uuid", nil
+	case t == "DATE":
+		return "date", nil
+	case t == "TIME":
+		return "time", nil
+	case t == "TIMESTAMP":
+		return "timestamp", nil
+	case t == "TIMESTAMP WITH TIME ZONE" || t == "TIMESTAMPTZ":
+		return "timestamptz", nil
+	case strings.HasPrefix(t, "DECIMAL("):
+		return strings.ToLower(strings.ReplaceAll(t, ",", ", ")), nil
+	}
+
+	return "", fmt.Errorf("DuckDB type %s has no Iceberg equivalent", duckdbType)
+}
+
+// emptyScanSQL builds a query returning no rows but the columns of the schema
+func emptyScanSQL(schema *iceberg.Schema) (string, error) {
+	var columns []string
+	for _, field := range schema.Fields {
+		typ, err := duckDBType(field.Type)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %v", field.Name, err)
+		}
+		columns = append(columns, fmt.Sprintf("CAST(NULL AS %s) AS %s", typ, sqlutil.QuoteIdentifier(field.Name)))
+	}
+	return fmt.Sprintf("SELECT %s WHERE false", strings.Join(columns, ", ")), nil
+}
+
+// buildScanSQL builds a DuckDB query returning the live rows of a snapshot, given its
+// live manifest entries. As the Iceberg spec requires, position deletes apply to data
+// files with a lower or equal sequence number and equality deletes to data files with
+// a strictly lower one. It returns an empty string when the snapshot holds no data files.
+func buildScanSQL(fileIO *iceberg.FileIO, schema *iceberg.Schema, entries []iceberg.ManifestEntry) (string, error) {
+	return scanSQL(fileIO, schema, entries, false)
+}
+
+// Columns added by buildPositionScanSQL to locate each row
+const (
+	filePathColumn = "__iceberg_file_path"
+	positionColumn = "__iceberg_pos"
+)
+
+// buildPositionScanSQL is like buildScanSQL, but each row also carries the location of
+// its data file and its position in it, as position delete files record them
+func buildPositionScanSQL(fileIO *iceberg.FileIO, schema *iceberg.Schema, entries []iceberg.ManifestEntry) (string, error) {
+	return scanSQL(fileIO, schema, entries, true)
+}
+
+// scanSQL implements buildScanSQL and buildPositionScanSQL
+func scanSQL(fileIO *iceberg.FileIO, schema *iceberg.Schema, entries []iceberg.ManifestEntry, positions bool) (string, error) {
+	var dataFiles, positionDeletes, equalityDeletes []iceberg.ManifestEntry
+
+	for _, entry := range entries {
+		if !strings.EqualFold(entry.DataFile.FileFormat, "parquet") {
+			return "", fmt.Errorf("unsupported file format %s for %s", entry.DataFile.FileFormat, entry.DataFile.FilePath)
+		}
+
+		switch entry.DataFile.Content {
+		case iceberg.FileContentData:
+			dataFiles = append(dataFiles, entry)
+		case iceberg.FileContentPositionDeletes:
+			positionDeletes = append(positionDeletes, entry)
+		case iceberg.FileContentEqualityDeletes:
+			equalityDeletes = append(equalityDeletes, entry)
+		}
+	}
+
+	if len(dataFiles) == 0 {
+		return "", nil
+	}
+
+	var dataPaths []string
+	for _, entry := range dataFiles {
+		dataPaths = append(dataPaths, fileIO.LocalPath(entry.DataFile.FilePath))
+	}
+
+	if len(positionDeletes) == 0 && len(equalityDeletes) == 0 && !positions {
+		return fmt.Sprintf("SELECT * FROM read_parquet(%s, union_by_name = true)", parquetList(dataPaths)), nil
+	}
+
+	// Map the local paths DuckDB reports back to the locations recorded in delete files
+	var fileRows []string
+	for i, entry := range dataFiles {
+		fileRows = append(fileRows, fmt.Sprintf("(%s, %s, %d)",
+			sqlutil.QuoteString(dataPaths[i]), sqlutil.QuoteString(entry.DataFile.FilePath), entry.SequenceNumber))
+	}
+	ctes := []string{fmt.Sprintf("data_files(local_path, location, seq) AS (VALUES %s)", strings.Join(fileRows, ", "))}
+	var conditions []string
+
+	if len(positionDeletes) > 0 {
+		var deleteScans []string
+		for _, entry := range positionDeletes {
+			deleteScans = append(deleteScans, fmt.Sprintf("SELECT file_path, pos, %d AS seq FROM read_parquet(%s)",
+				entry.SequenceNumber, sqlutil.QuoteString(fileIO.LocalPath(entry.DataFile.FilePath))))
+		}
+		ctes = append(ctes, fmt.Sprintf("position_deletes AS (%s)", strings.Join(deleteScans, " UNION ALL ")))
+		conditions = append(conditions, `NOT EXISTS (
+	SELECT 1 FROM position_deletes d
+	WHERE d.file_path = data_files.location AND d.pos = data.file_row_number AND d.seq >= data_files.seq
+)`)
+	}
+
+	for _, entry := range equalityDeletes {
+		if len(entry.DataFile.EqualityIDs) == 0 {
+			return "", fmt.Errorf("equality delete file %s has no equality field IDs", entry.DataFile.FilePath)
+		}
+		var matches []string
+		for _, id := range entry.DataFile.EqualityIDs {
+			field := schema.FieldByID(id)
+			if field == nil {
+				return "", fmt.Errorf("equality delete file %s references unknown field %d", entry.DataFile.FilePath, id)
+			}
+			column := sqlutil.QuoteIdentifier(field.Name)
+			matches = append(matches, fmt.Sprintf("d.%s IS NOT DISTINCT FROM data.%s", column, column))
+		}
+		conditions = append(conditions, fmt.Sprintf(`NOT (data_files.seq < %d AND EXISTS (
+	SELECT 1 FROM read_parquet(%s) d
+	WHERE %s
+))`, entry.SequenceNumber, sqlutil.QuoteString(fileIO.LocalPath(entry.DataFile.FilePath)), strings.Join(matches, " AND ")))
+	}
+
+	columns := "data.* EXCLUDE (filename, file_row_number)"
+	if positions {
+		columns += fmt.Sprintf(", data_files.location AS %s, data.file_row_number AS %s", filePathColumn, positionColumn)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "\nWHERE " + strings.Join(conditions, "\nAND ")
+	}
+
+	return fmt.Sprintf(`WITH %s
+SELECT %s
+FROM read_parquet(%s, filename = true, file_row_number = true, union_by_name = true) AS data
+JOIN data_files ON data_files.local_path = data.filename%s`, strings.Join(ctes, ",\n"), columns, parquetList(dataPaths), where), nil
+}
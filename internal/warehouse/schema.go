@@ -0,0 +1,52 @@
+package warehouse
+
+import (
+	"context"
+	"fmt"
+
+	"the-modern-data-stack/internal/cli"
+)
+
+func runSchema(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("schema")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) != 1 {
+		return cli.Usagef("expected a single table")
+	}
+	ns, tableName := parseTableIdentifier(positional[0], *namespace)
+
+	table, err := opts.client().LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &table.Metadata
+	schema := metadata.CurrentSchema()
+	if schema == nil {
+		return fmt.Errorf("table metadata has no current schema")
+	}
+
+	fmt.Printf("📋 Schema %d of '%s.%s' (%d columns):\n", schema.SchemaID, ns, tableName, len(schema.Fields))
+	for _, field := range schema.Fields {
+		line := fmt.Sprintf("   - %s: %v", field.Name, field.Type)
+		if field.Required {
+			line += " (required)"
+		}
+		if field.Doc != "" {
+			line += " — " + field.Doc
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("🧩 Partitioning: %s\n", formatSpec(metadata.DefaultSpec(), schema))
+
+	if len(metadata.Properties) > 0 {
+		fmt.Println("⚙️  Properties:")
+		for _, key := range sortedKeys(metadata.Properties) {
+			fmt.Printf("   - %s: %s\n", key, metadata.Properties[key])
+		}
+	}
+	fmt.Printf("📁 Location: %s\n", metadata.Location)
+	return nil
+}
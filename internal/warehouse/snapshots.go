@@ -0,0 +1,107 @@
+package warehouse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+)
+
+// formatTimestampMs formats an Iceberg millisecond timestamp for display
+func formatTimestampMs(ms int64) string {
+	return time.UnixMilli(ms).Format("2006-01-02 15:04:05 MST")
+}
+
+// refsBySnapshot groups the branch and tag names of a table by the snapshot they point at
+func refsBySnapshot(metadata *iceberg.TableMetadata) map[int64][]string {
+	refs := make(map[int64][]string)
+	for name, ref := range metadata.Refs {
+		label := name
+		if ref.Type == "tag" {
+			label = "tag:" + name
+		}
+		refs[ref.SnapshotID] = append(refs[ref.SnapshotID], label)
+	}
+	for id := range refs {
+		sort.Strings(refs[id])
+	}
+	return refs
+}
+
+func runSnapshots(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("snapshots")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace of the table when not given as namespace.table")
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) != 1 {
+		return cli.Usagef("expected a single table")
+	}
+	ns, tableName := parseTableIdentifier(positional[0], *namespace)
+
+	table, err := opts.client().LoadTable(ctx, ns, tableName)
+	if err != nil {
+		return err
+	}
+	metadata := &table.Metadata
+
+	if len(metadata.Snapshots) == 0 {
+		fmt.Printf("ℹ️  Table '%s.%s' has no snapshots yet\n", ns, tableName)
+		return nil
+	}
+
+	snapshots := append([]iceberg.Snapshot(nil), metadata.Snapshots...)
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].TimestampMs < snapshots[j].TimestampMs
+	})
+
+	refs := refsBySnapshot(metadata)
+
+	fmt.Printf("📸 Snapshots of '%s.%s' (%d):\n", ns, tableName, len(snapshots))
+	for _, snapshot := range snapshots {
+		labels := refs[snapshot.SnapshotID]
+		if metadata.CurrentSnapshotID != nil && *metadata.CurrentSnapshotID == snapshot.SnapshotID {
+			labels = append([]string{"current"}, labels...)
+		}
+
+		header := fmt.Sprintf("%d", snapshot.SnapshotID)
+		if len(labels) > 0 {
+			header += " (" + strings.Join(labels, ", ") + ")"
+		}
+		fmt.Printf("\n🔹 %s\n", header)
+		fmt.Printf("   - Timestamp: %s\n", formatTimestampMs(snapshot.TimestampMs))
+
+		operation := snapshot.Summary["operation"]
+		if operation == "" {
+			operation = "unknown"
+		}
+		fmt.Printf("   - Operation: %s\n", operation)
+
+		if snapshot.ParentSnapshotID != nil {
+			fmt.Printf("   - Parent: %d\n", *snapshot.ParentSnapshotID)
+		}
+		if snapshot.SequenceNumber > 0 {
+			fmt.Printf("   - Sequence number: %d\n", snapshot.SequenceNumber)
+		}
+
+		var keys []string
+		for key := range snapshot.Summary {
+			if key != "operation" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		if len(keys) > 0 {
+			fmt.Println("   - Summary:")
+			for _, key := range keys {
+				fmt.Printf("     • %s: %s\n", key, snapshot.Summary[key])
+			}
+		}
+	}
+
+	return nil
+}
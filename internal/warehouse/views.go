@@ -0,0 +1,325 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"the-modern-data-stack/internal/cli"
+	"the-modern-data-stack/internal/iceberg"
+	"the-modern-data-stack/internal/logging"
+	"the-modern-data-stack/internal/sqlutil"
+)
+
+// viewDialects are the SQL dialects a portable view definition is registered for
+var viewDialects = []string{"trino", "duckdb"}
+
+// viewDefinition holds the SQL of a view read from the views directory, by dialect
+type viewDefinition struct {
+	name            string
+	representations map[string]string
+}
+
+// readViewDefinitions reads the views of a directory. A file named <view>.sql holds
+// SQL that runs unchanged on every dialect, while <view>.<dialect>.sql files hold the
+// SQL of one dialect and take precedence over the portable file.
+func readViewDefinitions(dir string) ([]*viewDefinition, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*viewDefinition)
+	portable := make(map[string]string)
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		sqlText := strings.TrimRight(strings.TrimSpace(string(content)), ";")
+		if sqlText == "" {
+			return nil, fmt.Errorf("%s is empty", path)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), ".sql")
+		dialect := ""
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name, dialect = name[:i], strings.ToLower(name[i+1:])
+		}
+
+		view := byName[name]
+		if view == nil {
+			view = &viewDefinition{name: name, representations: make(map[string]string)}
+			byName[name] = view
+		}
+		if dialect == "" {
+			portable[name] = sqlText
+		} else {
+			view.representations[dialect] = sqlText
+		}
+	}
+
+	for name, sqlText := range portable {
+		for _, dialect := range viewDialects {
+			if _, ok := byName[name].representations[dialect]; !ok {
+				byName[name].representations[dialect] = sqlText
+			}
+		}
+	}
+
+	var views []*viewDefinition
+	for _, view := range byName {
+		views = append(views, view)
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].name < views[j].name })
+	return views, nil
+}
+
+// viewRepresentations returns the SQL representations of a view sorted by dialect
+func (v *viewDefinition) viewRepresentations() []iceberg.ViewRepresentation {
+	var representations []iceberg.ViewRepresentation
+	for dialect, sqlText := range v.representations {
+		representations = append(representations, iceberg.ViewRepresentation{Type: "sql", SQL: sqlText, Dialect: dialect})
+	}
+	sort.Slice(representations, func(i, j int) bool { return representations[i].Dialect < representations[j].Dialect })
+	return representations
+}
+
+// createTableViews creates a DuckDB view over the current snapshot of every table of a
+// namespace, both unqualified and within a schema named after the namespace, so that
+// view definitions can be run to derive their columns
+func createTableViews(ctx context.Context, db *sql.DB, client *iceberg.Client, fileIO *iceberg.FileIO, namespace string) error {
+	tables, err := client.ListTables(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	schemaName := sqlutil.QuoteIdentifier(namespace)
+	if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+schemaName); err != nil {
+		return fmt.Errorf("failed to create schema %s: %v", namespace, err)
+	}
+
+	for _, tableName := range tables {
+		table, err := client.LoadTable(ctx, namespace, tableName)
+		if err != nil {
+			return err
+		}
+		if err := createCurrentView(ctx, db, fileIO, &table.Metadata, tableName); err != nil {
+			return err
+		}
+
+		qualified := fmt.Sprintf("CREATE VIEW %s.%s AS SELECT * FROM main.%s", schemaName, sqlutil.QuoteIdentifier(tableName), sqlutil.QuoteIdentifier(tableName))
+		if _, err := db.ExecContext(ctx, qualified); err != nil {
+			return fmt.Errorf("failed to create view for %s.%s: %v", namespace, tableName, err)
+		}
+	}
+	return nil
+}
+
+// createCurrentView creates a DuckDB view reading the current snapshot of a table, or
+// returning no rows but its columns when the table holds no data yet
+func createCurrentView(ctx context.Context, db *sql.DB, fileIO *iceberg.FileIO, metadata *iceberg.TableMetadata, tableName string) error {
+	if snapshot := metadata.CurrentSnapshot(); snapshot != nil {
+		return createSnapshotView(ctx, db, fileIO, metadata, tableName, snapshot)
+	}
+
+	schema := metadata.CurrentSchema()
+	if schema == nil {
+		return fmt.Errorf("table %s has no current schema", tableName)
+	}
+	scanSQL, err := emptyScanSQL(schema)
+	if err != nil {
+		return fmt.Errorf("table %s: %v", tableName, err)
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE VIEW %s AS %s", sqlutil.QuoteIdentifier(tableName), scanSQL)); err != nil {
+		return fmt.Errorf("failed to create view for %s: %v", tableName, err)
+	}
+	return nil
+}
+
+// viewSchema derives the Iceberg schema of a view from the columns DuckDB reports for its query
+func viewSchema(ctx context.Context, db *sql.DB, query string) (*iceberg.Schema, error) {
+	rows, err := db.QueryContext(ctx, "DESCRIBE "+query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schema := &iceberg.Schema{Type: "struct", Fields: []iceberg.Field{}}
+	for rows.Next() {
+		var name, typ string
+		var null, key, defaultVal, extra sql.NullString
+		if err := rows.Scan(&name, &typ, &null, &key, &defaultVal, &extra); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		fieldType, err := icebergType(typ)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+		schema.Fields = append(schema.Fields, iceberg.Field{
+			ID:       len(schema.Fields) + 1,
+			Name:     name,
+			Required: false,
+			Type:     fieldType,
+		})
+	}
+	return schema, rows.Err()
+}
+
+// createOrReplaceView creates the view in the catalog, or adds a new version to it when
+// its SQL or columns changed. It reports what was done.
+func createOrReplaceView(ctx context.Context, client *iceberg.Client, namespace string, view *viewDefinition, schema *iceberg.Schema) (string, error) {
+	version := iceberg.ViewVersion{
+		VersionID:        1,
+		TimestampMs:      time.Now().UnixMilli(),
+		Summary:          map[string]string{"engine-name": "duckdb", "created-by": "mds"},
+		Representations:  view.viewRepresentations(),
+		DefaultNamespace: strings.Split(namespace, "."),
+	}
+
+	existing, err := client.LoadView(ctx, namespace, view.name)
+	if err != nil {
+		if !iceberg.IsNotFound(err) {
+			return "", err
+		}
+		request := &iceberg.CreateViewRequest{
+			Name:        view.name,
+			Schema:      *schema,
+			ViewVersion: version,
+			Properties:  map[string]string{},
+		}
+		if _, err := client.CreateView(ctx, namespace, request); err != nil {
+			return "", err
+		}
+		return "created", nil
+	}
+
+	metadata := &existing.Metadata
+	if current := metadata.CurrentVersion(); current != nil {
+		currentSchema := metadata.SchemaByID(current.SchemaID)
+		if reflect.DeepEqual(current.Representations, version.Representations) &&
+			currentSchema != nil && reflect.DeepEqual(currentSchema.Fields, schema.Fields) {
+			return "unchanged", nil
+		}
+	}
+
+	for _, v := range metadata.Versions {
+		if v.VersionID >= version.VersionID {
+			version.VersionID = v.VersionID + 1
+		}
+	}
+	for _, s := range metadata.Schemas {
+		if s.SchemaID >= schema.SchemaID {
+			schema.SchemaID = s.SchemaID + 1
+		}
+	}
+	version.SchemaID = -1
+
+	commit := &iceberg.TableCommit{
+		Requirements: []iceberg.TableRequirement{iceberg.AssertViewUUID(metadata.ViewUUID)},
+		Updates: []iceberg.TableUpdate{
+			iceberg.AddViewSchema(schema),
+			iceberg.AddViewVersion(&version),
+			iceberg.SetCurrentViewVersion(-1),
+		},
+	}
+	if _, err := client.ReplaceView(ctx, namespace, view.name, commit); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("replaced (version %d)", version.VersionID), nil
+}
+
+func runCreateViews(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("create-views")
+	opts := addCatalogFlags(fs)
+	namespace := fs.String("namespace", "my_data", "Namespace to create the views in")
+	dir := fs.String("dir", "views", "Directory holding the <view>.sql and <view>.<dialect>.sql definitions")
+	positional := parseInterspersed(fs, args)
+
+	views, err := readViewDefinitions(*dir)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		wanted := make(map[string]bool)
+		for _, name := range positional {
+			wanted[name] = true
+		}
+		var selected []*viewDefinition
+		for _, view := range views {
+			if wanted[view.name] {
+				selected = append(selected, view)
+				delete(wanted, view.name)
+			}
+		}
+		if len(wanted) > 0 {
+			var missing []string
+			for name := range wanted {
+				missing = append(missing, name)
+			}
+			sort.Strings(missing)
+			return fmt.Errorf("no definition of view(s) %s in %s", strings.Join(missing, ", "), *dir)
+		}
+		views = selected
+	}
+	if len(views) == 0 {
+		fmt.Printf("ℹ️  No view definitions found in %s\n", *dir)
+		return nil
+	}
+
+	client := opts.client()
+	fileIO := opts.fileIO()
+
+	db, err := logging.OpenDB("duckdb", "")
+	if err != nil {
+		return fmt.Errorf("failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+
+	if err := createTableViews(ctx, db, client, fileIO, *namespace); err != nil {
+		return err
+	}
+
+	fmt.Printf("👓 Creating %d view(s) in namespace '%s'...\n", len(views), *namespace)
+	failed := 0
+	for _, view := range views {
+		// The columns are derived from the DuckDB SQL, which may differ from the Trino one
+		query, ok := view.representations["duckdb"]
+		if !ok {
+			fmt.Printf("   ❌ %s: a portable %s.sql or a %s.duckdb.sql definition is needed to derive its columns\n", view.name, view.name, view.name)
+			failed++
+			continue
+		}
+		schema, err := viewSchema(ctx, db, query)
+		if err != nil {
+			fmt.Printf("   ❌ %s: invalid DuckDB SQL: %v\n", view.name, err)
+			failed++
+			continue
+		}
+
+		result, err := createOrReplaceView(ctx, client, *namespace, view, schema)
+		if err != nil {
+			fmt.Printf("   ❌ %s: %v\n", view.name, err)
+			failed++
+			continue
+		}
+
+		var dialects []string
+		for _, r := range view.viewRepresentations() {
+			dialects = append(dialects, r.Dialect)
+		}
+		fmt.Printf("   ✅ %s: %s [%s, %d column(s)]\n", view.name, result, strings.Join(dialects, ", "), len(schema.Fields))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d view(s) could not be created", failed, len(views))
+	}
+	fmt.Printf("💡 Query them from Trino as iceberg.%s.<view>\n", *namespace)
+	return nil
+}
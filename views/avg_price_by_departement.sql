@@ -0,0 +1,7 @@
+-- Average transaction price per département
+SELECT
+    departement,
+    COUNT(*) AS transactions,
+    AVG(prix) AS avg_price
+FROM transactions_sample
+GROUP BY departement
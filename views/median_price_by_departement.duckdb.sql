@@ -0,0 +1,6 @@
+-- Median transaction price per département (DuckDB)
+SELECT
+    departement,
+    CAST(median(prix) AS DOUBLE) AS median_price
+FROM transactions_sample
+GROUP BY departement
@@ -0,0 +1,6 @@
+-- Median transaction price per département (Trino)
+SELECT
+    departement,
+    approx_percentile(prix, 0.5) AS median_price
+FROM transactions_sample
+GROUP BY departement
@@ -0,0 +1,16 @@
+-- Number of rows of every table loaded by the main workflow
+SELECT 'transactions_sample' AS table_name, COUNT(*) AS row_count FROM transactions_sample
+UNION ALL
+SELECT 'flux_nouveaux_emprunts', COUNT(*) FROM flux_nouveaux_emprunts
+UNION ALL
+SELECT 'foyers_fiscaux', COUNT(*) FROM foyers_fiscaux
+UNION ALL
+SELECT 'loyers', COUNT(*) FROM loyers
+UNION ALL
+SELECT 'parc_immobilier', COUNT(*) FROM parc_immobilier
+UNION ALL
+SELECT 'taux_endettement', COUNT(*) FROM taux_endettement
+UNION ALL
+SELECT 'taux_interet', COUNT(*) FROM taux_interet
+UNION ALL
+SELECT 'indice_reference_loyers', COUNT(*) FROM indice_reference_loyers